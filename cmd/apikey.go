@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/ratelimit"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+
+	"github.com/google/uuid"
+)
+
+const apikeyUsage = "usage: wallet-backend [flags] apikey create -name NAME [-admin] [-rate-limit N -rate-burst N] | revoke CLIENT_ID"
+
+// runAPIKey implements the apikey subcommand, which registers API clients and
+// revokes their keys.
+func runAPIKey(ctx context.Context, dbConfig repository.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(apikeyUsage)
+	}
+
+	postgres, err := repository.NewPG(ctx, dbConfig)
+	if err != nil {
+		return err
+	}
+	defer postgres.Close()
+	s := service.NewService(repository.NewRepository(postgres), service.Config{})
+
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		name := flags.String("name", "", "client name")
+		admin := flags.Bool("admin", false, "allow access to every wallet and the admin API")
+		rate := flags.Float64("rate-limit", -1, "requests per second instead of the default client limit; 0 for no limit")
+		burst := flags.Int("rate-burst", -1, "burst of the client's own rate limit")
+		if err := flags.Parse(args[1:]); err != nil || *name == "" || flags.NArg() > 0 {
+			return errors.New(apikeyUsage)
+		}
+
+		client := models.Client{Name: *name, Admin: *admin}
+		switch {
+		case *rate < 0 && *burst < 0:
+		case *rate < 0 || *burst < 0:
+			return errors.New("-rate-limit and -rate-burst must be set together")
+		case *rate > 0 && *burst == 0:
+			return errors.New("-rate-burst must be positive")
+		default:
+			client.RateLimit = &ratelimit.Limit{Rate: *rate, Burst: *burst}
+		}
+
+		client, key, err := s.NewClient(ctx, client)
+		if err != nil {
+			return err
+		}
+		// The key is only stored hashed, so this is the one chance to see it.
+		fmt.Printf("client: %s\nkey: %s\n", client.ID, key)
+		return nil
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New(apikeyUsage)
+		}
+		clientID, err := uuid.Parse(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid client ID %q", args[1])
+		}
+		return s.RevokeClient(ctx, clientID)
+	default:
+		return errors.New(apikeyUsage)
+	}
+}
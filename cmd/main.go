@@ -2,42 +2,228 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"wallet-app/pkg/config"
 	"wallet-app/pkg/handler"
+	"wallet-app/pkg/logging"
+	"wallet-app/pkg/metrics"
+	"wallet-app/pkg/migrations"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/outbox"
+	"wallet-app/pkg/ratelimit"
 	"wallet-app/pkg/repository"
 	"wallet-app/pkg/server"
 	"wallet-app/pkg/service"
+	"wallet-app/pkg/tracing"
+	"wallet-app/pkg/webhook"
 
-	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
 
-	if err := godotenv.Load("config.env"); err != nil {
-		log.Fatalf("error loading env variables: %s", err.Error())
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fatal("error loading config", err)
+	}
+	slog.SetDefault(logging.New(os.Stderr, cfg.LogLevel))
+
+	dbConfig := repository.Config{
+		URL:             cfg.DB.URL,
+		Host:            cfg.DB.Host,
+		Port:            cfg.DB.Port,
+		User:            cfg.DB.User,
+		Pass:            cfg.DB.Pass,
+		DBName:          cfg.DB.Name,
+		SSLMode:         cfg.DB.SSLMode,
+		MaxConns:        cfg.DB.MaxConns,
+		MinConns:        cfg.DB.MinConns,
+		MaxConnLifetime: cfg.DB.MaxConnLifetime,
+		MaxConnIdleTime: cfg.DB.MaxConnIdleTime,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Wallets that predate currencies are migrated to the default currency
+	// the application creates wallets in.
+	migrationSettings := migrations.Settings{DefaultCurrency: cfg.DefaultCurrency}
+
+	reconcileOptions := models.ReconcileOptions{
+		Stream:    cfg.Reconcile.Stream,
+		BatchSize: cfg.Reconcile.BatchSize,
+	}
+
+	if len(cfg.Args) > 0 {
+		switch cfg.Args[0] {
+		case "migrate":
+			if err := runMigrate(ctx, dbConfig.ConnString(), migrationSettings, cfg.Args[1:]); err != nil {
+				fatal("migrate", err)
+			}
+		case "apikey":
+			if err := runAPIKey(ctx, dbConfig, cfg.Args[1:]); err != nil {
+				fatal("apikey", err)
+			}
+		case "reconcile":
+			if err := runReconcile(ctx, dbConfig, reconcileOptions, cfg.Args[1:]); err != nil {
+				fatal("reconcile", err)
+			}
+		default:
+			fatal("unknown command", fmt.Errorf("%q", cfg.Args[0]))
+		}
+		return
+	}
+
+	if cfg.MigrateOnStart {
+		if err := runMigrate(ctx, dbConfig.ConnString(), migrationSettings, []string{"up"}); err != nil {
+			fatal("migrate", err)
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		fatal("tracing", err)
 	}
 
 	// repo := repository.NewRepository()
-	postgres, err := repository.NewPG(context.Background(), repository.Config{
-		Host:    os.Getenv("DB_HOST"),
-		Port:    os.Getenv("DB_PORT"),
-		User:    os.Getenv("DB_USER"),
-		Pass:    os.Getenv("DB_PASS"),
-		DBName:  os.Getenv("DB_NAME"),
-		SSLMode: os.Getenv("DB_SSLMODE"),
-	})
+	postgres, err := repository.NewPG(context.Background(), dbConfig)
+	if err != nil {
+		fatal("database", err)
+	}
+
+	prometheus.MustRegister(metrics.NewPoolCollector(postgres.Stat))
 
+	schemaVersion, err := migrations.Latest()
 	if err != nil {
-		log.Fatal(err)
+		fatal("migrations", err)
 	}
 
 	repo := repository.NewRepository(postgres)
-	service := service.NewService(repo)
-	handler := handler.NewHandler(service)
-	router := handler.RegisterRoutes()
-	server := new(server.Server)
-	if err := server.Run("8000", router); err != nil {
-		log.Fatal(err)
+	webhookOptions := service.WebhookOptions{
+		Client:      webhook.NewClient(cfg.Webhooks.Timeout, cfg.Webhooks.AllowPrivate),
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		Backoff:     cfg.Webhooks.Backoff,
+	}
+	service := service.NewService(repo, service.Config{
+		DefaultCurrency: cfg.DefaultCurrency,
+		HoldTTL:         cfg.HoldTTL,
+		SchemaVersion:   schemaVersion,
+
+		AllowPrivateWebhooks: cfg.Webhooks.AllowPrivate,
+	})
+	// Every background worker is tracked, so the pool outlives them.
+	var workers sync.WaitGroup
+	background := func(run func()) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run()
+		}()
+	}
+	if cfg.BalanceSnapshotInterval > 0 {
+		background(func() { service.RunBalanceSnapshots(ctx, cfg.BalanceSnapshotInterval) })
+	}
+	if cfg.Reconcile.Interval > 0 {
+		background(func() { service.RunReconciliation(ctx, cfg.Reconcile.Interval, reconcileOptions) })
 	}
+
+	publisher, closePublisher, err := outbox.New(cfg.Outbox.Publisher)
+	if err != nil {
+		fatal("outbox", err)
+	}
+	if cfg.Outbox.Interval > 0 {
+		relayTo := outbox.Multi(publisher, service.WebhookPublisher(), service.EventPublisher())
+		background(func() { service.RunOutboxRelay(ctx, cfg.Outbox.Interval, relayTo, cfg.Outbox.BatchSize) })
+	}
+	if cfg.Webhooks.Interval > 0 {
+		background(func() { service.RunWebhookDeliveries(ctx, cfg.Webhooks.Interval, webhookOptions) })
+	}
+
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Store == ratelimit.StorePostgres {
+		rateLimitStore = postgres.RateLimits()
+	}
+
+	h := handler.NewHandler(service)
+	router := h.RegisterRoutes(handler.Config{
+		AllowedOrigins:   cfg.HTTP.CORSOrigins,
+		ReadinessTimeout: cfg.HTTP.ReadinessTimeout,
+		// Shutdown starts when ctx is done, see server.Serve.
+		ShuttingDown: ctx.Done(),
+		RateLimits: &handler.RateLimits{
+			Store:  rateLimitStore,
+			Client: cfg.RateLimit.Client,
+			Wallet: cfg.RateLimit.Wallet,
+		},
+		EventsHeartbeat:    cfg.HTTP.EventsHeartbeat,
+		EventsPollInterval: cfg.HTTP.EventsPollInterval,
+	})
+
+	if cfg.HTTP.MetricsPort > 0 {
+		// The metrics are served apart from the API, where only the
+		// monitoring network can reach them.
+		metricsServer := server.New(server.Config{
+			ReadTimeout:     cfg.HTTP.ReadTimeout,
+			WriteTimeout:    cfg.HTTP.WriteTimeout,
+			IdleTimeout:     cfg.HTTP.IdleTimeout,
+			ShutdownTimeout: cfg.HTTP.ShutdownTimeout,
+			DrainDelay:      cfg.HTTP.DrainDelay,
+		})
+		background(func() {
+			if err := metricsServer.Run(ctx, cfg.HTTP.MetricsPort, handler.MetricsRoutes()); err != nil {
+				slog.Error("metrics server", slog.Any("error", err))
+			}
+		})
+	}
+
+	server := server.New(server.Config{
+		ReadTimeout:     cfg.HTTP.ReadTimeout,
+		WriteTimeout:    cfg.HTTP.WriteTimeout,
+		IdleTimeout:     cfg.HTTP.IdleTimeout,
+		ShutdownTimeout: cfg.HTTP.ShutdownTimeout,
+		DrainDelay:      cfg.HTTP.DrainDelay,
+	})
+	err = server.Run(ctx, cfg.HTTP.Port, router)
+
+	// The pool is closed only after in-flight requests and the background
+	// workers are done with it. The server may also stop on an error, so
+	// make sure the workers stop too. Waiting for the workers and flushing
+	// traces share the deadline of the server's shutdown, so the whole of
+	// it takes no longer than the drain delay and the shutdown timeout.
+	stop()
+	shutdownCtx, cancel := server.ShutdownContext()
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		slog.Error("background workers did not stop in time")
+	}
+	postgres.Close()
+	if err := closePublisher(); err != nil {
+		slog.Error("close outbox publisher", slog.Any("error", err))
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("flush traces", slog.Any("error", err))
+	}
+	cancel()
+
+	if err != nil {
+		fatal("server", err)
+	}
+	slog.Info("server stopped")
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"wallet-app/pkg/migrations"
+)
+
+const migrateUsage = "usage: wallet-backend [flags] migrate up [N] | down [N] | status"
+
+// runMigrate implements the migrate subcommand. settings carry the
+// configuration the migrations need, such as DEFAULT_CURRENCY.
+func runMigrate(ctx context.Context, connString string, settings migrations.Settings, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := migrations.New(connString, settings)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		if len(args) == 1 {
+			return migrator.Up(ctx)
+		}
+		steps, err := parseSteps(args)
+		if err != nil {
+			return err
+		}
+		return migrator.UpSteps(ctx, steps)
+	case "down":
+		if len(args) == 1 {
+			return migrator.Down(ctx, 1)
+		}
+		steps, err := parseSteps(args)
+		if err != nil {
+			return err
+		}
+		return migrator.Down(ctx, steps)
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStatus(status)
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// parseSteps reads N from "up N" or "down N".
+func parseSteps(args []string) (int, error) {
+	if len(args) != 2 {
+		return 0, errors.New(migrateUsage)
+	}
+	steps, err := strconv.Atoi(args[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid number of steps %q", args[1])
+	}
+	return steps, nil
+}
+
+func printStatus(status migrations.Status) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, m := range status.Migrations {
+		state := "pending"
+		if m.Applied {
+			state = "applied"
+		}
+		if m.Version == status.Version && status.Dirty {
+			state = "dirty"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, state)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+)
+
+const reconcileUsage = "usage: wallet-backend [flags] reconcile [-stream] [-batch-size N]"
+
+// runReconcile implements the reconcile subcommand, which checks every
+// wallet's balance against its ledger once. It fails if any didn't match, so
+// that a cron job running it is noticed.
+func runReconcile(ctx context.Context, dbConfig repository.Config, opts models.ReconcileOptions, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.BoolVar(&opts.Stream, "stream", opts.Stream, "check the wallets in batches instead of with a single query")
+	flags.IntVar(&opts.BatchSize, "batch-size", opts.BatchSize, "wallets per batch with -stream")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 || opts.BatchSize < 1 {
+		return errors.New(reconcileUsage)
+	}
+
+	postgres, err := repository.NewPG(ctx, dbConfig)
+	if err != nil {
+		return err
+	}
+	defer postgres.Close()
+	s := service.NewService(repository.NewRepository(postgres), service.Config{})
+
+	run, err := s.Reconcile(ctx, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("reconciliation: %s\nwallets: %d\ndiscrepancies: %d\n", run.ID, run.Wallets, run.Discrepancies)
+	if run.Discrepancies > 0 {
+		return fmt.Errorf("%d wallets don't match their ledger, see reconciliation_discrepancies", run.Discrepancies)
+	}
+	return nil
+}
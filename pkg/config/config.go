@@ -0,0 +1,475 @@
+// Package config loads the application settings. Every setting has a
+// default and can be overridden, from lowest to highest precedence, by an
+// optional env file, environment variables and command-line flags. A setting
+// named KEY can also be read from the file named by KEY_FILE, which is how
+// secrets are usually mounted into containers.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"wallet-app/pkg/currency"
+	"wallet-app/pkg/outbox"
+	"wallet-app/pkg/ratelimit"
+	"wallet-app/pkg/tracing"
+
+	"github.com/joho/godotenv"
+)
+
+// DefaultFile is read if it exists and no other file was asked for.
+const DefaultFile = "config.env"
+
+type (
+	Config struct {
+		HTTP            HTTP
+		DB              DB
+		DefaultCurrency string
+		HoldTTL         time.Duration
+		// BalanceSnapshotInterval is how often balance snapshots are taken;
+		// zero disables them.
+		BalanceSnapshotInterval time.Duration
+		Reconcile               Reconcile
+		Outbox                  Outbox
+		Webhooks                Webhooks
+		// MigrateOnStart applies pending migrations before serving.
+		MigrateOnStart bool
+		LogLevel       slog.Level
+		Tracing        tracing.Config
+		RateLimit      RateLimit
+
+		// Args are the positional arguments left after the flags, such as a
+		// subcommand.
+		Args []string
+	}
+
+	HTTP struct {
+		Port            int
+		ReadTimeout     time.Duration
+		WriteTimeout    time.Duration
+		IdleTimeout     time.Duration
+		ShutdownTimeout time.Duration
+		// DrainDelay is how long to keep serving, reporting not ready, before
+		// shutting down.
+		DrainDelay       time.Duration
+		ReadinessTimeout time.Duration
+		// CORSOrigins lists the origins allowed to call the API; "*" allows any.
+		CORSOrigins []string
+		// EventsHeartbeat and EventsPollInterval tune the wallet event
+		// streams, see handler.Config.
+		EventsHeartbeat    time.Duration
+		EventsPollInterval time.Duration
+		// MetricsPort is the internal port /metrics is served on; 0 doesn't
+		// serve metrics.
+		MetricsPort int
+	}
+
+	RateLimit struct {
+		// Store is one of the ratelimit Store constants.
+		Store string
+		// Client is the default limit of each API client, Wallet the limit
+		// of operations on each wallet. A zero rate disables a limit.
+		Client ratelimit.Limit
+		Wallet ratelimit.Limit
+	}
+
+	Reconcile struct {
+		// Interval is how often the server reconciles balances with the
+		// ledger; zero disables the job.
+		Interval time.Duration
+		// Stream and BatchSize are the models.ReconcileOptions of the job
+		// and the default ones of the reconcile subcommand.
+		Stream    bool
+		BatchSize int
+	}
+
+	Outbox struct {
+		// Interval is how often the relay publishes new balance change
+		// events; zero disables it.
+		Interval  time.Duration
+		BatchSize int
+		Publisher outbox.Config
+	}
+
+	Webhooks struct {
+		// Interval is how often the worker sends the webhook deliveries
+		// that are due; zero disables it.
+		Interval time.Duration
+		// Timeout bounds each attempt.
+		Timeout     time.Duration
+		MaxAttempts int
+		// Backoff is the delay after the first failed attempt; it doubles
+		// with every further one.
+		Backoff time.Duration
+		// AllowPrivate lets webhooks point to loopback, private and
+		// link-local addresses.
+		AllowPrivate bool
+	}
+
+	// DB is either a full URL or its parts. URL wins when both are set.
+	DB struct {
+		URL     string
+		Host    string
+		Port    string
+		User    string
+		Pass    string
+		Name    string
+		SSLMode string
+
+		// Zero values keep the pgxpool defaults.
+		MaxConns        int32
+		MinConns        int32
+		MaxConnLifetime time.Duration
+		MaxConnIdleTime time.Duration
+	}
+)
+
+// setting describes one configuration key and how it is applied to Config.
+type setting struct {
+	key   string
+	def   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"HTTP_PORT", "8000", "port to listen on", func(c *Config, v string) (err error) {
+		c.HTTP.Port, err = strconv.Atoi(v)
+		return err
+	}},
+	{"METRICS_PORT", "9090", "internal port to serve /metrics on, apart from the API; 0 disables it", func(c *Config, v string) (err error) {
+		c.HTTP.MetricsPort, err = strconv.Atoi(v)
+		return err
+	}},
+	{"HTTP_READ_TIMEOUT", "10s", "maximum duration for reading a request", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.ReadTimeout })},
+	{"HTTP_WRITE_TIMEOUT", "10s", "maximum duration for writing a response", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.WriteTimeout })},
+	{"HTTP_IDLE_TIMEOUT", "60s", "how long to keep idle keep-alive connections", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.IdleTimeout })},
+	{"SHUTDOWN_TIMEOUT", "30s", "how long the shutdown may take after the drain delay, in-flight requests and background work included", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.ShutdownTimeout })},
+	{"SHUTDOWN_DRAIN_DELAY", "0s", "how long to keep serving with /readyz failing before shutting down", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.DrainDelay })},
+	{"READINESS_TIMEOUT", "2s", "how long /readyz waits for the database", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.ReadinessTimeout })},
+	{"EVENTS_HEARTBEAT_INTERVAL", "15s", "how often wallet event streams send a heartbeat", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.EventsHeartbeat })},
+	{"EVENTS_POLL_INTERVAL", "2s", "how often wallet event streams check for events relayed by other instances", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.EventsPollInterval })},
+	{"CORS_ALLOWED_ORIGINS", "", "comma-separated list of allowed CORS origins; required, * allows any", func(c *Config, v string) error {
+		c.HTTP.CORSOrigins = nil
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.HTTP.CORSOrigins = append(c.HTTP.CORSOrigins, origin)
+			}
+		}
+		return nil
+	}},
+
+	{"DATABASE_URL", "", "full Postgres connection URL, overrides DB_* parts", stringSetter(func(c *Config) *string { return &c.DB.URL })},
+	{"DB_HOST", "", "Postgres host", stringSetter(func(c *Config) *string { return &c.DB.Host })},
+	{"DB_PORT", "5432", "Postgres port", stringSetter(func(c *Config) *string { return &c.DB.Port })},
+	{"DB_USER", "", "Postgres user", stringSetter(func(c *Config) *string { return &c.DB.User })},
+	{"DB_PASS", "", "Postgres password", stringSetter(func(c *Config) *string { return &c.DB.Pass })},
+	{"DB_NAME", "", "Postgres database", stringSetter(func(c *Config) *string { return &c.DB.Name })},
+	{"DB_SSLMODE", "disable", "Postgres sslmode", stringSetter(func(c *Config) *string { return &c.DB.SSLMode })},
+	{"DB_MAX_CONNS", "0", "maximum size of the connection pool", int32Setter(func(c *Config) *int32 { return &c.DB.MaxConns })},
+	{"DB_MIN_CONNS", "0", "minimum size of the connection pool", int32Setter(func(c *Config) *int32 { return &c.DB.MinConns })},
+	{"DB_MAX_CONN_LIFETIME", "0", "maximum lifetime of a pooled connection", durationSetter(func(c *Config) *time.Duration { return &c.DB.MaxConnLifetime })},
+	{"DB_MAX_CONN_IDLE_TIME", "0", "maximum idle time of a pooled connection", durationSetter(func(c *Config) *time.Duration { return &c.DB.MaxConnIdleTime })},
+
+	{"DEFAULT_CURRENCY", currency.Default, "currency of wallets created without one", func(c *Config, v string) error {
+		c.DefaultCurrency = currency.Normalize(v)
+		return nil
+	}},
+	{"HOLD_TTL", "24h", "how long a hold reserves funds", durationSetter(func(c *Config) *time.Duration { return &c.HoldTTL })},
+	{"BALANCE_SNAPSHOT_INTERVAL", "1h", "how often to snapshot balances for historical queries; 0 disables snapshots", durationSetter(func(c *Config) *time.Duration { return &c.BalanceSnapshotInterval })},
+	{"RECONCILE_INTERVAL", "24h", "how often to reconcile wallet balances with the ledger; 0 disables the job", durationSetter(func(c *Config) *time.Duration { return &c.Reconcile.Interval })},
+	{"RECONCILE_STREAM", "false", "reconcile wallets in batches instead of with a single query", func(c *Config, v string) (err error) {
+		c.Reconcile.Stream, err = strconv.ParseBool(v)
+		return err
+	}},
+	{"RECONCILE_BATCH_SIZE", "1000", "wallets per batch of a streaming reconciliation", intSetter(func(c *Config) *int { return &c.Reconcile.BatchSize })},
+	{"OUTBOX_RELAY_INTERVAL", "1s", "how often to publish new balance change events; 0 disables the relay", durationSetter(func(c *Config) *time.Duration { return &c.Outbox.Interval })},
+	{"OUTBOX_BATCH_SIZE", "100", "events the relay publishes per batch", intSetter(func(c *Config) *int { return &c.Outbox.BatchSize })},
+	{"OUTBOX_PUBLISHER", outbox.PublisherInProcess, "where to publish balance change events: inprocess, stdout or file", stringSetter(func(c *Config) *string { return &c.Outbox.Publisher.Publisher })},
+	{"OUTBOX_FILE", "events.jsonl", "file the file publisher appends events to", stringSetter(func(c *Config) *string { return &c.Outbox.Publisher.File })},
+	{"WEBHOOK_INTERVAL", "1s", "how often to send the webhook deliveries that are due; 0 disables webhook delivery", durationSetter(func(c *Config) *time.Duration { return &c.Webhooks.Interval })},
+	{"WEBHOOK_TIMEOUT", "10s", "how long to wait for a webhook receiver to answer", durationSetter(func(c *Config) *time.Duration { return &c.Webhooks.Timeout })},
+	{"WEBHOOK_MAX_ATTEMPTS", "10", "attempts at a webhook delivery before it fails for good", intSetter(func(c *Config) *int { return &c.Webhooks.MaxAttempts })},
+	{"WEBHOOK_BACKOFF", "30s", "delay after the first failed webhook attempt, doubled after each further one", durationSetter(func(c *Config) *time.Duration { return &c.Webhooks.Backoff })},
+	{"WEBHOOK_ALLOW_PRIVATE", "false", "allow webhooks to loopback, private and link-local addresses, for local development", func(c *Config, v string) (err error) {
+		c.Webhooks.AllowPrivate, err = strconv.ParseBool(v)
+		return err
+	}},
+	{"MIGRATE_ON_START", "false", "apply pending migrations before serving", func(c *Config, v string) (err error) {
+		c.MigrateOnStart, err = strconv.ParseBool(v)
+		return err
+	}},
+
+	{"LOG_LEVEL", "info", "minimum level of log lines: debug, info, warn or error", func(c *Config, v string) error {
+		return c.LogLevel.UnmarshalText([]byte(v))
+	}},
+	{"TRACING_EXPORTER", tracing.ExporterNone, "where to send traces: none, stdout, file or otlp", stringSetter(func(c *Config) *string { return &c.Tracing.Exporter })},
+	{"TRACING_FILE", "traces.json", "file the file trace exporter appends to", stringSetter(func(c *Config) *string { return &c.Tracing.File })},
+	{"TRACING_SAMPLE_RATIO", "1", "share of new traces to record, from 0 to 1", func(c *Config, v string) (err error) {
+		c.Tracing.SampleRatio, err = strconv.ParseFloat(v, 64)
+		return err
+	}},
+
+	{"RATE_LIMIT_STORE", ratelimit.StoreMemory, "where rate limits are counted: memory, per instance, or postgres, shared by all instances", stringSetter(func(c *Config) *string { return &c.RateLimit.Store })},
+	{"RATE_LIMIT_CLIENT_RATE", "50", "requests per second each API client may make; 0 disables the limit", floatSetter(func(c *Config) *float64 { return &c.RateLimit.Client.Rate })},
+	{"RATE_LIMIT_CLIENT_BURST", "100", "requests each API client may make at once", intSetter(func(c *Config) *int { return &c.RateLimit.Client.Burst })},
+	{"RATE_LIMIT_WALLET_RATE", "0", "operations per second on each wallet; 0, the default, disables the limit", floatSetter(func(c *Config) *float64 { return &c.RateLimit.Wallet.Rate })},
+	{"RATE_LIMIT_WALLET_BURST", "20", "operations on each wallet at once", intSetter(func(c *Config) *int { return &c.RateLimit.Wallet.Burst })},
+}
+
+func stringSetter(field func(c *Config) *string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func intSetter(field func(c *Config) *int) func(c *Config, v string) error {
+	return func(c *Config, v string) (err error) {
+		*field(c), err = strconv.Atoi(v)
+		return err
+	}
+}
+
+func floatSetter(field func(c *Config) *float64) func(c *Config, v string) error {
+	return func(c *Config, v string) (err error) {
+		*field(c), err = strconv.ParseFloat(v, 64)
+		return err
+	}
+}
+
+func int32Setter(field func(c *Config) *int32) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.ParseInt(v, 10, 32)
+		*field(c) = int32(n)
+		return err
+	}
+}
+
+func durationSetter(field func(c *Config) *time.Duration) func(c *Config, v string) error {
+	return func(c *Config, v string) (err error) {
+		*field(c), err = time.ParseDuration(v)
+		return err
+	}
+}
+
+// flagName turns HTTP_READ_TIMEOUT into http-read-timeout.
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// Load reads the configuration for the command line args (without the
+// program name) and the process environment.
+func Load(args []string) (Config, error) {
+	return load(args, os.LookupEnv, os.Stderr)
+}
+
+func load(args []string, lookupEnv func(string) (string, bool), output io.Writer) (Config, error) {
+	fs := flag.NewFlagSet("wallet-app", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	configFile := fs.String("config", "", "env file to read settings from (default "+DefaultFile+" if it exists)")
+	flags := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flags[s.key] = fs.String(flagName(s.key), s.def, s.usage+" ($"+s.key+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	values := make(map[string]string, len(settings))
+	for _, s := range settings {
+		values[s.key] = s.def
+	}
+
+	// Env file.
+	path, required := *configFile, true
+	if path == "" {
+		path, required = DefaultFile, false
+		if v, ok := lookupEnv("CONFIG_FILE"); ok && v != "" {
+			path, required = v, true
+		}
+	}
+	fileValues, err := godotenv.Read(path)
+	switch {
+	case err == nil:
+		if err := merge(values, func(key string) (string, bool) {
+			v, ok := fileValues[key]
+			return v, ok
+		}); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	case !required && errors.Is(err, os.ErrNotExist):
+	default:
+		return Config{}, fmt.Errorf("read config file: %w", err)
+	}
+
+	// Environment.
+	if err := merge(values, lookupEnv); err != nil {
+		return Config{}, fmt.Errorf("environment: %w", err)
+	}
+
+	// Flags, only those given explicitly.
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if f.Name == flagName(s.key) {
+				values[s.key] = *flags[s.key]
+			}
+		}
+	})
+
+	cfg := Config{Args: fs.Args()}
+	for _, s := range settings {
+		if err := s.set(&cfg, values[s.key]); err != nil {
+			return Config{}, fmt.Errorf("invalid %s %q: %w", s.key, values[s.key], err)
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// merge copies the known keys found by lookup into values. KEY_FILE is read
+// into KEY; setting both in the same source is an error.
+func merge(values map[string]string, lookup func(string) (string, bool)) error {
+	for _, s := range settings {
+		v, ok := lookup(s.key)
+		path, fromFile := lookup(s.key + "_FILE")
+		fromFile = fromFile && path != ""
+
+		switch {
+		case ok && fromFile:
+			return fmt.Errorf("both %s and %s_FILE are set", s.key, s.key)
+		case fromFile:
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("read %s_FILE: %w", s.key, err)
+			}
+			values[s.key] = strings.TrimRight(string(content), "\r\n")
+		case ok:
+			values[s.key] = v
+		}
+	}
+	return nil
+}
+
+// Validate checks that the settings are usable together.
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.HTTP.Port < 1 || c.HTTP.Port > 65535 {
+		errs = append(errs, fmt.Errorf("HTTP_PORT must be between 1 and 65535, got %d", c.HTTP.Port))
+	}
+	if c.HTTP.MetricsPort < 0 || c.HTTP.MetricsPort > 65535 {
+		errs = append(errs, fmt.Errorf("METRICS_PORT must be between 0 and 65535, got %d", c.HTTP.MetricsPort))
+	} else if c.HTTP.MetricsPort == c.HTTP.Port {
+		errs = append(errs, errors.New("METRICS_PORT must differ from HTTP_PORT"))
+	}
+	for key, d := range map[string]time.Duration{
+		"HTTP_READ_TIMEOUT":  c.HTTP.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT": c.HTTP.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":  c.HTTP.IdleTimeout,
+		"SHUTDOWN_TIMEOUT":   c.HTTP.ShutdownTimeout,
+		"READINESS_TIMEOUT":  c.HTTP.ReadinessTimeout,
+		"HOLD_TTL":           c.HoldTTL,
+
+		"EVENTS_HEARTBEAT_INTERVAL": c.HTTP.EventsHeartbeat,
+		"EVENTS_POLL_INTERVAL":      c.HTTP.EventsPollInterval,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", key))
+		}
+	}
+	if c.HTTP.DrainDelay < 0 {
+		errs = append(errs, errors.New("SHUTDOWN_DRAIN_DELAY can't be negative"))
+	}
+	if c.BalanceSnapshotInterval < 0 {
+		errs = append(errs, errors.New("BALANCE_SNAPSHOT_INTERVAL can't be negative"))
+	}
+	if c.Reconcile.Interval < 0 {
+		errs = append(errs, errors.New("RECONCILE_INTERVAL can't be negative"))
+	}
+	if c.Reconcile.BatchSize < 1 {
+		errs = append(errs, errors.New("RECONCILE_BATCH_SIZE must be positive"))
+	}
+	if c.Outbox.Interval < 0 {
+		errs = append(errs, errors.New("OUTBOX_RELAY_INTERVAL can't be negative"))
+	}
+	if c.Outbox.BatchSize < 1 {
+		errs = append(errs, errors.New("OUTBOX_BATCH_SIZE must be positive"))
+	}
+	if c.Webhooks.Interval < 0 {
+		errs = append(errs, errors.New("WEBHOOK_INTERVAL can't be negative"))
+	}
+	if c.Webhooks.Timeout <= 0 || c.Webhooks.Backoff <= 0 {
+		errs = append(errs, errors.New("WEBHOOK_TIMEOUT and WEBHOOK_BACKOFF must be positive"))
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, errors.New("WEBHOOK_MAX_ATTEMPTS must be positive"))
+	}
+	switch c.Outbox.Publisher.Publisher {
+	case outbox.PublisherInProcess, outbox.PublisherStdout:
+	case outbox.PublisherFile:
+		if c.Outbox.Publisher.File == "" {
+			errs = append(errs, errors.New("OUTBOX_FILE must be set for the file publisher"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown OUTBOX_PUBLISHER: %s", c.Outbox.Publisher.Publisher))
+	}
+	if len(c.HTTP.CORSOrigins) == 0 {
+		errs = append(errs, errors.New("CORS_ALLOWED_ORIGINS must be set"))
+	}
+
+	if c.DB.URL == "" && (c.DB.Host == "" || c.DB.User == "" || c.DB.Name == "") {
+		errs = append(errs, errors.New("either DATABASE_URL or DB_HOST, DB_USER and DB_NAME must be set"))
+	}
+	if c.DB.MaxConns < 0 || c.DB.MinConns < 0 {
+		errs = append(errs, errors.New("DB_MAX_CONNS and DB_MIN_CONNS can't be negative"))
+	}
+	if c.DB.MaxConns > 0 && c.DB.MinConns > c.DB.MaxConns {
+		errs = append(errs, errors.New("DB_MIN_CONNS can't be greater than DB_MAX_CONNS"))
+	}
+	if c.DB.MaxConnLifetime < 0 || c.DB.MaxConnIdleTime < 0 {
+		errs = append(errs, errors.New("DB_MAX_CONN_LIFETIME and DB_MAX_CONN_IDLE_TIME can't be negative"))
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	case tracing.ExporterFile:
+		if c.Tracing.File == "" {
+			errs = append(errs, errors.New("TRACING_FILE must be set for the file exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown TRACING_EXPORTER: %s", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("TRACING_SAMPLE_RATIO must be between 0 and 1"))
+	}
+
+	switch c.RateLimit.Store {
+	case ratelimit.StoreMemory, ratelimit.StorePostgres:
+	default:
+		errs = append(errs, fmt.Errorf("unknown RATE_LIMIT_STORE: %s", c.RateLimit.Store))
+	}
+	for scope, limit := range map[string]ratelimit.Limit{
+		"CLIENT": c.RateLimit.Client,
+		"WALLET": c.RateLimit.Wallet,
+	} {
+		if limit.Rate < 0 {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_%s_RATE can't be negative", scope))
+		}
+		if limit.Rate > 0 && limit.Burst < 1 {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_%s_BURST must be positive", scope))
+		}
+	}
+
+	if !currency.Valid(c.DefaultCurrency) {
+		errs = append(errs, fmt.Errorf("unsupported DEFAULT_CURRENCY: %s", c.DefaultCurrency))
+	}
+
+	return errors.Join(errs...)
+}
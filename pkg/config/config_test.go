@@ -0,0 +1,226 @@
+package config
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"wallet-app/pkg/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	cfg, err := load(nil, env(map[string]string{
+		"DATABASE_URL":         "postgres://localhost/wallet",
+		"CORS_ALLOWED_ORIGINS": "https://app.example",
+	}), io.Discard)
+	require.NoError(t, err)
+
+	assert.Equal(t, 8000, cfg.HTTP.Port)
+	assert.Equal(t, 9090, cfg.HTTP.MetricsPort)
+	assert.Equal(t, 10*time.Second, cfg.HTTP.ReadTimeout)
+	assert.Equal(t, 10*time.Second, cfg.HTTP.WriteTimeout)
+	assert.Equal(t, 60*time.Second, cfg.HTTP.IdleTimeout)
+	assert.Equal(t, 30*time.Second, cfg.HTTP.ShutdownTimeout)
+	assert.Equal(t, time.Duration(0), cfg.HTTP.DrainDelay)
+	assert.Equal(t, 2*time.Second, cfg.HTTP.ReadinessTimeout)
+	assert.Equal(t, 15*time.Second, cfg.HTTP.EventsHeartbeat)
+	assert.Equal(t, 2*time.Second, cfg.HTTP.EventsPollInterval)
+	assert.Equal(t, []string{"https://app.example"}, cfg.HTTP.CORSOrigins)
+	assert.Equal(t, "postgres://localhost/wallet", cfg.DB.URL)
+	assert.Equal(t, "5432", cfg.DB.Port)
+	assert.Equal(t, "disable", cfg.DB.SSLMode)
+	assert.Equal(t, int32(0), cfg.DB.MaxConns)
+	assert.Equal(t, "RUB", cfg.DefaultCurrency)
+	assert.Equal(t, 24*time.Hour, cfg.HoldTTL)
+	assert.Equal(t, time.Hour, cfg.BalanceSnapshotInterval)
+	assert.Equal(t, 24*time.Hour, cfg.Reconcile.Interval)
+	assert.False(t, cfg.Reconcile.Stream)
+	assert.Equal(t, 1000, cfg.Reconcile.BatchSize)
+	assert.Equal(t, time.Second, cfg.Outbox.Interval)
+	assert.Equal(t, 100, cfg.Outbox.BatchSize)
+	assert.Equal(t, "inprocess", cfg.Outbox.Publisher.Publisher)
+	assert.Equal(t, "events.jsonl", cfg.Outbox.Publisher.File)
+	assert.Equal(t, time.Second, cfg.Webhooks.Interval)
+	assert.Equal(t, 10*time.Second, cfg.Webhooks.Timeout)
+	assert.Equal(t, 10, cfg.Webhooks.MaxAttempts)
+	assert.Equal(t, 30*time.Second, cfg.Webhooks.Backoff)
+	assert.False(t, cfg.Webhooks.AllowPrivate)
+	assert.False(t, cfg.MigrateOnStart)
+	assert.Equal(t, slog.LevelInfo, cfg.LogLevel)
+	assert.Equal(t, "none", cfg.Tracing.Exporter)
+	assert.Equal(t, 1.0, cfg.Tracing.SampleRatio)
+	assert.Equal(t, "memory", cfg.RateLimit.Store)
+	assert.Equal(t, ratelimit.Limit{Rate: 50, Burst: 100}, cfg.RateLimit.Client)
+	assert.Equal(t, ratelimit.Limit{Rate: 0, Burst: 20}, cfg.RateLimit.Wallet)
+	assert.False(t, cfg.RateLimit.Wallet.Enabled(), "wallet limits are opt-in")
+	assert.Empty(t, cfg.Args)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	file := writeFile(t, "app.env", `
+HTTP_PORT=8001
+HTTP_READ_TIMEOUT=1s
+HTTP_WRITE_TIMEOUT=2s
+DB_HOST=file-host
+DB_USER=file-user
+DB_NAME=wallet
+`)
+
+	cfg, err := load(
+		[]string{"-config", file, "-http-port", "8003", "migrate", "up"},
+		env(map[string]string{
+			"HTTP_PORT":            "8002",
+			"HTTP_READ_TIMEOUT":    "3s",
+			"DB_HOST":              "env-host",
+			"CORS_ALLOWED_ORIGINS": "https://a.example, https://b.example",
+			"DB_MAX_CONNS":         "20",
+			"DB_MIN_CONNS":         "2",
+		}),
+		io.Discard,
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, 8003, cfg.HTTP.Port, "flag beats env and file")
+	assert.Equal(t, 3*time.Second, cfg.HTTP.ReadTimeout, "env beats file")
+	assert.Equal(t, 2*time.Second, cfg.HTTP.WriteTimeout, "file beats default")
+	assert.Equal(t, "env-host", cfg.DB.Host)
+	assert.Equal(t, "file-user", cfg.DB.User)
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, cfg.HTTP.CORSOrigins)
+	assert.Equal(t, int32(20), cfg.DB.MaxConns)
+	assert.Equal(t, int32(2), cfg.DB.MinConns)
+	assert.Equal(t, []string{"migrate", "up"}, cfg.Args)
+}
+
+func TestLoadDefaultFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, DefaultFile), []byte("DATABASE_URL=postgres://from-file/wallet\nCORS_ALLOWED_ORIGINS=*\n"), 0o600))
+
+	cfg, err := load(nil, env(nil), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://from-file/wallet", cfg.DB.URL)
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	_, err := load([]string{"-config", "missing.env"}, env(map[string]string{"DATABASE_URL": "postgres://localhost/wallet"}), io.Discard)
+	assert.Error(t, err, "a file asked for explicitly must exist")
+
+	_, err = load(nil, env(map[string]string{"CONFIG_FILE": "missing.env", "DATABASE_URL": "postgres://localhost/wallet"}), io.Discard)
+	assert.Error(t, err)
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	secret := writeFile(t, "db_pass", "s3cr3t\n")
+
+	cfg, err := load(nil, env(map[string]string{
+		"DB_HOST":      "localhost",
+		"DB_USER":      "wallet",
+		"DB_NAME":      "wallet",
+		"DB_PASS_FILE": secret,
+
+		"CORS_ALLOWED_ORIGINS": "*",
+	}), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", cfg.DB.Pass)
+
+	_, err = load(nil, env(map[string]string{
+		"DATABASE_URL":      "postgres://localhost/wallet",
+		"DB_PASS":           "plain",
+		"DB_PASS_FILE":      secret,
+		"DATABASE_URL_FILE": "",
+	}), io.Discard)
+	assert.Error(t, err, "KEY and KEY_FILE can't both be set")
+
+	_, err = load(nil, env(map[string]string{"DATABASE_URL_FILE": filepath.Join(t.TempDir(), "missing")}), io.Discard)
+	assert.Error(t, err)
+}
+
+func TestLoadValidation(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	base := map[string]string{"DATABASE_URL": "postgres://localhost/wallet", "CORS_ALLOWED_ORIGINS": "*"}
+	tests := map[string]map[string]string{
+		"no database":               {},
+		"bad port":                  {"HTTP_PORT": "70000"},
+		"not a number":              {"HTTP_PORT": "http"},
+		"bad metrics port":          {"METRICS_PORT": "-1"},
+		"metrics on the api port":   {"METRICS_PORT": "8000"},
+		"bad duration":              {"HTTP_READ_TIMEOUT": "soon"},
+		"zero timeout":              {"SHUTDOWN_TIMEOUT": "0s"},
+		"zero heartbeat":            {"EVENTS_HEARTBEAT_INTERVAL": "0s"},
+		"negative drain":            {"SHUTDOWN_DRAIN_DELAY": "-1s"},
+		"negative snapshots":        {"BALANCE_SNAPSHOT_INTERVAL": "-1h"},
+		"negative reconcile":        {"RECONCILE_INTERVAL": "-1h"},
+		"zero reconcile batch":      {"RECONCILE_BATCH_SIZE": "0"},
+		"negative outbox relay":     {"OUTBOX_RELAY_INTERVAL": "-1s"},
+		"zero outbox batch":         {"OUTBOX_BATCH_SIZE": "0"},
+		"unknown publisher":         {"OUTBOX_PUBLISHER": "kafka"},
+		"no outbox file":            {"OUTBOX_PUBLISHER": "file", "OUTBOX_FILE": ""},
+		"negative webhook interval": {"WEBHOOK_INTERVAL": "-1s"},
+		"zero webhook timeout":      {"WEBHOOK_TIMEOUT": "0s"},
+		"zero webhook backoff":      {"WEBHOOK_BACKOFF": "0s"},
+		"no webhook attempts":       {"WEBHOOK_MAX_ATTEMPTS": "0"},
+		"bad webhook allow private": {"WEBHOOK_ALLOW_PRIVATE": "maybe"},
+		"min above max conns":       {"DB_MAX_CONNS": "2", "DB_MIN_CONNS": "5"},
+		"negative conns":            {"DB_MAX_CONNS": "-1"},
+		"unknown currency":          {"DEFAULT_CURRENCY": "ABC"},
+		"no cors origins":           {"CORS_ALLOWED_ORIGINS": " , "},
+		"unset cors origins":        {"CORS_ALLOWED_ORIGINS": ""},
+		"bad bool":                  {"MIGRATE_ON_START": "maybe"},
+		"bad log level":             {"LOG_LEVEL": "verbose"},
+		"unknown exporter":          {"TRACING_EXPORTER": "jaeger"},
+		"no trace file":             {"TRACING_EXPORTER": "file", "TRACING_FILE": ""},
+		"bad sample ratio":          {"TRACING_SAMPLE_RATIO": "1.5"},
+		"unknown limit store":       {"RATE_LIMIT_STORE": "redis"},
+		"negative rate":             {"RATE_LIMIT_CLIENT_RATE": "-1"},
+		"no burst":                  {"RATE_LIMIT_WALLET_RATE": "10", "RATE_LIMIT_WALLET_BURST": "0"},
+	}
+
+	for name, values := range tests {
+		t.Run(name, func(t *testing.T) {
+			merged := map[string]string{}
+			if name != "no database" {
+				for k, v := range base {
+					merged[k] = v
+				}
+			}
+			for k, v := range values {
+				merged[k] = v
+			}
+
+			_, err := load(nil, env(merged), io.Discard)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := load([]string{"-no-such-flag"}, env(base), io.Discard)
+	assert.Error(t, err)
+}
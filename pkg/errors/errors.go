@@ -2,6 +2,7 @@ package custom_errors
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -9,4 +10,58 @@ import (
 var (
 	ErrNotEnoughFunds = errors.New("not enough funds")
 	ErrWalletNotFound = pgx.ErrNoRows
+	ErrSameWallet     = errors.New("can't transfer to the same wallet")
+	ErrWalletExists   = errors.New("wallet already exists")
+
+	ErrWalletFrozen            = errors.New("wallet is frozen")
+	ErrWalletClosed            = errors.New("wallet is closed")
+	ErrInvalidStatusTransition = errors.New("invalid wallet status transition")
+
+	ErrCurrencyMismatch    = errors.New("currency doesn't match wallet currency")
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+	ErrInvalidAmount   = errors.New("invalid amount")
+	ErrTooManyDecimals = errors.New("too many decimal places")
+	ErrAmountOverflow  = errors.New("amount is too large")
+
+	ErrNegativeBalance = errors.New("balance can't be negative")
+	ErrBalanceOverflow = errors.New("balance would exceed the maximum allowed value")
+
+	ErrHoldNotFound       = errors.New("hold not found")
+	ErrHoldNotActive      = errors.New("hold was already captured or released")
+	ErrHoldExpired        = errors.New("hold has expired")
+	ErrCaptureExceedsHold = errors.New("capture amount exceeds hold amount")
+
+	ErrIdempotentReplay       = errors.New("request was already processed")
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+
+	ErrUnauthorized   = errors.New("missing or invalid API key")
+	ErrForbidden      = errors.New("wallet belongs to another client")
+	ErrClientNotFound = errors.New("client not found")
+
+	ErrNotReady = errors.New("service is not ready")
+
+	ErrWalletRateLimited = errors.New("wallet rate limit exceeded")
+
+	ErrReconciliationNotFound = errors.New("no reconciliation has finished yet")
+
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrDeliveryPending  = errors.New("webhook delivery is still pending")
 )
+
+// BatchError is returned by all-or-nothing batches: the operation at Index
+// failed with Err, and no operation of the batch was applied.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type (
+	WalletStatusJSON struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+
+	WalletStatusResp struct {
+		WalletID  uuid.UUID `json:"walletId"`
+		From      string    `json:"from"`
+		To        string    `json:"to"`
+		Reason    string    `json:"reason"`
+		ChangedAt time.Time `json:"changedAt"`
+	}
+
+	ReconciliationResp struct {
+		ID            uuid.UUID `json:"id"`
+		Streaming     bool      `json:"streaming"`
+		StartedAt     time.Time `json:"startedAt"`
+		FinishedAt    time.Time `json:"finishedAt"`
+		Wallets       int64     `json:"wallets"`
+		Discrepancies int64     `json:"discrepancies"`
+	}
+)
+
+func (h *Handler) setWalletStatus(w http.ResponseWriter, r *http.Request) {
+	walletID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.sendError(w, fmt.Sprintf("wrong uuid: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var req WalletStatusJSON
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	status := strings.ToLower(req.Status)
+	switch status {
+	case models.WalletActive, models.WalletFrozen, models.WalletClosed:
+	default:
+		h.sendError(w, "wrong status", http.StatusBadRequest)
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		h.sendError(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	change, err := h.service.SetWalletStatus(r.Context(), walletID, status, reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrWalletNotFound):
+			h.sendError(w, "wallet not found", http.StatusNotFound)
+		case errors.Is(err, custom_errors.ErrInvalidStatusTransition):
+			h.sendError(w, err.Error(), http.StatusConflict)
+		default:
+			if !h.handleAccessError(w, err) {
+				h.sendInternalError(w, r, "internal server error", err)
+			}
+		}
+		return
+	}
+
+	h.sendJSON(w, WalletStatusResp{
+		WalletID:  change.WalletID,
+		From:      change.From,
+		To:        change.To,
+		Reason:    change.Reason,
+		ChangedAt: change.CreatedAt,
+	}, http.StatusOK)
+}
+
+// getReconciliation reports the latest finished reconciliation; the wallets
+// that didn't match are in its report table.
+func (h *Handler) getReconciliation(w http.ResponseWriter, r *http.Request) {
+	run, err := h.service.GetLatestReconciliation(r.Context())
+	if err != nil {
+		if errors.Is(err, custom_errors.ErrReconciliationNotFound) {
+			h.sendError(w, err.Error(), http.StatusNotFound)
+		} else if !h.handleAccessError(w, err) {
+			h.sendInternalError(w, r, "internal server error", err)
+		}
+		return
+	}
+
+	h.sendJSON(w, ReconciliationResp{
+		ID:            run.ID,
+		Streaming:     run.Streaming,
+		StartedAt:     run.StartedAt,
+		FinishedAt:    run.FinishedAt,
+		Wallets:       run.Wallets,
+		Discrepancies: run.Discrepancies,
+	}, http.StatusOK)
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+	"wallet-app/pkg/testutils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetWalletStatus(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		s := service.NewService(repo, service.Config{})
+		h := NewHandler(s)
+		ctx := context.Background()
+		walletID := uuid.New()
+
+		_ = repo.NewWallet(ctx, walletID, "RUB", 10000)
+
+		router := chi.NewRouter()
+		router.Post("/api/v1/wallet", h.updateWalletBalance)
+		router.Put("/api/v1/admin/wallets/{id}/status", h.setWalletStatus)
+
+		setStatus := func(id uuid.UUID, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/wallets/"+id.String()+"/status", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+
+		update := func(operation string) *httptest.ResponseRecorder {
+			body := `{"walletId":"` + walletID.String() + `", "operationType": "` + operation + `", "amount": "1.00"}`
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+
+		t.Run("bad request", func(t *testing.T) {
+			rr := setStatus(walletID, `{"status": "deleted", "reason": "test"}`)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+			rr = setStatus(walletID, `{"status": "frozen", "reason": " "}`)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+
+		t.Run("wallet not found", func(t *testing.T) {
+			rr := setStatus(uuid.New(), `{"status": "frozen", "reason": "test"}`)
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+		})
+
+		t.Run("frozen", func(t *testing.T) {
+			rr := setStatus(walletID, `{"status": "frozen", "reason": "card stolen"}`)
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			var resp WalletStatusResp
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+			assert.Equal(t, models.WalletActive, resp.From)
+			assert.Equal(t, models.WalletFrozen, resp.To)
+			assert.Equal(t, "card stolen", resp.Reason)
+
+			assert.Equal(t, http.StatusForbidden, update("withdraw").Code)
+			assert.Equal(t, http.StatusOK, update("deposit").Code)
+
+			rr = setStatus(walletID, `{"status": "closed", "reason": "test"}`)
+			assert.Equal(t, http.StatusConflict, rr.Code)
+
+			rr = setStatus(walletID, `{"status": "active", "reason": "card found"}`)
+			assert.Equal(t, http.StatusOK, rr.Code)
+		})
+
+		t.Run("closed", func(t *testing.T) {
+			rr := setStatus(walletID, `{"status": "closed", "reason": "closed by owner"}`)
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			assert.Equal(t, http.StatusConflict, update("withdraw").Code)
+			assert.Equal(t, http.StatusConflict, update("deposit").Code)
+		})
+	})
+}
+
+func TestGetReconciliation(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		s := service.NewService(repo, service.Config{})
+		router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+		admin := newAPIKey(t, s, true)
+
+		get := func(key string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/reconciliation", nil)
+			req.Header.Set("Authorization", "Bearer "+key)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+
+		require.NoError(t, repo.NewWallet(t.Context(), uuid.New(), "RUB", 10000))
+		run, err := s.Reconcile(t.Context(), models.ReconcileOptions{Stream: true, BatchSize: 2})
+		require.NoError(t, err)
+
+		rr := get(admin)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp ReconciliationResp
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		assert.Equal(t, run.ID, resp.ID)
+		assert.True(t, resp.Streaming)
+		assert.Positive(t, resp.Wallets)
+		assert.Zero(t, resp.Discrepancies)
+
+		assert.Equal(t, http.StatusForbidden, get(newAPIKey(t, s, false)).Code)
+	})
+
+	t.Run("none yet", func(t *testing.T) {
+		s := service.NewService(testutils.SetupTestMemory(t), service.Config{})
+		router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/reconciliation", nil)
+		req.Header.Set("Authorization", "Bearer "+newAPIKey(t, s, true))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestGetTrialBalance(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		s := service.NewService(repo, service.Config{})
+		router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+
+		get := func(key string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/trial-balance", nil)
+			req.Header.Set("Authorization", "Bearer "+key)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+
+		walletID := uuid.New()
+		require.NoError(t, repo.NewWallet(t.Context(), walletID, "RUB", 10000))
+		require.NoError(t, repo.Withdraw(t.Context(), walletID, "RUB", 2500))
+
+		rr := get(newAPIKey(t, s, true))
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp TrialBalanceResp
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		require.NotEmpty(t, resp.Currencies)
+		for _, c := range resp.Currencies {
+			assert.True(t, c.Balanced, c.Currency)
+			assert.Equal(t, c.TotalDebit, c.TotalCredit, c.Currency)
+		}
+
+		assert.Equal(t, http.StatusForbidden, get(newAPIKey(t, s, false)).Code)
+	})
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/service"
+	"wallet-app/pkg/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// apiKey returns the key sent as a bearer token or in X-API-Key.
+func apiKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, token, _ := strings.Cut(auth, " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return r.Header.Get(apiKeyHeader)
+}
+
+// authenticate rejects requests without a valid API key with 401 and makes
+// the rest of the request run as the key's client.
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := apiKey(r)
+		if key == "" {
+			h.sendUnauthorized(w)
+			return
+		}
+
+		client, err := h.service.Authenticate(r.Context(), key)
+		if errors.Is(err, custom_errors.ErrUnauthorized) {
+			h.sendUnauthorized(w)
+			return
+		}
+		if err != nil {
+			h.sendInternalError(w, r, "could not authenticate", err)
+			return
+		}
+
+		trace.SpanFromContext(r.Context()).SetAttributes(tracing.ClientIDKey.String(client.ID.String()))
+		next.ServeHTTP(w, r.WithContext(service.WithClient(r.Context(), client)))
+	})
+}
+
+// requireAdmin lets only admin clients through. It must run after
+// authenticate.
+func (h *Handler) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if client, ok := service.ClientFromContext(r.Context()); !ok || !client.Admin {
+			h.sendError(w, "admin access required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) sendUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="wallet-app"`)
+	h.sendError(w, custom_errors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+}
+
+// handleAccessError rejects operations on wallets of other clients. It
+// returns false if err is not about access.
+func (h *Handler) handleAccessError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, custom_errors.ErrForbidden) {
+		h.sendError(w, err.Error(), http.StatusForbidden)
+		return true
+	}
+	return false
+}
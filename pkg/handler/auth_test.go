@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+	"wallet-app/pkg/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAPIKey registers a client with s and returns its key.
+func newAPIKey(t *testing.T, s *service.Service, admin bool) string {
+	t.Helper()
+	_, key, err := s.NewClient(t.Context(), models.Client{Name: "test", Admin: admin})
+	require.NoError(t, err)
+	return key
+}
+
+func TestAuthentication(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		s := service.NewService(repo, service.Config{})
+		router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+
+		alice, bob, admin := newAPIKey(t, s, false), newAPIKey(t, s, false), newAPIKey(t, s, true)
+		aliceWallet, bobWallet := uuid.New(), uuid.New()
+
+		do := func(key, method, path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			if key != "" {
+				req.Header.Set("Authorization", "Bearer "+key)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+		deposit := func(key string, walletID uuid.UUID) *httptest.ResponseRecorder {
+			return do(key, http.MethodPost, "/api/v1/wallet",
+				`{"walletId":"`+walletID.String()+`","operationType":"deposit","amount":"10.00"}`)
+		}
+
+		require.Equal(t, http.StatusOK, deposit(alice, aliceWallet).Code)
+		require.Equal(t, http.StatusOK, deposit(bob, bobWallet).Code)
+
+		t.Run("missing or unknown key", func(t *testing.T) {
+			for _, key := range []string{"", "wk_unknown", "not-a-key"} {
+				rr := do(key, http.MethodGet, "/api/v1/wallets/"+aliceWallet.String(), "")
+				assert.Equal(t, http.StatusUnauthorized, rr.Code, "key %q", key)
+				assert.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+
+		t.Run("X-API-Key header", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+aliceWallet.String(), nil)
+			req.Header.Set("X-API-Key", alice)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusOK, rr.Code)
+		})
+
+		t.Run("wallets of other clients", func(t *testing.T) {
+			assert.Equal(t, http.StatusOK, do(alice, http.MethodGet, "/api/v1/wallets/"+aliceWallet.String(), "").Code)
+			assert.Equal(t, http.StatusForbidden, do(alice, http.MethodGet, "/api/v1/wallets/"+bobWallet.String(), "").Code)
+			assert.Equal(t, http.StatusForbidden, do(alice, http.MethodGet, "/api/v1/wallets/"+bobWallet.String()+"/transactions", "").Code)
+			assert.Equal(t, http.StatusForbidden, deposit(alice, bobWallet).Code)
+
+			rr := do(alice, http.MethodPost, "/api/v1/transfers",
+				`{"fromWalletId":"`+bobWallet.String()+`","toWalletId":"`+aliceWallet.String()+`","amount":"1.00"}`)
+			assert.Equal(t, http.StatusForbidden, rr.Code)
+
+			// Paying into another client's wallet is allowed.
+			rr = do(alice, http.MethodPost, "/api/v1/transfers",
+				`{"fromWalletId":"`+aliceWallet.String()+`","toWalletId":"`+bobWallet.String()+`","amount":"1.00"}`)
+			assert.Equal(t, http.StatusOK, rr.Code)
+		})
+
+		t.Run("currency of other clients' wallets", func(t *testing.T) {
+			yenWallet := uuid.New()
+			rr := do(bob, http.MethodPost, "/api/v1/wallet", `{"walletId":"`+yenWallet.String()+`","operationType":"deposit","amount":"100","currency":"JPY"}`)
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			// 1.00 has too many decimals for JPY, which alice mustn't learn.
+			for _, req := range []struct{ path, body string }{
+				{"/api/v1/wallet", `{"walletId":"` + yenWallet.String() + `","operationType":"deposit","amount":"1.00"}`},
+				{"/api/v1/wallet", `{"walletId":"` + yenWallet.String() + `","operationType":"withdraw","amount":"1.00"}`},
+				{"/api/v1/transfers", `{"fromWalletId":"` + yenWallet.String() + `","toWalletId":"` + aliceWallet.String() + `","amount":"1.00"}`},
+				{"/api/v1/wallets/" + yenWallet.String() + "/holds", `{"amount":"1.00"}`},
+			} {
+				rr := do(alice, http.MethodPost, req.path, req.body)
+				assert.Equal(t, http.StatusForbidden, rr.Code, req.body)
+				assert.NotContains(t, rr.Body.String(), "JPY")
+			}
+
+			rr = do(alice, http.MethodPost, "/api/v1/wallet/batch", `{"mode":"best_effort","operations":[{"walletId":"`+yenWallet.String()+`","operationType":"deposit","amount":"1.00"}]}`)
+			require.Equal(t, http.StatusOK, rr.Code)
+			assert.Contains(t, rr.Body.String(), "wallet belongs to another client")
+			assert.NotContains(t, rr.Body.String(), "JPY")
+		})
+
+		t.Run("admin", func(t *testing.T) {
+			path := "/api/v1/admin/wallets/" + bobWallet.String() + "/status"
+			body := `{"status":"frozen","reason":"test"}`
+
+			assert.Equal(t, http.StatusForbidden, do(bob, http.MethodPut, path, body).Code)
+			assert.Equal(t, http.StatusOK, do(admin, http.MethodGet, "/api/v1/wallets/"+bobWallet.String(), "").Code)
+			assert.Equal(t, http.StatusOK, do(admin, http.MethodPut, path, body).Code)
+		})
+
+		t.Run("revoked key", func(t *testing.T) {
+			client, key, err := s.NewClient(t.Context(), models.Client{Name: "revoked"})
+			require.NoError(t, err)
+			require.NoError(t, s.RevokeClient(t.Context(), client.ID))
+
+			assert.Equal(t, http.StatusUnauthorized, do(key, http.MethodGet, "/api/v1/wallets/"+aliceWallet.String(), "").Code)
+		})
+	})
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/money"
+	"wallet-app/pkg/service"
+
+	"github.com/google/uuid"
+)
+
+const (
+	batchModeAtomic     = "atomic"
+	batchModeBestEffort = "best_effort"
+
+	batchStatusSucceeded = "succeeded"
+	batchStatusFailed    = "failed"
+	batchStatusSkipped   = "skipped"
+
+	// maxBatchOperations bounds the rows an atomic batch keeps locked.
+	maxBatchOperations = 1000
+)
+
+type (
+	BatchJSON struct {
+		Mode       string             `json:"mode,omitempty"`
+		Operations []UpdateWalletJSON `json:"operations"`
+	}
+
+	BatchResp struct {
+		Mode    string          `json:"mode"`
+		Error   string          `json:"error,omitempty"`
+		Results []BatchItemResp `json:"results"`
+	}
+
+	BatchItemResp struct {
+		Index     int       `json:"index"`
+		WalletID  uuid.UUID `json:"walletId"`
+		Operation string    `json:"operationType"`
+		Status    string    `json:"status"`
+		Balance   string    `json:"balance,omitempty"`
+		Currency  string    `json:"currency,omitempty"`
+		Error     string    `json:"error,omitempty"`
+	}
+)
+
+func (h *Handler) batch(w http.ResponseWriter, r *http.Request) {
+	var req BatchJSON
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	switch req.Mode {
+	case "":
+		req.Mode = batchModeAtomic
+	case batchModeAtomic, batchModeBestEffort:
+	default:
+		h.sendError(w, "mode must be atomic or best_effort", http.StatusBadRequest)
+		return
+	}
+	if len(req.Operations) == 0 {
+		h.sendError(w, "operations must not be empty", http.StatusBadRequest)
+		return
+	}
+	if len(req.Operations) > maxBatchOperations {
+		h.sendError(w, fmt.Sprintf("at most %d operations are allowed", maxBatchOperations), http.StatusBadRequest)
+		return
+	}
+
+	ops, ok := h.parseBatchOperations(w, r, req.Operations)
+	if !ok {
+		return
+	}
+
+	ctx, ok := h.withIdempotencyKey(w, r, req, http.StatusOK, nil)
+	if !ok {
+		return
+	}
+	ctx = service.WithBatchResponse(ctx, func(results []models.BatchResult) ([]byte, error) {
+		var body bytes.Buffer
+		err := json.NewEncoder(&body).Encode(batchResponse(req.Mode, ops, results))
+		return body.Bytes(), err
+	})
+
+	results, err := h.service.Batch(ctx, ops, req.Mode == batchModeAtomic)
+	var batchErr *custom_errors.BatchError
+	switch {
+	case errors.As(err, &batchErr):
+		res := batchResponse(req.Mode, ops, nil)
+		res.Error = fmt.Sprintf("operation %d: %s", batchErr.Index, batchErrorMessage(batchErr.Err))
+		for i := range res.Results {
+			res.Results[i].Status = batchStatusSkipped
+		}
+		res.Results[batchErr.Index].Status = batchStatusFailed
+		res.Results[batchErr.Index].Error = batchErrorMessage(batchErr.Err)
+		h.sendJSON(w, res, http.StatusUnprocessableEntity)
+	case err != nil:
+		if !h.handleRateLimitError(w, err) && !h.handleIdempotencyError(w, r, err) {
+			h.sendInternalError(w, r, "internal server error", err)
+		}
+	default:
+		h.sendJSON(w, batchResponse(req.Mode, ops, results), http.StatusOK)
+	}
+}
+
+// parseBatchOperations validates the operations of a batch like single
+// operations are validated. It returns false if an error response was
+// already written.
+func (h *Handler) parseBatchOperations(w http.ResponseWriter, r *http.Request, items []UpdateWalletJSON) ([]models.BatchOperation, bool) {
+	// Wallets often appear several times in a batch, so look each up once.
+	currencies := make(map[string]string)
+	forbidden := make(map[uuid.UUID]bool)
+
+	ops := make([]models.BatchOperation, len(items))
+	for i, item := range items {
+		operation := strings.ToLower(item.Operation)
+		if operation != models.OperationDeposit && operation != models.OperationWithdraw {
+			h.sendError(w, fmt.Sprintf("operations[%d]: wrong operation type", i), http.StatusBadRequest)
+			return nil, false
+		}
+		if item.WalletID == uuid.Nil {
+			h.sendError(w, fmt.Sprintf("operations[%d]: walletId is required", i), http.StatusBadRequest)
+			return nil, false
+		}
+
+		requested, err := parseCurrency(item.Currency)
+		if err != nil {
+			h.sendError(w, fmt.Sprintf("operations[%d]: %v", i, err), http.StatusBadRequest)
+			return nil, false
+		}
+		if forbidden[item.WalletID] {
+			ops[i] = models.BatchOperation{WalletID: item.WalletID, Operation: operation, Currency: requested}
+			continue
+		}
+		cacheKey := item.WalletID.String() + requested
+		walletCurrency, ok := currencies[cacheKey]
+		if !ok {
+			walletCurrency, err = h.service.ResolveCurrency(r.Context(), item.WalletID, requested)
+			if errors.Is(err, custom_errors.ErrForbidden) {
+				// The service reports the operation as forbidden. Its amount
+				// is left unchecked, as checking it would need the currency
+				// of the wallet.
+				forbidden[item.WalletID] = true
+				ops[i] = models.BatchOperation{WalletID: item.WalletID, Operation: operation, Currency: requested}
+				continue
+			}
+			if err != nil {
+				h.sendInternalError(w, r, "internal server error", err)
+				return nil, false
+			}
+			currencies[cacheKey] = walletCurrency
+		}
+
+		amount, err := parseAmount(item.Amount, walletCurrency)
+		if err != nil {
+			h.sendError(w, fmt.Sprintf("operations[%d]: %v", i, err), http.StatusBadRequest)
+			return nil, false
+		}
+
+		ops[i] = models.BatchOperation{
+			WalletID:  item.WalletID,
+			Operation: operation,
+			Currency:  walletCurrency,
+			Amount:    amount,
+		}
+	}
+	return ops, true
+}
+
+// batchResponse reports the outcome of each of ops. results may be nil for
+// a batch that didn't run.
+func batchResponse(mode string, ops []models.BatchOperation, results []models.BatchResult) BatchResp {
+	res := BatchResp{Mode: mode, Results: make([]BatchItemResp, len(ops))}
+	for i, op := range ops {
+		item := BatchItemResp{Index: i, WalletID: op.WalletID, Operation: op.Operation}
+		if results != nil {
+			if err := results[i].Err; err != nil {
+				item.Status = batchStatusFailed
+				item.Error = batchErrorMessage(err)
+			} else {
+				item.Status = batchStatusSucceeded
+				item.Balance = money.Format(results[i].Balance, results[i].Currency)
+				item.Currency = results[i].Currency
+			}
+		}
+		res.Results[i] = item
+	}
+	return res
+}
+
+// batchErrorMessage is what a client is told about a failed operation.
+func batchErrorMessage(err error) string {
+	if errors.Is(err, custom_errors.ErrWalletNotFound) {
+		return "wallet not found"
+	}
+	return err.Error()
+}
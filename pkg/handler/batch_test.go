@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+	"wallet-app/pkg/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		s := service.NewService(repo, service.Config{})
+		router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+		key := newAPIKey(t, s, false)
+
+		do := func(body string, header ...string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet/batch", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+key)
+			for i := 0; i < len(header); i += 2 {
+				req.Header.Set(header[i], header[i+1])
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+		op := func(walletID uuid.UUID, kind, amount string) string {
+			return `{"walletId":"` + walletID.String() + `","operationType":"` + kind + `","amount":"` + amount + `"}`
+		}
+		decode := func(rr *httptest.ResponseRecorder) BatchResp {
+			var res BatchResp
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+			return res
+		}
+
+		a, b := uuid.New(), uuid.New()
+		rr := do(`{"operations":[` + op(a, "deposit", "100.00") + `,` + op(b, "deposit", "10.00") + `]}`)
+		require.Equal(t, http.StatusOK, rr.Code)
+		res := decode(rr)
+		assert.Equal(t, "atomic", res.Mode, "batches are atomic by default")
+		require.Len(t, res.Results, 2)
+		assert.Equal(t, BatchItemResp{Index: 0, WalletID: a, Operation: "deposit", Status: "succeeded", Balance: "100.00", Currency: "RUB"}, res.Results[0])
+		assert.Equal(t, "10.00", res.Results[1].Balance)
+
+		t.Run("atomic", func(t *testing.T) {
+			rr := do(`{"mode":"atomic","operations":[` + op(a, "withdraw", "30.00") + `,` + op(b, "withdraw", "20.00") + `]}`)
+			require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+			res := decode(rr)
+			assert.Equal(t, "operation 1: not enough funds", res.Error)
+			assert.Equal(t, "skipped", res.Results[0].Status)
+			assert.Equal(t, "failed", res.Results[1].Status)
+			assert.Equal(t, "not enough funds", res.Results[1].Error)
+
+			wallet, err := s.GetWallet(t.Context(), a)
+			require.NoError(t, err)
+			assert.Equal(t, int64(10000), wallet.Balance)
+		})
+
+		t.Run("best effort", func(t *testing.T) {
+			rr := do(`{"mode":"best_effort","operations":[` + op(a, "withdraw", "30.00") + `,` + op(b, "withdraw", "20.00") + `,` + op(a, "withdraw", "5.00") + `]}`)
+			require.Equal(t, http.StatusOK, rr.Code)
+			res := decode(rr)
+			assert.Equal(t, "succeeded", res.Results[0].Status)
+			assert.Equal(t, "70.00", res.Results[0].Balance)
+			assert.Equal(t, "failed", res.Results[1].Status)
+			assert.Equal(t, "not enough funds", res.Results[1].Error)
+			assert.Equal(t, "65.00", res.Results[2].Balance)
+		})
+
+		t.Run("wallets of other clients", func(t *testing.T) {
+			other := uuid.New()
+			require.NoError(t, repo.NewWallet(t.Context(), other, "RUB", 10000))
+
+			rr := do(`{"mode":"best_effort","operations":[` + op(other, "withdraw", "1.00") + `,` + op(a, "deposit", "5.00") + `]}`)
+			require.Equal(t, http.StatusOK, rr.Code)
+			res := decode(rr)
+			assert.Equal(t, "failed", res.Results[0].Status)
+			assert.Equal(t, "wallet belongs to another client", res.Results[0].Error)
+			assert.Equal(t, "70.00", res.Results[1].Balance)
+
+			rr = do(`{"operations":[` + op(a, "deposit", "5.00") + `,` + op(other, "withdraw", "1.00") + `]}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+			assert.Equal(t, "operation 1: wallet belongs to another client", decode(rr).Error)
+
+			body := `{"mode":"best_effort","operations":[` + op(other, "withdraw", "1.00") + `,` + op(a, "withdraw", "5.00") + `]}`
+			first := do(body, "Idempotency-Key", "mixed-1")
+			require.Equal(t, http.StatusOK, first.Code)
+			replay := do(body, "Idempotency-Key", "mixed-1")
+			require.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+			assert.JSONEq(t, first.Body.String(), replay.Body.String(), "the stored response has the denied operations too")
+		})
+
+		t.Run("idempotency key", func(t *testing.T) {
+			body := `{"mode":"best_effort","operations":[` + op(b, "withdraw", "100.00") + `,` + op(b, "deposit", "1.00") + `]}`
+			first := do(body, "Idempotency-Key", "payroll-1")
+			require.Equal(t, http.StatusOK, first.Code)
+
+			replay := do(body, "Idempotency-Key", "payroll-1")
+			require.Equal(t, http.StatusOK, replay.Code)
+			assert.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+			assert.JSONEq(t, first.Body.String(), replay.Body.String(), "the replay returns the per-item results")
+
+			wallet, err := s.GetWallet(t.Context(), b)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1100), wallet.Balance, "the batch is applied once")
+		})
+
+		t.Run("bad request", func(t *testing.T) {
+			for _, body := range []string{
+				`{"operations":[]}`,
+				`{"mode":"some","operations":[` + op(a, "deposit", "1.00") + `]}`,
+				`{"operations":[` + op(a, "deposit", "1.00") + `,` + op(a, "refund", "1.00") + `]}`,
+				`{"operations":[` + op(a, "deposit", "-1.00") + `]}`,
+				`{"operations":[` + op(uuid.Nil, "deposit", "1.00") + `]}`,
+				`{"operations":[` + strings.Repeat(op(a, "deposit", "1.00")+",", maxBatchOperations) + op(a, "deposit", "1.00") + `]}`,
+			} {
+				assert.Equal(t, http.StatusBadRequest, do(body).Code, body[:min(len(body), 80)])
+			}
+		})
+	})
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/money"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultEventsHeartbeat    = 15 * time.Second
+	DefaultEventsPollInterval = 2 * time.Second
+
+	lastEventIDHeader = "Last-Event-ID"
+	// eventsWriteTimeout bounds each write to an event stream, in place of
+	// the server write timeout that would cut the stream.
+	eventsWriteTimeout = 10 * time.Second
+	eventsBatchSize    = 100
+	// eventsRetry is how long, in milliseconds, browsers wait before
+	// reconnecting a dropped stream.
+	eventsRetry = 3000
+)
+
+// WalletEventJSON is the data of a wallet event sent to a stream.
+type WalletEventJSON struct {
+	Type      string    `json:"type"`
+	WalletID  uuid.UUID `json:"walletId"`
+	Amount    string    `json:"amount"`
+	Balance   string    `json:"balance"`
+	Currency  string    `json:"currency"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// walletEvents streams the balance changes of a wallet as Server-Sent
+// Events. A stream starts after the Last-Event-ID the client resumes from,
+// or else with the next change; it ends when the client disconnects or
+// shutdown starts.
+func (h *Handler) walletEvents(cfg Config) http.HandlerFunc {
+	heartbeat := cfg.EventsHeartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultEventsHeartbeat
+	}
+	pollInterval := cfg.EventsPollInterval
+	if pollInterval <= 0 {
+		pollInterval = DefaultEventsPollInterval
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		walletID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			h.sendError(w, fmt.Sprintf("wrong uuid: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		var lastID int64
+		resume := r.Header.Get(lastEventIDHeader)
+		if resume != "" {
+			lastID, err = strconv.ParseInt(resume, 10, 64)
+			if err != nil || lastID < 0 {
+				h.sendError(w, "Last-Event-ID must be an event ID", http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx := r.Context()
+		stream, err := h.service.WatchWallet(ctx, walletID)
+		if err != nil {
+			if errors.Is(err, custom_errors.ErrWalletNotFound) {
+				h.sendError(w, "wallet not found", http.StatusNotFound)
+			} else if !h.handleAccessError(w, err) {
+				h.sendInternalError(w, r, "could not stream events", err)
+			}
+			return
+		}
+		// Subscribed before reading the last ID, so no change is missed.
+		defer stream.Close()
+
+		if resume == "" {
+			lastID, err = stream.LastID(ctx)
+			if err != nil {
+				h.sendInternalError(w, r, "could not stream events", err)
+				return
+			}
+		}
+
+		rc := http.NewResponseController(w)
+		write := func(format string, args ...any) error {
+			// The deadline is extended before every write, so the stream
+			// outlives the server write timeout but a stuck client doesn't.
+			err := rc.SetWriteDeadline(time.Now().Add(eventsWriteTimeout))
+			if err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, format, args...); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		// sendNew sends the events after lastID, a batch at a time.
+		sendNew := func() error {
+			for {
+				events, err := stream.After(ctx, lastID, eventsBatchSize)
+				if err != nil {
+					return err
+				}
+				for _, e := range events {
+					if err := write("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, walletEventData(e)); err != nil {
+						return err
+					}
+					lastID = e.ID
+				}
+				if len(events) < eventsBatchSize {
+					return nil
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// Keeps reverse proxies such as nginx from buffering the stream.
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		err = write("retry: %d\n\n", eventsRetry)
+		if err == nil {
+			err = sendNew()
+		}
+
+		heartbeatTicker := time.NewTicker(heartbeat)
+		defer heartbeatTicker.Stop()
+		pollTicker := time.NewTicker(pollInterval)
+		defer pollTicker.Stop()
+
+		for err == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-cfg.ShuttingDown:
+				return
+			case <-stream.Wake:
+				err = sendNew()
+			case <-pollTicker.C:
+				err = sendNew()
+			case <-heartbeatTicker.C:
+				err = write(": heartbeat\n\n")
+			}
+		}
+		if ctx.Err() == nil {
+			slog.WarnContext(ctx, "wallet event stream failed", slog.String("wallet_id", walletID.String()), slog.Any("error", err))
+		}
+	}
+}
+
+func walletEventData(e models.Event) []byte {
+	data, _ := json.Marshal(WalletEventJSON{
+		Type:      e.Type,
+		WalletID:  e.WalletID,
+		Amount:    money.Format(e.Amount, e.Currency),
+		Balance:   money.Format(e.Balance, e.Currency),
+		Currency:  e.Currency,
+		CreatedAt: e.CreatedAt,
+	})
+	return data
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/server"
+	"wallet-app/pkg/service"
+	"wallet-app/pkg/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sseMessage struct {
+	id, event, data string
+	comment         bool
+}
+
+// readSSE sends the messages of an event stream to the returned channel,
+// which is closed when the stream ends.
+func readSSE(resp *http.Response) <-chan sseMessage {
+	messages := make(chan sseMessage, 100)
+	go func() {
+		defer close(messages)
+		var msg sseMessage
+		var fields bool
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if fields {
+					messages <- msg
+				}
+				msg, fields = sseMessage{}, false
+			case strings.HasPrefix(line, ":"):
+				msg.comment, fields = true, true
+			case strings.HasPrefix(line, "id: "):
+				msg.id, fields = strings.TrimPrefix(line, "id: "), true
+			case strings.HasPrefix(line, "event: "):
+				msg.event, fields = strings.TrimPrefix(line, "event: "), true
+			case strings.HasPrefix(line, "data: "):
+				msg.data, fields = strings.TrimPrefix(line, "data: "), true
+			}
+		}
+	}()
+	return messages
+}
+
+func TestWalletEvents(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		ctx, cancel := context.WithCancel(t.Context())
+		s := service.NewService(repo, service.Config{})
+		router := NewHandler(s).RegisterRoutes(Config{
+			AllowedOrigins:  []string{"*"},
+			ShuttingDown:    ctx.Done(),
+			EventsHeartbeat: 50 * time.Millisecond,
+			// Events reach streams only when relayed, through the bus.
+			EventsPollInterval: time.Hour,
+		})
+		alice, bob := newAPIKey(t, s, false), newAPIKey(t, s, false)
+
+		// A real server, so that streams are subject to its write timeout.
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		srv := server.New(server.Config{WriteTimeout: 100 * time.Millisecond, ShutdownTimeout: time.Second})
+		served := make(chan error, 1)
+		go func() {
+			served <- srv.Serve(ctx, l, router)
+		}()
+		defer func() {
+			cancel()
+			assert.NoError(t, <-served)
+		}()
+
+		walletID := uuid.New()
+		path := "/api/v1/wallets/" + walletID.String() + "/events"
+		deposit := func() {
+			t.Helper()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", strings.NewReader(`{"walletId":"`+walletID.String()+`","operationType":"deposit","amount":"10.00"}`))
+			req.Header.Set("Authorization", "Bearer "+alice)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusOK, rr.Code)
+		}
+		relay := func() {
+			t.Helper()
+			_, err := s.RelayOutbox(t.Context(), s.EventPublisher(), 0)
+			require.NoError(t, err)
+		}
+		open := func(ctx context.Context, key, lastEventID string) *http.Response {
+			t.Helper()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+l.Addr().String()+path, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+key)
+			if lastEventID != "" {
+				req.Header.Set("Last-Event-ID", lastEventID)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			return resp
+		}
+		next := func(messages <-chan sseMessage, comment bool) sseMessage {
+			t.Helper()
+			timeout := time.After(5 * time.Second)
+			for {
+				select {
+				case msg, ok := <-messages:
+					require.True(t, ok, "stream ended")
+					if msg.comment == comment {
+						return msg
+					}
+				case <-timeout:
+					require.FailNow(t, "no message")
+				}
+			}
+		}
+
+		t.Run("validation", func(t *testing.T) {
+			resp := open(t.Context(), alice, "")
+			resp.Body.Close()
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+			deposit()
+			relay()
+			resp = open(t.Context(), bob, "")
+			resp.Body.Close()
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+			resp = open(t.Context(), alice, "latest")
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+
+		var firstID, secondID string
+		t.Run("stream", func(t *testing.T) {
+			streamCtx, closeStream := context.WithCancel(t.Context())
+			defer closeStream()
+			resp := open(streamCtx, alice, "")
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+			messages := readSSE(resp)
+
+			next(messages, true)
+			// Outlive the write timeout of the server.
+			time.Sleep(200 * time.Millisecond)
+
+			deposit()
+			relay()
+			msg := next(messages, false)
+			assert.Equal(t, models.EventDeposited, msg.event)
+			var data WalletEventJSON
+			require.NoError(t, json.Unmarshal([]byte(msg.data), &data))
+			assert.Equal(t, walletID, data.WalletID)
+			assert.Equal(t, "10.00", data.Amount)
+			assert.Equal(t, "20.00", data.Balance)
+			assert.Equal(t, "RUB", data.Currency)
+			firstID = msg.id
+
+			deposit()
+			relay()
+			secondID = next(messages, false).id
+			assert.NotEqual(t, firstID, secondID)
+		})
+
+		t.Run("resume", func(t *testing.T) {
+			require.NotEmpty(t, firstID)
+
+			// Not relayed yet, but resuming reads the events missed.
+			deposit()
+			streamCtx, closeStream := context.WithCancel(t.Context())
+			defer closeStream()
+			resp := open(streamCtx, alice, firstID)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			messages := readSSE(resp)
+
+			assert.Equal(t, secondID, next(messages, false).id)
+			var data WalletEventJSON
+			require.NoError(t, json.Unmarshal([]byte(next(messages, false).data), &data))
+			assert.Equal(t, "40.00", data.Balance)
+		})
+
+		t.Run("shutdown", func(t *testing.T) {
+			resp := open(t.Context(), alice, "")
+			defer resp.Body.Close()
+			messages := readSSE(resp)
+			next(messages, true)
+
+			// Streams end when shutdown starts instead of holding it up.
+			cancel()
+			for range messages {
+			}
+		})
+	})
+}
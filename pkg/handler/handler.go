@@ -2,45 +2,119 @@ package handler
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
+	"time"
 	"wallet-app/pkg/middlewares"
 	"wallet-app/pkg/service"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Handler struct {
 	service *service.Service
 }
 
+type Config struct {
+	// AllowedOrigins lists the CORS origins allowed to call the API.
+	AllowedOrigins []string
+	// ReadinessTimeout bounds the checks behind /readyz.
+	ReadinessTimeout time.Duration
+	// ShuttingDown is closed when graceful shutdown starts; /readyz fails
+	// from then on. Nil means never.
+	ShuttingDown <-chan struct{}
+	// RateLimits limits the requests of each client and to each wallet. Nil
+	// disables rate limiting.
+	RateLimits *RateLimits
+	// EventsHeartbeat is how often wallet event streams send a comment to
+	// keep idle connections open.
+	EventsHeartbeat time.Duration
+	// EventsPollInterval is how often wallet event streams check for events
+	// relayed by other instances.
+	EventsPollInterval time.Duration
+}
+
 func NewHandler(service *service.Service) *Handler {
 	return &Handler{service: service}
 }
 
-func (h *Handler) RegisterRoutes() *chi.Mux {
+// MetricsRoutes serves /metrics. The metrics aren't scoped to a client, so
+// they are served on an internal listener of their own rather than with
+// the API.
+func MetricsRoutes() *chi.Mux {
+	r := chi.NewRouter()
+	r.Handle("/metrics", promhttp.Handler())
+	return r
+}
+
+func (h *Handler) RegisterRoutes(cfg Config) *chi.Mux {
 	r := chi.NewRouter()
 	r.Use(middleware.StripSlashes)
+	r.Use(middlewares.RequestIDMiddleware)
+	r.Use(middlewares.TracingMiddleware)
 	r.Use(middlewares.LoggingMiddleware)
+	r.Use(middlewares.MetricsMiddleware)
 
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
+		AllowedOrigins:   cfg.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", apiKeyHeader, "Content-Type", "X-CSRF-Token", idempotencyKeyHeader, middlewares.RequestIDHeader, "traceparent", "tracestate"},
+		ExposedHeaders:   []string{"Link", idempotentReplayedHeader, middlewares.RequestIDHeader, "Retry-After", rateLimitLimitHeader, rateLimitRemainingHeader, rateLimitResetHeader},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
 
+	r.Get("/healthz", h.healthz)
+	r.Get("/readyz", h.readyz(cfg))
+
 	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(limitBody)
+		r.Use(h.authenticate)
+		r.Use(h.limitClients(cfg.RateLimits))
+		r.Use(h.limitWallets(cfg.RateLimits))
+
 		r.Post("/wallet", h.updateWalletBalance)
+		r.Post("/wallet/batch", h.batch)
+		r.Post("/transfers", h.transfer)
 		r.Get("/wallets/{id}", h.getWalletInfo)
+		r.Get("/wallets/{id}/transactions", h.listTransactions)
+		r.Get("/wallets/{id}/events", h.walletEvents(cfg))
+		r.Post("/wallets/{id}/holds", h.authorizeHold)
+		r.Get("/holds/{id}", h.getHold)
+		r.Post("/holds/{id}/capture", h.captureHold)
+		r.Post("/holds/{id}/release", h.releaseHold)
+
+		r.Post("/webhooks", h.createWebhook)
+		r.Get("/webhooks", h.listWebhooks)
+		r.Delete("/webhooks/{id}", h.deleteWebhook)
+		r.Get("/webhooks/{id}/deliveries", h.listWebhookDeliveries)
+		r.Post("/webhooks/{id}/deliveries/{deliveryID}/redeliver", h.redeliverWebhook)
+
+		r.With(h.requireAdmin).Put("/admin/wallets/{id}/status", h.setWalletStatus)
+		r.With(h.requireAdmin).Get("/admin/reconciliation", h.getReconciliation)
+		r.With(h.requireAdmin).Get("/admin/trial-balance", h.getTrialBalance)
 	})
 
 	return r
 }
 
+// maxBodySize caps the request bodies of the API. The largest requests,
+// batches of maxBatchOperations operations, stay well below it.
+const maxBodySize = 1 << 20
+
+// limitBody caps the size of request bodies, so that no one can make the
+// server buffer an arbitrarily large body. Reading past the cap fails and
+// the request is rejected as malformed.
+func limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		next.ServeHTTP(w, r)
+	})
+}
+
 // Standard Responses
 
 func (h *Handler) sendError(w http.ResponseWriter, message string, status int) {
@@ -54,6 +128,13 @@ func (h *Handler) sendError(w http.ResponseWriter, message string, status int) {
 	json.NewEncoder(w).Encode(ErrorRes{message})
 }
 
+// sendInternalError logs the cause of a 5xx response, which the client is
+// not shown, and sends message instead.
+func (h *Handler) sendInternalError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	slog.ErrorContext(r.Context(), message, slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.Any("error", err))
+	h.sendError(w, message, http.StatusInternalServerError)
+}
+
 func (h *Handler) sendSuccess(w http.ResponseWriter, message string, status int) {
 
 	if message == "" {
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const DefaultReadinessTimeout = 2 * time.Second
+
+type HealthResp struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthz reports that the process is up and serving requests.
+func (h *Handler) healthz(w http.ResponseWriter, r *http.Request) {
+	h.sendJSON(w, HealthResp{Status: "ok"}, http.StatusOK)
+}
+
+// readyz reports whether the instance should receive traffic: the database
+// answers within the timeout, its schema is migrated and shutdown hasn't
+// started.
+func (h *Handler) readyz(cfg Config) http.HandlerFunc {
+	timeout := cfg.ReadinessTimeout
+	if timeout <= 0 {
+		timeout = DefaultReadinessTimeout
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-cfg.ShuttingDown:
+			h.sendJSON(w, HealthResp{Status: "unavailable", Error: "shutting down"}, http.StatusServiceUnavailable)
+			return
+		default:
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		if err := h.service.Ready(ctx); err != nil {
+			slog.WarnContext(r.Context(), "not ready", slog.Any("error", err))
+			h.sendJSON(w, HealthResp{Status: "unavailable", Error: err.Error()}, http.StatusServiceUnavailable)
+			return
+		}
+		h.sendJSON(w, HealthResp{Status: "ok"}, http.StatusOK)
+	}
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaDatabase reports a fixed ping result and schema version.
+type schemaDatabase struct {
+	repository.Database
+	pingErr error
+	version uint
+	dirty   bool
+}
+
+func (d schemaDatabase) Ping(context.Context) error {
+	return d.pingErr
+}
+
+func (d schemaDatabase) SchemaVersion(context.Context) (uint, bool, error) {
+	return d.version, d.dirty, nil
+}
+
+func TestHealthz(t *testing.T) {
+	s := service.NewService(repository.NewRepository(repository.NewMemory()), service.Config{})
+	router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestReadyz(t *testing.T) {
+	const expected = 20250901120000
+
+	tests := map[string]struct {
+		db     schemaDatabase
+		status int
+	}{
+		"ready":                 {schemaDatabase{version: expected}, http.StatusOK},
+		"newer schema":          {schemaDatabase{version: expected + 1}, http.StatusOK},
+		"database down":         {schemaDatabase{pingErr: errors.New("connection refused"), version: expected}, http.StatusServiceUnavailable},
+		"not migrated":          {schemaDatabase{version: expected - 1}, http.StatusServiceUnavailable},
+		"failed migration":      {schemaDatabase{version: expected, dirty: true}, http.StatusServiceUnavailable},
+		"never migrated at all": {schemaDatabase{}, http.StatusServiceUnavailable},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.db.Database = repository.NewMemory()
+			s := service.NewService(repository.NewRepository(tt.db), service.Config{SchemaVersion: expected})
+			router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tt.status, rr.Code)
+
+			var resp HealthResp
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+			if tt.status == http.StatusOK {
+				assert.Equal(t, "ok", resp.Status)
+			} else {
+				assert.Equal(t, "unavailable", resp.Status)
+				assert.NotEmpty(t, resp.Error)
+			}
+		})
+	}
+
+	t.Run("shutting down", func(t *testing.T) {
+		shuttingDown := make(chan struct{})
+		s := service.NewService(repository.NewRepository(repository.NewMemory()), service.Config{})
+		router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}, ShuttingDown: shuttingDown})
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		close(shuttingDown)
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	})
+}
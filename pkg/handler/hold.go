@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/money"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type (
+	HoldJSON struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency,omitempty"`
+	}
+
+	// CaptureJSON's Amount may be left empty to capture the whole hold.
+	CaptureJSON struct {
+		Amount string `json:"amount,omitempty"`
+	}
+
+	HoldResp struct {
+		ID             uuid.UUID `json:"id"`
+		WalletID       uuid.UUID `json:"walletId"`
+		Amount         string    `json:"amount"`
+		CapturedAmount string    `json:"capturedAmount"`
+		Currency       string    `json:"currency"`
+		Status         string    `json:"status"`
+		CreatedAt      time.Time `json:"createdAt"`
+		ExpiresAt      time.Time `json:"expiresAt"`
+	}
+)
+
+func newHoldResp(hold models.Hold) HoldResp {
+	return HoldResp{
+		ID:             hold.ID,
+		WalletID:       hold.WalletID,
+		Amount:         money.Format(hold.Amount, hold.Currency),
+		CapturedAmount: money.Format(hold.CapturedAmount, hold.Currency),
+		Currency:       hold.Currency,
+		Status:         hold.Status,
+		CreatedAt:      hold.CreatedAt,
+		ExpiresAt:      hold.ExpiresAt,
+	}
+}
+
+func (h *Handler) authorizeHold(w http.ResponseWriter, r *http.Request) {
+	walletID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.sendError(w, fmt.Sprintf("wrong uuid: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var req HoldJSON
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	holdCurrency, err := parseCurrency(req.Currency)
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	holdCurrency, err = h.service.ResolveCurrency(r.Context(), walletID, holdCurrency)
+	if err != nil {
+		if !h.handleAccessError(w, err) {
+			h.sendInternalError(w, r, "internal server error", err)
+		}
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, holdCurrency)
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if amount == 0 {
+		h.sendError(w, "amount must be positive", http.StatusBadRequest)
+		return
+	}
+
+	hold := h.service.NewHold(walletID, holdCurrency, amount)
+	res := newHoldResp(hold)
+
+	ctx, ok := h.withIdempotencyKey(w, r, req, http.StatusCreated, res)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Authorize(ctx, hold); err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrWalletNotFound):
+			h.sendError(w, "wallet not found", http.StatusNotFound)
+		case errors.Is(err, custom_errors.ErrNotEnoughFunds), errors.Is(err, custom_errors.ErrCurrencyMismatch):
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+		default:
+			if !h.handleAccessError(w, err) && !h.handleRateLimitError(w, err) && !h.handleWalletStatusError(w, err) && !h.handleIdempotencyError(w, r, err) {
+				h.sendInternalError(w, r, "internal server error", err)
+			}
+		}
+		return
+	}
+	h.sendJSON(w, res, http.StatusCreated)
+}
+
+func (h *Handler) getHold(w http.ResponseWriter, r *http.Request) {
+	holdID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.sendError(w, fmt.Sprintf("wrong uuid: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	hold, err := h.service.GetHold(r.Context(), holdID)
+	if err != nil {
+		if errors.Is(err, custom_errors.ErrHoldNotFound) {
+			h.sendError(w, err.Error(), http.StatusNotFound)
+		} else if !h.handleAccessError(w, err) {
+			h.sendInternalError(w, r, "could not get hold", err)
+		}
+		return
+	}
+
+	h.sendJSON(w, newHoldResp(hold), http.StatusOK)
+}
+
+func (h *Handler) captureHold(w http.ResponseWriter, r *http.Request) {
+	holdID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.sendError(w, fmt.Sprintf("wrong uuid: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var req CaptureJSON
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	hold, err := h.service.GetHold(r.Context(), holdID)
+	if err != nil {
+		if errors.Is(err, custom_errors.ErrHoldNotFound) {
+			h.sendError(w, err.Error(), http.StatusNotFound)
+		} else if !h.handleAccessError(w, err) {
+			h.sendInternalError(w, r, "could not get hold", err)
+		}
+		return
+	}
+
+	var amount int64
+	if req.Amount != "" {
+		amount, err = parseAmount(req.Amount, hold.Currency)
+		if err != nil {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if amount == 0 {
+			h.sendError(w, "amount must be positive", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, ok := h.withIdempotencyKey(w, r, req, http.StatusOK, SuccessRes{"hold captured"})
+	if !ok {
+		return
+	}
+
+	if err := h.service.Capture(ctx, holdID, amount); err != nil {
+		h.sendHoldError(w, r, err)
+		return
+	}
+	h.sendSuccess(w, "hold captured", http.StatusOK)
+}
+
+func (h *Handler) releaseHold(w http.ResponseWriter, r *http.Request) {
+	holdID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.sendError(w, fmt.Sprintf("wrong uuid: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx, ok := h.withIdempotencyKey(w, r, struct{}{}, http.StatusOK, SuccessRes{"hold released"})
+	if !ok {
+		return
+	}
+
+	if err := h.service.Release(ctx, holdID); err != nil {
+		h.sendHoldError(w, r, err)
+		return
+	}
+	h.sendSuccess(w, "hold released", http.StatusOK)
+}
+
+func (h *Handler) sendHoldError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, custom_errors.ErrHoldNotFound):
+		h.sendError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, custom_errors.ErrHoldNotActive), errors.Is(err, custom_errors.ErrHoldExpired):
+		h.sendError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, custom_errors.ErrCaptureExceedsHold), errors.Is(err, custom_errors.ErrNotEnoughFunds):
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+	default:
+		if !h.handleAccessError(w, err) && !h.handleRateLimitError(w, err) && !h.handleWalletStatusError(w, err) && !h.handleIdempotencyError(w, r, err) {
+			h.sendInternalError(w, r, "internal server error", err)
+		}
+	}
+}
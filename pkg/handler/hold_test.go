@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+	"wallet-app/pkg/testutils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHolds(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		s := service.NewService(repo, service.Config{})
+		h := NewHandler(s)
+		ctx := context.Background()
+		walletID := uuid.New()
+
+		_ = repo.NewWallet(ctx, walletID, "RUB", 10000)
+
+		router := chi.NewRouter()
+		router.Post("/api/v1/wallet", h.updateWalletBalance)
+		router.Get("/api/v1/wallets/{id}", h.getWalletInfo)
+		router.Post("/api/v1/wallets/{id}/holds", h.authorizeHold)
+		router.Get("/api/v1/holds/{id}", h.getHold)
+		router.Post("/api/v1/holds/{id}/capture", h.captureHold)
+		router.Post("/api/v1/holds/{id}/release", h.releaseHold)
+
+		do := func(method, url, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, url, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+
+		authorize := func(amount string) HoldResp {
+			rr := do(http.MethodPost, "/api/v1/wallets/"+walletID.String()+"/holds", `{"amount":"`+amount+`"}`)
+			assert.Equal(t, http.StatusCreated, rr.Code)
+
+			var resp HoldResp
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+			return resp
+		}
+
+		walletInfo := func() WalletResp {
+			rr := do(http.MethodGet, "/api/v1/wallets/"+walletID.String(), "")
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			var resp WalletResp
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+			return resp
+		}
+
+		t.Run("authorize and capture part", func(t *testing.T) {
+			hold := authorize("60.00")
+			assert.Equal(t, models.HoldActive, hold.Status)
+			assert.Equal(t, "60.00", hold.Amount)
+			assert.Equal(t, "RUB", hold.Currency)
+
+			wallet := walletInfo()
+			assert.Equal(t, "100.00", wallet.Balance)
+			assert.Equal(t, "40.00", wallet.AvailableBalance)
+
+			rr := do(http.MethodPost, "/api/v1/wallet",
+				`{"walletId":"`+walletID.String()+`","operationType":"WITHDRAW","amount":"50.00"}`)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+			rr = do(http.MethodPost, "/api/v1/holds/"+hold.ID.String()+"/capture", `{"amount":"70.00"}`)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+			rr = do(http.MethodPost, "/api/v1/holds/"+hold.ID.String()+"/capture", `{"amount":"25.00"}`)
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			rr = do(http.MethodGet, "/api/v1/holds/"+hold.ID.String(), "")
+			assert.Equal(t, http.StatusOK, rr.Code)
+			var resp HoldResp
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+			assert.Equal(t, models.HoldCaptured, resp.Status)
+			assert.Equal(t, "25.00", resp.CapturedAmount)
+
+			wallet = walletInfo()
+			assert.Equal(t, "75.00", wallet.Balance)
+			assert.Equal(t, "75.00", wallet.AvailableBalance)
+		})
+
+		t.Run("capture whole hold", func(t *testing.T) {
+			hold := authorize("5.00")
+
+			rr := do(http.MethodPost, "/api/v1/holds/"+hold.ID.String()+"/capture", `{}`)
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			assert.Equal(t, "70.00", walletInfo().Balance)
+		})
+
+		t.Run("release", func(t *testing.T) {
+			hold := authorize("70.00")
+			assert.Equal(t, "0.00", walletInfo().AvailableBalance)
+
+			rr := do(http.MethodPost, "/api/v1/holds/"+hold.ID.String()+"/release", "")
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "70.00", walletInfo().AvailableBalance)
+
+			rr = do(http.MethodPost, "/api/v1/holds/"+hold.ID.String()+"/release", "")
+			assert.Equal(t, http.StatusConflict, rr.Code)
+
+			rr = do(http.MethodPost, "/api/v1/holds/"+hold.ID.String()+"/capture", `{}`)
+			assert.Equal(t, http.StatusConflict, rr.Code)
+		})
+
+		t.Run("not enough available funds", func(t *testing.T) {
+			rr := do(http.MethodPost, "/api/v1/wallets/"+walletID.String()+"/holds", `{"amount":"70.01"}`)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			rr := do(http.MethodPost, "/api/v1/wallets/"+uuid.NewString()+"/holds", `{"amount":"1.00"}`)
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+
+			rr = do(http.MethodPost, "/api/v1/holds/"+uuid.NewString()+"/release", "")
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+
+			rr = do(http.MethodGet, "/api/v1/holds/"+uuid.NewString(), "")
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+		})
+	})
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/service"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	errIdempotencyKeyTooLarge = "Idempotency-Key must be at most 255 characters"
+)
+
+// withIdempotencyKey attaches the request's Idempotency-Key, if any, to ctx
+// together with a fingerprint of req and the response that a successful call
+// sends back. It returns false if an error response was already written.
+func (h *Handler) withIdempotencyKey(w http.ResponseWriter, r *http.Request, req any, status int, response any) (context.Context, bool) {
+	ctx := r.Context()
+
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		return ctx, true
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		h.sendError(w, errIdempotencyKeyTooLarge, http.StatusBadRequest)
+		return ctx, false
+	}
+
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		h.sendError(w, "bad request", http.StatusBadRequest)
+		return ctx, false
+	}
+	fingerprint := sha256.Sum256([]byte(r.Method + " " + r.URL.Path + "\n" + string(reqJSON)))
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(response); err != nil {
+		h.sendInternalError(w, r, "internal server error", err)
+		return ctx, false
+	}
+
+	return service.WithIdempotencyKey(ctx, models.IdempotencyKey{
+		Key:         scopedIdempotencyKey(r),
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+		StatusCode:  status,
+		Response:    body.Bytes(),
+	}), true
+}
+
+// scopedIdempotencyKey prefixes the request's key with its client, so that
+// clients can't collide with, or replay, each other's keys.
+func scopedIdempotencyKey(r *http.Request) string {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if client, ok := service.ClientFromContext(r.Context()); ok {
+		return client.ID.String() + ":" + key
+	}
+	return key
+}
+
+// handleIdempotencyError replays the stored response or rejects a reused key.
+// It returns false if err is not related to idempotency.
+func (h *Handler) handleIdempotencyError(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case errors.Is(err, custom_errors.ErrIdempotencyKeyReused):
+		h.sendError(w, err.Error(), http.StatusUnprocessableEntity)
+		return true
+	case errors.Is(err, custom_errors.ErrIdempotentReplay):
+		stored, err := h.service.GetIdempotencyKey(r.Context(), scopedIdempotencyKey(r))
+		if err != nil {
+			h.sendInternalError(w, r, "internal server error", err)
+			return true
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(idempotentReplayedHeader, "true")
+		w.WriteHeader(stored.StatusCode)
+		w.Write(stored.Response)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+	"wallet-app/pkg/testutils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		s := service.NewService(repo, service.Config{})
+		h := NewHandler(s)
+		ctx := context.Background()
+		walletID := uuid.New()
+
+		_ = repo.NewWallet(ctx, walletID, "RUB", 0)
+
+		router := chi.NewRouter()
+		router.Post("/api/v1/wallet", h.updateWalletBalance)
+
+		deposit := func(key, amount string) *httptest.ResponseRecorder {
+			body := fmt.Sprintf(`{"walletId":"%s", "operationType": "deposit", "amount": "%s"}`, walletID, amount)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(idempotencyKeyHeader, key)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+
+		t.Run("replay returns stored response", func(t *testing.T) {
+			key := uuid.NewString()
+
+			first := deposit(key, "10.00")
+			assert.Equal(t, http.StatusOK, first.Code)
+
+			second := deposit(key, "10.00")
+			assert.Equal(t, http.StatusOK, second.Code)
+			assert.Equal(t, first.Body.String(), second.Body.String())
+			assert.Equal(t, "true", second.Header().Get(idempotentReplayedHeader))
+
+			balance, err := repo.GetBalance(ctx, walletID)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1000), balance)
+		})
+
+		t.Run("reused key with different payload", func(t *testing.T) {
+			key := uuid.NewString()
+
+			rr := deposit(key, "1.00")
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			rr = deposit(key, "2.00")
+			assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		})
+
+		t.Run("concurrent retries apply once", func(t *testing.T) {
+			key := uuid.NewString()
+			before, err := repo.GetBalance(ctx, walletID)
+			assert.NoError(t, err)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					rr := deposit(key, "5.00")
+					assert.Equal(t, http.StatusOK, rr.Code)
+				}()
+			}
+			wg.Wait()
+
+			after, err := repo.GetBalance(ctx, walletID)
+			assert.NoError(t, err)
+			assert.Equal(t, before+500, after)
+		})
+	})
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"net/http"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/money"
+)
+
+type (
+	TrialBalanceResp struct {
+		Currencies []TrialBalanceCurrencyResp `json:"currencies"`
+	}
+
+	// TrialBalanceCurrencyResp is the trial balance of one currency. The
+	// ledger is consistent when its debits equal its credits.
+	TrialBalanceCurrencyResp struct {
+		Currency    string                    `json:"currency"`
+		Accounts    []TrialBalanceAccountResp `json:"accounts"`
+		TotalDebit  string                    `json:"totalDebit"`
+		TotalCredit string                    `json:"totalCredit"`
+		Balanced    bool                      `json:"balanced"`
+	}
+
+	TrialBalanceAccountResp struct {
+		Account string `json:"account"`
+		Debit   string `json:"debit"`
+		Credit  string `json:"credit"`
+	}
+)
+
+func (h *Handler) getTrialBalance(w http.ResponseWriter, r *http.Request) {
+	lines, err := h.service.TrialBalance(r.Context())
+	if err != nil {
+		if !h.handleAccessError(w, err) {
+			h.sendInternalError(w, r, "internal server error", err)
+		}
+		return
+	}
+
+	h.sendJSON(w, trialBalanceResponse(lines), http.StatusOK)
+}
+
+// trialBalanceResponse groups lines, which are ordered by currency, by
+// currency.
+func trialBalanceResponse(lines []models.TrialBalanceLine) TrialBalanceResp {
+	res := TrialBalanceResp{Currencies: []TrialBalanceCurrencyResp{}}
+	for i := 0; i < len(lines); {
+		currency := lines[i].Currency
+		group := TrialBalanceCurrencyResp{Currency: currency}
+		var debit, credit int64
+		for ; i < len(lines) && lines[i].Currency == currency; i++ {
+			group.Accounts = append(group.Accounts, TrialBalanceAccountResp{
+				Account: lines[i].Account,
+				Debit:   money.Format(lines[i].Debit, currency),
+				Credit:  money.Format(lines[i].Credit, currency),
+			})
+			debit += lines[i].Debit
+			credit += lines[i].Credit
+		}
+		group.TotalDebit = money.Format(debit, currency)
+		group.TotalCredit = money.Format(credit, currency)
+		group.Balanced = debit == credit
+		res.Currencies = append(res.Currencies, group)
+	}
+	return res
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wallet-app/pkg/logging"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenDatabase fails every wallet lookup.
+type brokenDatabase struct {
+	repository.Database
+}
+
+func (brokenDatabase) GetWallet(context.Context, uuid.UUID) (models.Wallet, error) {
+	return models.Wallet{}, errors.New("connection refused")
+}
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(logging.New(&buf, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestRequestID(t *testing.T) {
+	captureLogs(t)
+	s := service.NewService(repository.NewRepository(repository.NewMemory()), service.Config{})
+	router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+
+	t.Run("taken from the request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+uuid.NewString(), nil)
+		req.Header.Set("X-Request-ID", "abc-123")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, "abc-123", rr.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		for _, sent := range []string{"", "with space", strings.Repeat("x", 129)} {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+uuid.NewString(), nil)
+			req.Header.Set("X-Request-ID", sent)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			_, err := uuid.Parse(rr.Header().Get("X-Request-ID"))
+			assert.NoError(t, err, "sent %q", sent)
+		}
+	})
+}
+
+func TestServerErrorsAreLogged(t *testing.T) {
+	logs := captureLogs(t)
+	repo := repository.NewRepository(brokenDatabase{repository.NewMemory()})
+	s := service.NewService(repo, service.Config{})
+	router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+uuid.NewString(), nil)
+	req.Header.Set("Authorization", "Bearer "+newAPIKey(t, s, false))
+	req.Header.Set("X-Request-ID", "req-500")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "connection refused", "the cause is logged, not sent")
+
+	var cause, access map[string]any
+	for _, line := range logLines(t, logs) {
+		switch line["msg"] {
+		case "could not get balance":
+			cause = line
+		case "request":
+			access = line
+		}
+	}
+
+	require.NotNil(t, cause)
+	assert.Equal(t, "ERROR", cause["level"])
+	assert.Contains(t, cause["error"], "connection refused")
+	assert.Equal(t, "req-500", cause["request_id"])
+
+	require.NotNil(t, access)
+	assert.Equal(t, "ERROR", access["level"])
+	assert.Equal(t, "/api/v1/wallets/{id}", access["route"])
+	assert.Equal(t, float64(http.StatusInternalServerError), access["status"])
+	assert.Equal(t, "req-500", access["request_id"])
+}
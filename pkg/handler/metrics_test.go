@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wallet-app/pkg/metrics"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+	"wallet-app/pkg/testutils"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		s := service.NewService(repo, service.Config{})
+		router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+		key := newAPIKey(t, s, false)
+		walletID := uuid.New()
+
+		do := func(method, path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+key)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+		operation := func(kind, amount string) *httptest.ResponseRecorder {
+			return do(http.MethodPost, "/api/v1/wallet",
+				`{"walletId":"`+walletID.String()+`","operationType":"`+kind+`","amount":"`+amount+`","currency":"USD"}`)
+		}
+
+		deposits := testutil.ToFloat64(metrics.Operations.WithLabelValues(metrics.OperationDeposit))
+		depositedUSD := testutil.ToFloat64(metrics.AmountMoved.WithLabelValues(metrics.OperationDeposit, "USD"))
+		withdrawals := testutil.ToFloat64(metrics.Operations.WithLabelValues(metrics.OperationWithdraw))
+		withdrawnUSD := testutil.ToFloat64(metrics.AmountMoved.WithLabelValues(metrics.OperationWithdraw, "USD"))
+		rejected := testutil.ToFloat64(metrics.InsufficientFunds.WithLabelValues(metrics.OperationWithdraw))
+		walletGets := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "/api/v1/wallets/{id}", "200"))
+
+		require.Equal(t, http.StatusOK, operation("deposit", "10.00").Code)
+		require.Equal(t, http.StatusOK, operation("deposit", "5.50").Code)
+		require.Equal(t, http.StatusOK, operation("withdraw", "3.00").Code)
+		require.Equal(t, http.StatusBadRequest, operation("withdraw", "100.00").Code)
+		require.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/wallets/"+walletID.String(), "").Code)
+		require.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/wallets/"+walletID.String()+"/", "").Code)
+
+		assert.Equal(t, deposits+2, testutil.ToFloat64(metrics.Operations.WithLabelValues(metrics.OperationDeposit)))
+		assert.Equal(t, depositedUSD+1550, testutil.ToFloat64(metrics.AmountMoved.WithLabelValues(metrics.OperationDeposit, "USD")))
+		assert.Equal(t, withdrawals+1, testutil.ToFloat64(metrics.Operations.WithLabelValues(metrics.OperationWithdraw)))
+		assert.Equal(t, withdrawnUSD+300, testutil.ToFloat64(metrics.AmountMoved.WithLabelValues(metrics.OperationWithdraw, "USD")))
+		assert.Equal(t, rejected+1, testutil.ToFloat64(metrics.InsufficientFunds.WithLabelValues(metrics.OperationWithdraw)))
+		assert.Equal(t, walletGets+2, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "/api/v1/wallets/{id}", "200")),
+			"requests are labelled by route pattern, not by path")
+
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/metrics", "").Code, "the metrics aren't served with the API")
+
+		rr := httptest.NewRecorder()
+		MetricsRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+		body, err := io.ReadAll(rr.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `wallet_http_requests_total{method="POST",route="/api/v1/wallet",status="400"}`)
+		assert.Contains(t, string(body), "wallet_http_request_duration_seconds_bucket")
+		assert.Contains(t, string(body), `wallet_insufficient_funds_total{operation="withdraw"}`)
+	})
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/metrics"
+	"wallet-app/pkg/ratelimit"
+	"wallet-app/pkg/service"
+
+	"github.com/google/uuid"
+)
+
+const (
+	rateLimitLimitHeader     = "RateLimit-Limit"
+	rateLimitRemainingHeader = "RateLimit-Remaining"
+	rateLimitResetHeader     = "RateLimit-Reset"
+
+	rateLimitScopeClient = "client"
+	rateLimitScopeWallet = "wallet"
+)
+
+// RateLimits configures the rate limiting of the API.
+type RateLimits struct {
+	Store ratelimit.Store
+	// Client is the default limit of each API client; a client's own limit
+	// takes precedence.
+	Client ratelimit.Limit
+	// Wallet limits the operations on each wallet, whoever makes them, so
+	// that a hot wallet can't pile up requests waiting for its row lock.
+	Wallet ratelimit.Limit
+}
+
+// limitClients rate limits each client. It must run after authenticate.
+func (h *Handler) limitClients(limits *RateLimits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limits == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client, ok := service.ClientFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			limit := limits.Client
+			if client.RateLimit != nil {
+				limit = *client.RateLimit
+			}
+			if !h.takeTokens(r.Context(), w, limits.Store, rateLimitScopeClient, limit, client.ID) {
+				h.sendError(w, rateLimitScopeClient+" rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitWallets rate limits the operations on each wallet. The service takes
+// the tokens once it has authorized an operation, and the handlers answer
+// ErrWalletRateLimited with handleRateLimitError.
+func (h *Handler) limitWallets(limits *RateLimits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limits == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := service.WithWalletLimiter(r.Context(), func(ctx context.Context, walletIDs ...uuid.UUID) error {
+				if !h.takeTokens(ctx, w, limits.Store, rateLimitScopeWallet, limits.Wallet, walletIDs...) {
+					return custom_errors.ErrWalletRateLimited
+				}
+				return nil
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// handleRateLimitError rejects operations over the rate limit of a wallet.
+// It returns false if err is not about the rate limit.
+func (h *Handler) handleRateLimitError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, custom_errors.ErrWalletRateLimited) {
+		h.sendError(w, err.Error(), http.StatusTooManyRequests)
+		return true
+	}
+	return false
+}
+
+// takeTokens takes a token from the scope bucket of each of ids, or none if
+// one of them is empty, and sets the RateLimit headers. If the limit is
+// exceeded it sets Retry-After and returns false. Errors of the store let
+// the request through: the limits protect the database, so they shouldn't
+// take the API down on their own.
+func (h *Handler) takeTokens(ctx context.Context, w http.ResponseWriter, store ratelimit.Store, scope string, limit ratelimit.Limit, ids ...uuid.UUID) bool {
+	if !limit.Enabled() || len(ids) == 0 {
+		return true
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = scope + ":" + id.String()
+	}
+	res, err := store.Take(ctx, keys, limit)
+	if err != nil {
+		slog.WarnContext(ctx, "rate limit store failed", slog.String("scope", scope), slog.Any("error", err))
+		return true
+	}
+
+	setRateLimitHeaders(w, res)
+	if res.Allowed {
+		return true
+	}
+
+	metrics.RateLimited.WithLabelValues(scope).Inc()
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	return false
+}
+
+// setRateLimitHeaders reports res unless an earlier limit of the request
+// has fewer requests remaining.
+func setRateLimitHeaders(w http.ResponseWriter, res ratelimit.Result) {
+	if prev, err := strconv.Atoi(w.Header().Get(rateLimitRemainingHeader)); err == nil && prev <= res.Remaining {
+		return
+	}
+	w.Header().Set(rateLimitLimitHeader, strconv.Itoa(res.Limit))
+	w.Header().Set(rateLimitRemainingHeader, strconv.Itoa(res.Remaining))
+	w.Header().Set(rateLimitResetHeader, strconv.Itoa(ceilSeconds(res.Reset)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/ratelimit"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore fails every Take.
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, []string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestRateLimits(t *testing.T) {
+	s := service.NewService(repository.NewRepository(repository.NewMemory()), service.Config{})
+
+	newRouter := func(limits *RateLimits) http.Handler {
+		return NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}, RateLimits: limits})
+	}
+	deposit := func(router http.Handler, key string, walletID uuid.UUID) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet",
+			strings.NewReader(`{"walletId":"`+walletID.String()+`","operationType":"deposit","amount":"1.00"}`))
+		req.Header.Set("Authorization", "Bearer "+key)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("per client", func(t *testing.T) {
+		router := newRouter(&RateLimits{
+			Store:  ratelimit.NewMemoryStore(),
+			Client: ratelimit.Limit{Rate: 0.01, Burst: 2},
+		})
+		key, other := newAPIKey(t, s, false), newAPIKey(t, s, false)
+
+		rr := deposit(router, key, uuid.New())
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "100", rr.Header().Get("RateLimit-Reset"))
+
+		require.Equal(t, http.StatusOK, deposit(router, key, uuid.New()).Code)
+
+		rr = deposit(router, key, uuid.New())
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "100", rr.Header().Get("Retry-After"))
+		assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+
+		assert.Equal(t, http.StatusOK, deposit(router, other, uuid.New()).Code, "clients have separate limits")
+	})
+
+	t.Run("client's own limit", func(t *testing.T) {
+		router := newRouter(&RateLimits{
+			Store:  ratelimit.NewMemoryStore(),
+			Client: ratelimit.Limit{Rate: 0.01, Burst: 1},
+		})
+		_, key, err := s.NewClient(t.Context(), models.Client{Name: "unlimited", RateLimit: &ratelimit.Limit{}})
+		require.NoError(t, err)
+
+		for range 3 {
+			rr := deposit(router, key, uuid.New())
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+		}
+	})
+
+	t.Run("per wallet", func(t *testing.T) {
+		router := newRouter(&RateLimits{
+			Store:  ratelimit.NewMemoryStore(),
+			Client: ratelimit.Limit{Rate: 0.01, Burst: 10},
+			Wallet: ratelimit.Limit{Rate: 0.01, Burst: 1},
+		})
+		key := newAPIKey(t, s, true)
+		walletID := uuid.New()
+
+		rr := deposit(router, key, walletID)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "1", rr.Header().Get("RateLimit-Limit"), "the headers report the tighter limit")
+		assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+
+		rr = deposit(router, newAPIKey(t, s, true), walletID)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code, "the wallet limit is shared by all clients")
+		assert.Contains(t, rr.Body.String(), "wallet rate limit exceeded")
+
+		assert.Equal(t, http.StatusOK, deposit(router, key, uuid.New()).Code)
+	})
+
+	t.Run("new wallets", func(t *testing.T) {
+		router := newRouter(&RateLimits{
+			Store:  ratelimit.NewMemoryStore(),
+			Wallet: ratelimit.Limit{Rate: 0.01, Burst: 2},
+		})
+
+		rr := deposit(router, newAPIKey(t, s, false), uuid.New())
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"), "creating a wallet takes a single token")
+	})
+
+	t.Run("requests denied to other clients", func(t *testing.T) {
+		router := newRouter(&RateLimits{
+			Store:  ratelimit.NewMemoryStore(),
+			Wallet: ratelimit.Limit{Rate: 0.01, Burst: 2},
+		})
+		owner, other := newAPIKey(t, s, false), newAPIKey(t, s, false)
+		walletID := uuid.New()
+
+		rr := deposit(router, owner, walletID)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+
+		for range 3 {
+			rr = deposit(router, other, walletID)
+			assert.Equal(t, http.StatusForbidden, rr.Code)
+			assert.Empty(t, rr.Header().Get("RateLimit-Remaining"), "the wallet limit isn't taken")
+		}
+
+		rr = deposit(router, owner, walletID)
+		require.Equal(t, http.StatusOK, rr.Code, "the owner's budget is left unchanged")
+		assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, http.StatusTooManyRequests, deposit(router, owner, walletID).Code)
+	})
+
+	t.Run("transfers to other clients", func(t *testing.T) {
+		limits := &RateLimits{
+			Store:  ratelimit.NewMemoryStore(),
+			Wallet: ratelimit.Limit{Rate: 0.01, Burst: 2},
+		}
+		limited, unlimited := newRouter(limits), newRouter(nil)
+		payer, owner := newAPIKey(t, s, false), newAPIKey(t, s, false)
+		fromIDs := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+		for _, fromID := range fromIDs {
+			require.Equal(t, http.StatusOK, deposit(unlimited, payer, fromID).Code)
+		}
+		toID := uuid.New()
+		require.Equal(t, http.StatusOK, deposit(unlimited, owner, toID).Code)
+
+		for _, fromID := range fromIDs {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/transfers",
+				strings.NewReader(`{"fromWalletId":"`+fromID.String()+`","toWalletId":"`+toID.String()+`","amount":"0.01"}`))
+			req.Header.Set("Authorization", "Bearer "+payer)
+			rr := httptest.NewRecorder()
+			limited.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusOK, rr.Code)
+		}
+
+		rr := deposit(limited, owner, toID)
+		require.Equal(t, http.StatusOK, rr.Code, "transfers in don't use up the limit of the destination")
+		assert.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+	})
+
+	t.Run("batches and holds", func(t *testing.T) {
+		limits := &RateLimits{
+			Store:  ratelimit.NewMemoryStore(),
+			Wallet: ratelimit.Limit{Rate: 0.01, Burst: 1},
+		}
+		limited, unlimited := newRouter(limits), newRouter(nil)
+		key := newAPIKey(t, s, false)
+		do := func(router http.Handler, path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+key)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+
+		walletID := uuid.New()
+		require.Equal(t, http.StatusOK, deposit(unlimited, key, walletID).Code)
+		op := `{"walletId":"` + walletID.String() + `","operationType":"deposit","amount":"1.00"}`
+		batch := `{"operations":[` + strings.Repeat(op+",", 4) + op + `]}`
+
+		rr := do(limited, "/api/v1/wallet/batch", batch)
+		require.Equal(t, http.StatusOK, rr.Code, "a batch takes one token per wallet")
+		assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+		rr = do(limited, "/api/v1/wallet/batch", batch)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Contains(t, rr.Body.String(), "wallet rate limit exceeded")
+
+		otherID := uuid.New()
+		require.Equal(t, http.StatusOK, deposit(unlimited, key, otherID).Code)
+		other := `{"walletId":"` + otherID.String() + `","operationType":"deposit","amount":"1.00"}`
+		rr = do(limited, "/api/v1/wallet/batch", `{"operations":[`+other+`,`+op+`]}`)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		rr = deposit(limited, key, otherID)
+		assert.Equal(t, http.StatusOK, rr.Code, "a denied batch doesn't use up the limits of its other wallets")
+
+		walletID = uuid.New()
+		require.Equal(t, http.StatusOK, deposit(unlimited, key, walletID).Code)
+		var holds []string
+		for range 2 {
+			rr := do(unlimited, "/api/v1/wallets/"+walletID.String()+"/holds", `{"amount":"0.10"}`)
+			require.Equal(t, http.StatusCreated, rr.Code)
+			var hold HoldResp
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &hold))
+			holds = append(holds, hold.ID.String())
+		}
+
+		assert.Equal(t, http.StatusOK, do(limited, "/api/v1/holds/"+holds[0]+"/capture", `{}`).Code)
+		rr = do(limited, "/api/v1/holds/"+holds[1]+"/release", "")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+		rr = do(limited, "/api/v1/holds/"+holds[1]+"/capture", `{}`)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	})
+
+	t.Run("oversized body", func(t *testing.T) {
+		router := newRouter(&RateLimits{
+			Store:  ratelimit.NewMemoryStore(),
+			Wallet: ratelimit.Limit{Rate: 0.01, Burst: 1},
+		})
+		walletID := uuid.New()
+		body := `{"walletId":"` + walletID.String() + `","operationType":"deposit","amount":"1.00","padding":"` + strings.Repeat("x", maxBodySize) + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+newAPIKey(t, s, false))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("store failure lets requests through", func(t *testing.T) {
+		captureLogs(t)
+		router := newRouter(&RateLimits{
+			Store:  failingStore{},
+			Client: ratelimit.Limit{Rate: 0.01, Burst: 1},
+		})
+		key := newAPIKey(t, s, false)
+
+		for range 2 {
+			assert.Equal(t, http.StatusOK, deposit(router, key, uuid.New()).Code)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+	"wallet-app/pkg/testutils"
+	"wallet-app/pkg/tracing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		s := service.NewService(repo, service.Config{})
+		router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+		walletID := uuid.New()
+		require.NoError(t, repo.NewWallet(t.Context(), walletID, "RUB", 10000))
+
+		const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet",
+			strings.NewReader(`{"walletId":"`+walletID.String()+`","operationType":"withdraw","amount":"30.00"}`))
+		req.Header.Set("Authorization", "Bearer "+newAPIKey(t, s, true))
+		req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		spans := map[string]sdktrace.ReadOnlySpan{}
+		for _, span := range recorder.Ended() {
+			if span.SpanContext().TraceID().String() == traceID {
+				spans[span.Name()] = span
+			}
+		}
+
+		server, ok := spans["POST /api/v1/wallet"]
+		require.True(t, ok, "the request span continues the caller's trace")
+		assert.Contains(t, server.Attributes(), attribute.String("http.route", "/api/v1/wallet"))
+		assert.Contains(t, server.Attributes(), attribute.Int("http.response.status_code", http.StatusOK))
+
+		withdraw, ok := spans["service.Withdraw"]
+		require.True(t, ok)
+		assert.Equal(t, server.SpanContext().SpanID(), withdraw.Parent().SpanID())
+		assert.Contains(t, withdraw.Attributes(), tracing.WalletIDKey.String(walletID.String()))
+
+		if _, isPG := spans["SELECT wallets"]; isPG {
+			assert.Contains(t, spans["SELECT wallets"].Attributes(), tracing.WalletIDKey.String(walletID.String()),
+				"queries carry the wallet they work on")
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/money"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type (
+	TransactionResp struct {
+		ID        int64     `json:"id"`
+		Operation string    `json:"operationType"`
+		Amount    string    `json:"amount"`
+		CreatedAt time.Time `json:"createdAt"`
+		// CounterpartyID is the other wallet of a transfer.
+		CounterpartyID *uuid.UUID `json:"counterpartyId,omitempty"`
+	}
+
+	TransactionListResp struct {
+		Currency     string            `json:"currency"`
+		Transactions []TransactionResp `json:"transactions"`
+		NextCursor   string            `json:"nextCursor,omitempty"`
+	}
+)
+
+func (h *Handler) listTransactions(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	walletID, err := uuid.Parse(idStr)
+	if err != nil {
+		h.sendError(w, fmt.Sprintf("wrong uuid: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseTransactionFilter(r)
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	page, err := h.service.ListTransactions(ctx, walletID, filter)
+	if err != nil {
+		if errors.Is(err, custom_errors.ErrWalletNotFound) {
+			h.sendError(w, "wallet not found", http.StatusNotFound)
+		} else if !h.handleAccessError(w, err) {
+			h.sendInternalError(w, r, "could not list transactions", err)
+		}
+		return
+	}
+
+	res := TransactionListResp{
+		Currency:     page.Currency,
+		Transactions: make([]TransactionResp, 0, len(page.Transactions)),
+	}
+	for _, t := range page.Transactions {
+		res.Transactions = append(res.Transactions, TransactionResp{
+			ID:        t.ID,
+			Operation: t.Operation,
+			Amount:    money.Format(t.Amount, page.Currency),
+			CreatedAt: t.CreatedAt,
+
+			CounterpartyID: t.CounterpartyID,
+		})
+	}
+	if page.NextCursor > 0 {
+		res.NextCursor = strconv.FormatInt(page.NextCursor, 10)
+	}
+
+	h.sendJSON(w, res, http.StatusOK)
+}
+
+func parseTransactionFilter(r *http.Request) (models.TransactionFilter, error) {
+	var filter models.TransactionFilter
+	query := r.URL.Query()
+
+	if op := strings.ToLower(query.Get("operationType")); op != "" {
+		switch op {
+		case models.OperationDeposit, models.OperationWithdraw, models.OperationOpeningBalance,
+			models.OperationTransferIn, models.OperationTransferOut:
+			filter.Operation = op
+		default:
+			return filter, errors.New("wrong operation type")
+		}
+	}
+
+	if from := query.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, errors.New("from must be an RFC3339 timestamp")
+		}
+		filter.From = t
+	}
+
+	if to := query.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, errors.New("to must be an RFC3339 timestamp")
+		}
+		filter.To = t
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		c, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || c <= 0 {
+			return filter, errors.New("invalid cursor")
+		}
+		filter.Cursor = c
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l <= 0 {
+			return filter, errors.New("limit must be a positive integer")
+		}
+		filter.Limit = l
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+	"wallet-app/pkg/testutils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListTransactions(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		s := service.NewService(repo, service.Config{})
+		h := NewHandler(s)
+		ctx := context.Background()
+		walletID := uuid.New()
+
+		_ = repo.NewWallet(ctx, walletID, "RUB", 100)
+		_ = repo.Deposit(ctx, walletID, "RUB", 250)
+		_ = repo.Withdraw(ctx, walletID, "RUB", 50)
+
+		router := chi.NewRouter()
+		router.Get("/api/v1/wallets/{id}/transactions", h.listTransactions)
+
+		t.Run("paginate", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID.String()+"/transactions?limit=2", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			var resp TransactionListResp
+			err := json.NewDecoder(rr.Body).Decode(&resp)
+			assert.NoError(t, err)
+			assert.Len(t, resp.Transactions, 2)
+			assert.Equal(t, "-0.50", resp.Transactions[0].Amount)
+			assert.NotEmpty(t, resp.NextCursor)
+
+			req = httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID.String()+"/transactions?limit=2&cursor="+resp.NextCursor, nil)
+			rr = httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			resp = TransactionListResp{}
+			err = json.NewDecoder(rr.Body).Decode(&resp)
+			assert.NoError(t, err)
+			assert.Len(t, resp.Transactions, 1)
+			assert.Equal(t, "1.00", resp.Transactions[0].Amount)
+			assert.Empty(t, resp.NextCursor)
+		})
+
+		t.Run("filter by operation", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID.String()+"/transactions?operationType=deposit", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			var resp TransactionListResp
+			err := json.NewDecoder(rr.Body).Decode(&resp)
+			assert.NoError(t, err)
+			assert.Len(t, resp.Transactions, 2)
+		})
+
+		t.Run("transfers", func(t *testing.T) {
+			otherID := uuid.New()
+			_ = repo.NewWallet(ctx, otherID, "RUB", 0)
+			_ = repo.Transfer(ctx, walletID, otherID, "RUB", 30)
+
+			for path, want := range map[string]TransactionResp{
+				walletID.String() + "/transactions?operationType=transfer_out": {Operation: "transfer_out", Amount: "-0.30", CounterpartyID: &otherID},
+				otherID.String() + "/transactions?operationType=transfer_in":   {Operation: "transfer_in", Amount: "0.30", CounterpartyID: &walletID},
+			} {
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+path, nil)
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, req)
+				assert.Equal(t, http.StatusOK, rr.Code, path)
+
+				var resp TransactionListResp
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+				if assert.Len(t, resp.Transactions, 1, path) {
+					got := resp.Transactions[0]
+					assert.Equal(t, want.Operation, got.Operation)
+					assert.Equal(t, want.Amount, got.Amount)
+					assert.Equal(t, want.CounterpartyID, got.CounterpartyID)
+				}
+			}
+		})
+
+		t.Run("invalid date", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID.String()+"/transactions?from=yesterday", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+
+		t.Run("wallet not found", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+uuid.New().String()+"/transactions", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+		})
+	})
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	custom_errors "wallet-app/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+type TransferJSON struct {
+	FromWalletID uuid.UUID `json:"fromWalletId"`
+	ToWalletID   uuid.UUID `json:"toWalletId"`
+	Amount       string    `json:"amount"`
+	Currency     string    `json:"currency,omitempty"`
+}
+
+func (h *Handler) transfer(w http.ResponseWriter, r *http.Request) {
+	var req TransferJSON
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	transferCurrency, err := parseCurrency(req.Currency)
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	transferCurrency, err = h.service.ResolveCurrency(r.Context(), req.FromWalletID, transferCurrency)
+	if err != nil {
+		if !h.handleAccessError(w, err) {
+			h.sendInternalError(w, r, "internal server error", err)
+		}
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, transferCurrency)
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if amount == 0 {
+		h.sendError(w, "amount must be positive", http.StatusBadRequest)
+		return
+	}
+
+	ctx, ok := h.withIdempotencyKey(w, r, req, http.StatusOK, SuccessRes{"transfer completed"})
+	if !ok {
+		return
+	}
+
+	if err := h.service.Transfer(ctx, req.FromWalletID, req.ToWalletID, transferCurrency, amount); err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrWalletNotFound):
+			h.sendError(w, "wallet not found", http.StatusNotFound)
+		case errors.Is(err, custom_errors.ErrNotEnoughFunds), errors.Is(err, custom_errors.ErrSameWallet),
+			errors.Is(err, custom_errors.ErrCurrencyMismatch), errors.Is(err, custom_errors.ErrBalanceOverflow):
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+		default:
+			if !h.handleAccessError(w, err) && !h.handleRateLimitError(w, err) && !h.handleWalletStatusError(w, err) && !h.handleIdempotencyError(w, r, err) {
+				h.sendInternalError(w, r, "internal server error", err)
+			}
+		}
+		return
+	}
+	h.sendSuccess(w, "transfer completed", http.StatusOK)
+}
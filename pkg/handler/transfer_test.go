@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+	"wallet-app/pkg/testutils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransfer(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		s := service.NewService(repo, service.Config{})
+		h := NewHandler(s)
+		ctx := context.Background()
+		fromID := uuid.New()
+		toID := uuid.New()
+
+		_ = repo.NewWallet(ctx, fromID, "RUB", 10000)
+		_ = repo.NewWallet(ctx, toID, "RUB", 0)
+
+		router := chi.NewRouter()
+		router.Post("/api/v1/transfers", h.transfer)
+
+		transfer := func(from, to uuid.UUID, amount string) *httptest.ResponseRecorder {
+			body := fmt.Sprintf(`{"fromWalletId":"%s", "toWalletId":"%s", "amount":"%s"}`, from, to, amount)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/transfers", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+
+		t.Run("valid transfer", func(t *testing.T) {
+			rr := transfer(fromID, toID, "40.00")
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			fromBalance, err := repo.GetBalance(ctx, fromID)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(6000), fromBalance)
+
+			toBalance, err := repo.GetBalance(ctx, toID)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(4000), toBalance)
+		})
+
+		t.Run("not enough funds", func(t *testing.T) {
+			rr := transfer(fromID, toID, "1000.00")
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+
+		t.Run("zero amount", func(t *testing.T) {
+			rr := transfer(fromID, toID, "0.00")
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Contains(t, rr.Body.String(), "amount must be positive")
+
+			transactions, err := repo.ListTransactions(ctx, toID, models.TransactionFilter{Operation: models.OperationTransferIn, Limit: 10})
+			assert.NoError(t, err)
+			assert.Len(t, transactions, 1, "nothing is recorded")
+		})
+
+		t.Run("same wallet", func(t *testing.T) {
+			rr := transfer(fromID, fromID, "1.00")
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+
+		t.Run("wallet not found", func(t *testing.T) {
+			rr := transfer(fromID, uuid.New(), "1.00")
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+		})
+	})
+}
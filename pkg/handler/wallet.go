@@ -5,10 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"regexp"
-	"strconv"
 	"strings"
+	"time"
+	"wallet-app/pkg/currency"
 	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/money"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -19,11 +20,23 @@ type (
 		WalletID  uuid.UUID `json:"walletId"`
 		Operation string    `json:"operationType"`
 		Amount    string    `json:"amount"`
+		Currency  string    `json:"currency,omitempty"`
 	}
 
 	WalletResp struct {
-		ID      uuid.UUID `json:"id"`
-		Balance string    `json:"balance"`
+		ID               uuid.UUID `json:"id"`
+		Balance          string    `json:"balance"`
+		AvailableBalance string    `json:"availableBalance"`
+		Currency         string    `json:"currency"`
+		Status           string    `json:"status"`
+	}
+
+	// WalletBalanceResp is the balance of a wallet as of a past instant.
+	WalletBalanceResp struct {
+		ID       uuid.UUID `json:"id"`
+		Balance  string    `json:"balance"`
+		Currency string    `json:"currency"`
+		AsOf     time.Time `json:"asOf"`
 	}
 )
 
@@ -35,20 +48,46 @@ func (h *Handler) getWalletInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var asOf time.Time
+	if value := r.URL.Query().Get("asOf"); value != "" {
+		asOf, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			h.sendError(w, "asOf must be an RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		if asOf.After(time.Now()) {
+			h.sendError(w, "asOf can't be in the future", http.StatusBadRequest)
+			return
+		}
+	}
+
 	ctx := r.Context()
-	balance, err := h.service.GetBalance(ctx, walletID)
+	wallet, err := h.service.GetWallet(ctx, walletID)
 	if err != nil {
 		if errors.Is(err, custom_errors.ErrWalletNotFound) {
 			h.sendError(w, "wallet not found", http.StatusNotFound)
-		} else {
-			h.sendError(w, "could not get balance", http.StatusInternalServerError)
+		} else if !h.handleAccessError(w, err) {
+			h.sendInternalError(w, r, "could not get balance", err)
+		}
+		return
+	}
+
+	if !asOf.IsZero() {
+		balance, err := h.service.GetBalanceAt(ctx, walletID, asOf)
+		if err != nil {
+			h.sendInternalError(w, r, "could not get balance", err)
 			return
 		}
+		h.sendJSON(w, WalletBalanceResp{ID: walletID, Balance: balance, Currency: wallet.Currency, AsOf: asOf}, http.StatusOK)
+		return
 	}
 
 	res := WalletResp{
-		ID:      walletID,
-		Balance: balance,
+		ID:               walletID,
+		Balance:          money.Format(wallet.Balance, wallet.Currency),
+		AvailableBalance: money.Format(wallet.Available, wallet.Currency),
+		Currency:         wallet.Currency,
+		Status:           wallet.Status,
 	}
 
 	h.sendJSON(w, res, http.StatusOK)
@@ -62,52 +101,98 @@ func (h *Handler) updateWalletBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	match, err := regexp.MatchString(`^\d+\.00$`, req.Amount)
-	if err != nil || !match {
-		h.sendError(w, "amount must be in format *.00 (e.g., 100.00)", http.StatusBadRequest)
+	walletCurrency, err := parseCurrency(req.Currency)
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	amountStr := strings.Replace(req.Amount, ".", "", 1)
-	amount, err := strconv.Atoi(amountStr)
+	walletCurrency, err = h.service.ResolveCurrency(r.Context(), req.WalletID, walletCurrency)
 	if err != nil {
-		h.sendError(w, "invalid amount", http.StatusBadRequest)
+		if !h.handleAccessError(w, err) {
+			h.sendInternalError(w, r, "internal server error", err)
+		}
 		return
 	}
 
-	if amount < 0 {
-		h.sendError(w, "amount can't be negative", http.StatusBadRequest)
+	amount, err := parseAmount(req.Amount, walletCurrency)
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	ctx := r.Context()
+	ctx, ok := h.withIdempotencyKey(w, r, req, http.StatusOK, SuccessRes{"balance updated"})
+	if !ok {
+		return
+	}
 
 	switch strings.ToLower(req.Operation) {
 	case "deposit":
-		if err := h.service.Deposit(ctx, req.WalletID, amount); err != nil {
-			if errors.Is(err, custom_errors.ErrWalletNotFound) {
-				if err := h.service.NewWallet(ctx, req.WalletID, amount); err != nil {
-					h.sendError(w, err.Error(), http.StatusInternalServerError)
-					return
-				}
-			} else {
-				h.sendError(w, err.Error(), http.StatusInternalServerError)
-				return
+		err := h.service.Deposit(ctx, req.WalletID, walletCurrency, amount)
+		if errors.Is(err, custom_errors.ErrWalletNotFound) {
+			err = h.service.NewWallet(ctx, req.WalletID, walletCurrency, amount)
+		}
+		if err != nil {
+			if errors.Is(err, custom_errors.ErrCurrencyMismatch) || errors.Is(err, custom_errors.ErrBalanceOverflow) {
+				h.sendError(w, err.Error(), http.StatusBadRequest)
+			} else if !h.handleAccessError(w, err) && !h.handleRateLimitError(w, err) && !h.handleWalletStatusError(w, err) && !h.handleIdempotencyError(w, r, err) {
+				h.sendInternalError(w, r, "internal server error", err)
 			}
+			return
 		}
 		h.sendSuccess(w, "balance updated", http.StatusOK)
 	case "withdraw":
-		if err := h.service.Withdraw(ctx, req.WalletID, amount); err != nil {
-			if errors.Is(err, custom_errors.ErrNotEnoughFunds) {
+		if err := h.service.Withdraw(ctx, req.WalletID, walletCurrency, amount); err != nil {
+			if errors.Is(err, custom_errors.ErrNotEnoughFunds) || errors.Is(err, custom_errors.ErrCurrencyMismatch) {
 				h.sendError(w, err.Error(), http.StatusBadRequest)
-				return
-			} else {
-				h.sendError(w, err.Error(), http.StatusInternalServerError)
-				return
+			} else if !h.handleAccessError(w, err) && !h.handleRateLimitError(w, err) && !h.handleWalletStatusError(w, err) && !h.handleIdempotencyError(w, r, err) {
+				h.sendInternalError(w, r, "internal server error", err)
 			}
+			return
 		}
 		h.sendSuccess(w, "balance updated", http.StatusOK)
 	default:
 		h.sendError(w, "wrong operation type", http.StatusBadRequest)
 	}
 }
+
+// handleWalletStatusError rejects operations on frozen or closed wallets. It
+// returns false if err is not about the wallet status.
+func (h *Handler) handleWalletStatusError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, custom_errors.ErrWalletFrozen):
+		h.sendError(w, err.Error(), http.StatusForbidden)
+		return true
+	case errors.Is(err, custom_errors.ErrWalletClosed):
+		h.sendError(w, err.Error(), http.StatusConflict)
+		return true
+	}
+	return false
+}
+
+func parseAmount(value, walletCurrency string) (int64, error) {
+	amount, err := money.Parse(value, walletCurrency)
+	if err != nil {
+		return 0, err
+	}
+
+	if amount < 0 {
+		return 0, errors.New("amount can't be negative")
+	}
+
+	return amount, nil
+}
+
+// parseCurrency validates an optional ISO 4217 code. An empty value is
+// returned as is and means "the wallet's currency".
+func parseCurrency(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	code := currency.Normalize(value)
+	if !currency.Valid(code) {
+		return "", fmt.Errorf("%w: %s", custom_errors.ErrUnsupportedCurrency, value)
+	}
+	return code, nil
+}
@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/webhook"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// minWebhookSecretLength keeps clients from signing with guessable secrets.
+const minWebhookSecretLength = 16
+
+type (
+	// WebhookJSON's Secret may be left empty to have one generated.
+	WebhookJSON struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"eventTypes"`
+		Secret     string   `json:"secret,omitempty"`
+	}
+
+	// WebhookResp carries the secret only when the webhook is created.
+	WebhookResp struct {
+		ID         uuid.UUID `json:"id"`
+		URL        string    `json:"url"`
+		EventTypes []string  `json:"eventTypes"`
+		Secret     string    `json:"secret,omitempty"`
+		CreatedAt  time.Time `json:"createdAt"`
+	}
+
+	WebhookListResp struct {
+		Webhooks []WebhookResp `json:"webhooks"`
+	}
+
+	DeliveryResp struct {
+		ID        int64     `json:"id"`
+		WebhookID uuid.UUID `json:"webhookId"`
+		EventID   int64     `json:"eventId"`
+		EventType string    `json:"eventType"`
+		WalletID  uuid.UUID `json:"walletId"`
+		Status    string    `json:"status"`
+		Attempts  int       `json:"attempts"`
+		// NextAttemptAt is only set for pending deliveries.
+		NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+		LastError      string     `json:"lastError,omitempty"`
+		LastStatusCode int        `json:"lastStatusCode,omitempty"`
+		CreatedAt      time.Time  `json:"createdAt"`
+	}
+
+	DeliveryListResp struct {
+		Deliveries []DeliveryResp `json:"deliveries"`
+	}
+)
+
+func newWebhookResp(wh models.Webhook) WebhookResp {
+	return WebhookResp{
+		ID:         wh.ID,
+		URL:        wh.URL,
+		EventTypes: wh.EventTypes,
+		CreatedAt:  wh.CreatedAt,
+	}
+}
+
+func newDeliveryResp(d models.WebhookDelivery) DeliveryResp {
+	resp := DeliveryResp{
+		ID:             d.ID,
+		WebhookID:      d.WebhookID,
+		EventID:        d.Event.ID,
+		EventType:      d.Event.Type,
+		WalletID:       d.Event.WalletID,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastError:      d.LastError,
+		LastStatusCode: d.LastStatusCode,
+		CreatedAt:      d.CreatedAt,
+	}
+	if d.Status == models.DeliveryPending {
+		resp.NextAttemptAt = &d.NextAttemptAt
+	}
+	return resp
+}
+
+// parseWebhook checks req and returns the webhook it describes.
+func parseWebhook(req WebhookJSON) (models.Webhook, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return models.Webhook{}, errors.New("url must be an absolute http or https URL")
+	}
+
+	if len(req.EventTypes) == 0 {
+		return models.Webhook{}, errors.New("eventTypes must not be empty")
+	}
+	for _, t := range req.EventTypes {
+		if !slices.Contains(models.EventTypes, t) {
+			return models.Webhook{}, fmt.Errorf("unknown event type %q", t)
+		}
+	}
+
+	if req.Secret != "" && len(req.Secret) < minWebhookSecretLength {
+		return models.Webhook{}, fmt.Errorf("secret must be at least %d characters long", minWebhookSecretLength)
+	}
+
+	return models.Webhook{
+		URL:        u.String(),
+		EventTypes: slices.Compact(slices.Sorted(slices.Values(req.EventTypes))),
+		Secret:     req.Secret,
+	}, nil
+}
+
+func (h *Handler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req WebhookJSON
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	wh, err := parseWebhook(req)
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	wh, err = h.service.CreateWebhook(r.Context(), wh)
+	if errors.Is(err, webhook.ErrPrivateAddress) {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.sendInternalError(w, r, "could not create webhook", err)
+		return
+	}
+
+	res := newWebhookResp(wh)
+	res.Secret = wh.Secret
+	h.sendJSON(w, res, http.StatusCreated)
+}
+
+func (h *Handler) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.service.ListWebhooks(r.Context())
+	if err != nil {
+		h.sendInternalError(w, r, "could not list webhooks", err)
+		return
+	}
+
+	res := WebhookListResp{Webhooks: make([]WebhookResp, 0, len(webhooks))}
+	for _, wh := range webhooks {
+		res.Webhooks = append(res.Webhooks, newWebhookResp(wh))
+	}
+	h.sendJSON(w, res, http.StatusOK)
+}
+
+func (h *Handler) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.sendError(w, fmt.Sprintf("wrong uuid: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeleteWebhook(r.Context(), webhookID); err != nil {
+		h.sendWebhookError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.sendError(w, fmt.Sprintf("wrong uuid: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryFailed:
+	default:
+		h.sendError(w, fmt.Sprintf("unknown status %q", status), http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := h.service.ListWebhookDeliveries(r.Context(), webhookID, status)
+	if err != nil {
+		h.sendWebhookError(w, r, err)
+		return
+	}
+
+	res := DeliveryListResp{Deliveries: make([]DeliveryResp, 0, len(deliveries))}
+	for _, d := range deliveries {
+		res.Deliveries = append(res.Deliveries, newDeliveryResp(d))
+	}
+	h.sendJSON(w, res, http.StatusOK)
+}
+
+func (h *Handler) redeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.sendError(w, fmt.Sprintf("wrong uuid: %v", err), http.StatusBadRequest)
+		return
+	}
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		h.sendError(w, "wrong delivery id", http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.service.Redeliver(r.Context(), webhookID, deliveryID)
+	if err != nil {
+		h.sendWebhookError(w, r, err)
+		return
+	}
+	h.sendJSON(w, newDeliveryResp(d), http.StatusAccepted)
+}
+
+func (h *Handler) sendWebhookError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, custom_errors.ErrWebhookNotFound), errors.Is(err, custom_errors.ErrDeliveryNotFound):
+		h.sendError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, custom_errors.ErrDeliveryPending):
+		h.sendError(w, err.Error(), http.StatusConflict)
+	default:
+		h.sendInternalError(w, r, "internal server error", err)
+	}
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/service"
+	"wallet-app/pkg/testutils"
+	"wallet-app/pkg/webhook"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhooks(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		// The receiver listens on loopback.
+		s := service.NewService(repo, service.Config{AllowPrivateWebhooks: true})
+		router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+		alice, bob := newAPIKey(t, s, false), newAPIKey(t, s, false)
+
+		const secret = "0123456789abcdef"
+		var (
+			mu       sync.Mutex
+			status   = http.StatusOK
+			received []models.Event
+		)
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			body, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			assert.NoError(t, webhook.Verify(secret, r.Header, body, time.Minute, time.Now()))
+			if status == http.StatusOK {
+				var event models.Event
+				assert.NoError(t, json.Unmarshal(body, &event))
+				received = append(received, event)
+			}
+			w.WriteHeader(status)
+		}))
+		defer receiver.Close()
+		setStatus := func(code int) {
+			mu.Lock()
+			defer mu.Unlock()
+			status = code
+		}
+
+		do := func(key, method, path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+key)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+		walletID := uuid.New()
+		deposit := func() {
+			t.Helper()
+			rr := do(alice, http.MethodPost, "/api/v1/wallet", `{"walletId":"`+walletID.String()+`","operationType":"deposit","amount":"10.00"}`)
+			require.Equal(t, http.StatusOK, rr.Code)
+		}
+		opts := service.WebhookOptions{Client: receiver.Client(), MaxAttempts: 2, Backoff: time.Millisecond}
+		deliver := func() {
+			t.Helper()
+			_, err := s.RelayOutbox(t.Context(), s.WebhookPublisher(), 0)
+			require.NoError(t, err)
+			_, err = s.DeliverWebhooks(t.Context(), opts)
+			require.NoError(t, err)
+		}
+
+		t.Run("validation", func(t *testing.T) {
+			for _, body := range []string{
+				`{"url":"ftp://example.com","eventTypes":["wallet.deposited"]}`,
+				`{"url":"/hooks","eventTypes":["wallet.deposited"]}`,
+				`{"url":"https://example.com","eventTypes":[]}`,
+				`{"url":"https://example.com","eventTypes":["wallet.closed"]}`,
+				`{"url":"https://example.com","eventTypes":["wallet.deposited"],"secret":"short"}`,
+				`not json`,
+			} {
+				assert.Equal(t, http.StatusBadRequest, do(alice, http.MethodPost, "/api/v1/webhooks", body).Code, body)
+			}
+		})
+
+		rr := do(alice, http.MethodPost, "/api/v1/webhooks",
+			`{"url":"`+receiver.URL+`","eventTypes":["wallet.deposited","wallet.withdrawn"],"secret":"`+secret+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code)
+		var created WebhookResp
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&created))
+		assert.Equal(t, secret, created.Secret)
+		path := "/api/v1/webhooks/" + created.ID.String()
+
+		rr = do(alice, http.MethodGet, "/api/v1/webhooks", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		var list WebhookListResp
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+		require.Len(t, list.Webhooks, 1)
+		assert.Empty(t, list.Webhooks[0].Secret, "the secret is only shown once")
+
+		t.Run("delivered and signed", func(t *testing.T) {
+			deposit()
+			deliver()
+
+			mu.Lock()
+			defer mu.Unlock()
+			require.Len(t, received, 1)
+			assert.Equal(t, walletID, received[0].WalletID)
+			assert.Equal(t, models.EventDeposited, received[0].Type)
+			assert.Equal(t, int64(1000), received[0].Balance)
+		})
+
+		t.Run("failed and redelivered", func(t *testing.T) {
+			setStatus(http.StatusInternalServerError)
+			deposit()
+			deliver()
+			time.Sleep(10 * time.Millisecond)
+			deliver()
+
+			rr := do(alice, http.MethodGet, path+"/deliveries?status=failed", "")
+			require.Equal(t, http.StatusOK, rr.Code)
+			var deliveries DeliveryListResp
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&deliveries))
+			require.Len(t, deliveries.Deliveries, 1)
+			failed := deliveries.Deliveries[0]
+			assert.Equal(t, 2, failed.Attempts)
+			assert.Equal(t, http.StatusInternalServerError, failed.LastStatusCode)
+			assert.NotEmpty(t, failed.LastError)
+			assert.Nil(t, failed.NextAttemptAt)
+
+			setStatus(http.StatusOK)
+			redeliver := path + "/deliveries/" + strconv.FormatInt(failed.ID, 10) + "/redeliver"
+			assert.Equal(t, http.StatusAccepted, do(alice, http.MethodPost, redeliver, "").Code)
+			assert.Equal(t, http.StatusConflict, do(alice, http.MethodPost, redeliver, "").Code, "already pending")
+			deliver()
+
+			mu.Lock()
+			assert.Len(t, received, 2)
+			mu.Unlock()
+
+			rr = do(alice, http.MethodGet, path+"/deliveries?status=delivered", "")
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&deliveries))
+			assert.Len(t, deliveries.Deliveries, 2)
+		})
+
+		t.Run("other clients", func(t *testing.T) {
+			assert.Equal(t, http.StatusNotFound, do(bob, http.MethodGet, path+"/deliveries", "").Code)
+			assert.Equal(t, http.StatusNotFound, do(bob, http.MethodDelete, path, "").Code)
+		})
+
+		assert.Equal(t, http.StatusNoContent, do(alice, http.MethodDelete, path, "").Code)
+		assert.Equal(t, http.StatusNotFound, do(alice, http.MethodGet, path+"/deliveries", "").Code)
+	})
+}
+
+func TestWebhookPrivateAddresses(t *testing.T) {
+	testutils.ForEachBackend(t, func(t *testing.T, repo *repository.Repository) {
+		s := service.NewService(repo, service.Config{})
+		router := NewHandler(s).RegisterRoutes(Config{AllowedOrigins: []string{"*"}})
+		alice := newAPIKey(t, s, false)
+
+		for _, url := range []string{
+			"http://169.254.169.254/latest/meta-data",
+			"http://127.0.0.1:8080/hooks",
+			"http://[::1]/hooks",
+			"http://localhost/hooks",
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(`{"url":"`+url+`","eventTypes":["wallet.deposited"]}`))
+			req.Header.Set("Authorization", "Bearer "+alice)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, url)
+		}
+	})
+}
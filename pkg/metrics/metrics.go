@@ -0,0 +1,109 @@
+// Package metrics holds the Prometheus collectors exposed on /metrics. They
+// are registered with the default registry, so promhttp.Handler serves them
+// together with the Go runtime and process metrics.
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "wallet"
+
+// Operations counted by the wallet metrics.
+const (
+	OperationDeposit  = "deposit"
+	OperationWithdraw = "withdraw"
+	OperationTransfer = "transfer"
+	OperationCapture  = "capture"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	Operations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operations_total",
+		Help:      "Successful balance operations by type.",
+	}, []string{"operation"})
+
+	InsufficientFunds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "insufficient_funds_total",
+		Help:      "Operations rejected for insufficient funds, by type.",
+	}, []string{"operation"})
+
+	// AmountMoved is in minor units of the currency, so amounts in different
+	// currencies must not be summed together.
+	AmountMoved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "amount_moved_total",
+		Help:      "Amount moved by successful operations, in minor units of the currency.",
+	}, []string{"operation", "currency"})
+
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by a rate limit, by the scope of the limit: client or wallet.",
+	}, []string{"scope"})
+
+	ReconciliationDiscrepancies = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "reconciliation_discrepancies",
+		Help:      "Wallets whose balance differed from their ledger total in the latest reconciliation.",
+	})
+
+	OutboxPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_events_published_total",
+		Help:      "Balance change events published by the outbox relay.",
+	})
+
+	OutboxPublishFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_publish_failures_total",
+		Help:      "Relay runs in which some events failed to publish and were left for a retry.",
+	})
+
+	WebhookAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_attempts_total",
+		Help:      "Webhook delivery attempts by result: delivered, retried or failed for good.",
+	}, []string{"result"})
+)
+
+// ObserveHTTP records one served request. route is the matched route
+// pattern rather than the raw path, to keep the number of series bounded.
+func ObserveHTTP(method, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	HTTPRequests.WithLabelValues(method, route, code).Inc()
+	HTTPRequestDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// ObserveOperation records the outcome of a balance operation. Errors other
+// than insufficient funds are left to the HTTP metrics.
+func ObserveOperation(operation, currency string, amount int64, err error) {
+	switch {
+	case err == nil:
+		Operations.WithLabelValues(operation).Inc()
+		AmountMoved.WithLabelValues(operation, currency).Add(float64(amount))
+	case errors.Is(err, custom_errors.ErrNotEnoughFunds):
+		InsufficientFunds.WithLabelValues(operation).Inc()
+	}
+}
@@ -1,30 +1,57 @@
 package middlewares
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 )
 
-type logger struct {
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
 	http.ResponseWriter
 	statusCode int
 }
 
-func (l *logger) WriteHeader(code int) {
+func (l *statusRecorder) WriteHeader(code int) {
 	l.statusCode = code
 	l.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the flushing and deadlines of
+// the underlying writer, which streaming responses need.
+func (l *statusRecorder) Unwrap() http.ResponseWriter {
+	return l.ResponseWriter
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	if rec, ok := w.(*statusRecorder); ok {
+		return rec
+	}
+	return &statusRecorder{
+		ResponseWriter: w,
+		statusCode:     200,
+	}
+}
+
+// LoggingMiddleware writes a line for every request, at error level for 5xx
+// responses. It must be used on a chi router.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		l := &logger{
-			ResponseWriter: w,
-			statusCode:     200,
-		}
+		l := newStatusRecorder(w)
 		next.ServeHTTP(l, r)
-		log.Printf("LOG: %s %s %d %s\n", r.Method, r.URL.Path, l.statusCode, time.Since(start))
+
+		level := slog.LevelInfo
+		if l.statusCode >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", routePattern(r)),
+			slog.Int("status", l.statusCode),
+			slog.Duration("duration", time.Since(start)),
+		)
 	})
 }
@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+	"wallet-app/pkg/metrics"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// unmatchedRoute labels requests that matched no route, so scanners probing
+// random paths don't create a series per path.
+const unmatchedRoute = "unmatched"
+
+// routePattern returns the pattern of the route that matched r. The pattern
+// is complete only after the router has matched the request, which happens
+// further down the chain, so it must be called after next.ServeHTTP.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+	return unmatchedRoute
+}
+
+// MetricsMiddleware counts requests and their latency per route pattern and
+// status code. It must be used on a chi router.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rec := newStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		metrics.ObserveHTTP(r.Method, routePattern(r), rec.statusCode, time.Since(start))
+	})
+}
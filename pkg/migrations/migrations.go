@@ -0,0 +1,203 @@
+// Package migrations applies the schema embedded in the binary with
+// golang-migrate, keeping its schema_migrations bookkeeping so that databases
+// migrated by the migrate CLI can be taken over as is.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"wallet-app/schema"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratepgx "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+const migrationsDir = "migrations"
+
+// lockID is the key of the advisory lock held while migrating. golang-migrate
+// takes its own lock too, but gives up on it after 15 seconds; this one makes
+// other replicas wait for as long as the migration runs.
+const lockID = 7_357_001
+
+type (
+	Migrator struct {
+		db *sql.DB
+		m  *migrate.Migrate
+	}
+
+	Migration struct {
+		Version uint
+		Name    string
+		Applied bool
+	}
+
+	Status struct {
+		// Version is the last applied migration, 0 if none.
+		Version uint
+		// Dirty means the last migration failed halfway and needs fixing by
+		// hand.
+		Dirty      bool
+		Migrations []Migration
+	}
+)
+
+// defaultCurrencySetting is the Postgres setting the migrations read the
+// default currency from.
+const defaultCurrencySetting = "wallet.default_currency"
+
+// Settings are passed to the migrations through their database session.
+type Settings struct {
+	// DefaultCurrency is the currency given to the wallets that predate
+	// currencies, which should be the one the application creates wallets
+	// in. Empty leaves wallet.default_currency as the server has it.
+	DefaultCurrency string
+}
+
+// New connects to the database at connString. The caller must Close the
+// Migrator.
+func New(connString string, settings Settings) (*Migrator, error) {
+	connCfg, err := pgx.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("parse connection string: %w", err)
+	}
+	if settings.DefaultCurrency != "" {
+		connCfg.RuntimeParams[defaultCurrencySetting] = settings.DefaultCurrency
+	}
+	db := stdlib.OpenDB(*connCfg)
+
+	driver, err := migratepgx.WithInstance(db, &migratepgx.Config{})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open migrations driver: %w", err)
+	}
+
+	src, err := iofs.New(schema.Migrations, migrationsDir)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "pgx5", driver)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init migrations: %w", err)
+	}
+
+	return &Migrator{db: db, m: m}, nil
+}
+
+func (mg *Migrator) Close() error {
+	srcErr, dbErr := mg.m.Close()
+	return errors.Join(srcErr, dbErr, mg.db.Close())
+}
+
+// Up applies all pending migrations.
+func (mg *Migrator) Up(ctx context.Context) error {
+	return mg.withLock(ctx, mg.m.Up)
+}
+
+// UpSteps applies the next steps pending migrations.
+func (mg *Migrator) UpSteps(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("steps must be positive, got %d", steps)
+	}
+	return mg.withLock(ctx, func() error {
+		return mg.m.Steps(steps)
+	})
+}
+
+// Down rolls back the last steps migrations.
+func (mg *Migrator) Down(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("steps must be positive, got %d", steps)
+	}
+	return mg.withLock(ctx, func() error {
+		return mg.m.Steps(-steps)
+	})
+}
+
+// Status reports the applied version and every known migration.
+func (mg *Migrator) Status(ctx context.Context) (Status, error) {
+	var status Status
+
+	version, dirty, err := mg.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return status, fmt.Errorf("get version: %w", err)
+	}
+	status.Version, status.Dirty = version, dirty
+
+	migrations, err := embedded()
+	if err != nil {
+		return status, err
+	}
+	for _, m := range migrations {
+		m.Applied = status.Version != 0 && m.Version <= status.Version
+		status.Migrations = append(status.Migrations, m)
+	}
+	return status, nil
+}
+
+// Latest returns the version of the newest migration in the binary, which a
+// fully migrated database is at.
+func Latest() (uint, error) {
+	migrations, err := embedded()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// withLock runs fn while holding the advisory lock. ErrNoChange is not an
+// error here: it just means another replica got there first.
+func (mg *Migrator) withLock(ctx context.Context, fn func() error) error {
+	conn, err := mg.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migrations lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	if err := fn(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// embedded lists the migrations in the binary, oldest first.
+func embedded() ([]Migration, error) {
+	entries, err := fs.ReadDir(schema.Migrations, migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		m, err := source.DefaultParse(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("parse migration %s: %w", entry.Name(), err)
+		}
+		if m.Direction == source.Up {
+			migrations = append(migrations, Migration{Version: m.Version, Name: m.Identifier})
+		}
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
@@ -0,0 +1,165 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sync"
+	"testing"
+	"wallet-app/schema"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+func TestEmbedded(t *testing.T) {
+	migrations, err := embedded()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	assert.Equal(t, "initial_migration", migrations[0].Name)
+	for i := 1; i < len(migrations); i++ {
+		assert.Less(t, migrations[i-1].Version, migrations[i].Version)
+	}
+
+	latest, err := Latest()
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].Version, latest)
+
+	// Every migration must be reversible.
+	for _, m := range migrations {
+		down := fmt.Sprintf("%s/%d_%s.down.sql", migrationsDir, m.Version, m.Name)
+		_, err := fs.Stat(schema.Migrations, down)
+		assert.NoError(t, err, "missing %s", down)
+	}
+}
+
+func startPG(t *testing.T) (connString string) {
+	t.Helper()
+	ctx := context.Background()
+
+	// testcontainers panics instead of returning an error when there is no
+	// Docker daemon at all.
+	defer func() {
+		if r := recover(); r != nil {
+			t.Skipf("docker is not available: %v", r)
+		}
+	}()
+
+	container, err := postgres.Run(ctx, "postgres:17.5",
+		postgres.WithDatabase("database"),
+		postgres.WithUsername("user"),
+		postgres.WithPassword("password"),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		t.Skipf("postgres is not available: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = testcontainers.TerminateContainer(container)
+	})
+
+	connString, err = container.ConnectionString(ctx, "sslmode=disable")
+	require.NoError(t, err)
+	return connString
+}
+
+func TestUpDown(t *testing.T) {
+	connString := startPG(t)
+	ctx := context.Background()
+
+	migrator, err := New(connString, Settings{})
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	status, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), status.Version)
+	for _, m := range status.Migrations {
+		assert.False(t, m.Applied)
+	}
+
+	require.NoError(t, migrator.Up(ctx))
+	require.NoError(t, migrator.Up(ctx), "nothing to apply is not an error")
+
+	status, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, status.Dirty)
+	assert.Equal(t, status.Migrations[len(status.Migrations)-1].Version, status.Version)
+
+	require.NoError(t, migrator.Down(ctx, 1))
+	status, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, status.Migrations[len(status.Migrations)-2].Version, status.Version)
+	assert.False(t, status.Migrations[len(status.Migrations)-1].Applied)
+
+	require.NoError(t, migrator.Down(ctx, len(status.Migrations)-1))
+	require.NoError(t, migrator.Up(ctx), "migrations must apply again after a full rollback")
+}
+
+func TestConcurrentUp(t *testing.T) {
+	connString := startPG(t)
+	ctx := context.Background()
+
+	// Replicas starting together must not race each other.
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			migrator, err := New(connString, Settings{})
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer migrator.Close()
+			errs <- migrator.Up(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	migrator, err := New(connString, Settings{})
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	status, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, status.Dirty)
+	assert.True(t, status.Migrations[len(status.Migrations)-1].Applied)
+}
+
+func TestDefaultCurrency(t *testing.T) {
+	connString := startPG(t)
+	ctx := context.Background()
+
+	migrator, err := New(connString, Settings{DefaultCurrency: "USD"})
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	// Stop just before the migration that adds currencies.
+	migrations, err := embedded()
+	require.NoError(t, err)
+	var steps int
+	for _, m := range migrations {
+		if m.Name == "wallet_currency" {
+			break
+		}
+		steps++
+	}
+	require.NoError(t, migrator.UpSteps(ctx, steps))
+	_, err = migrator.db.ExecContext(ctx, `INSERT INTO wallets (id) VALUES (gen_random_uuid())`)
+	require.NoError(t, err)
+
+	require.NoError(t, migrator.Up(ctx))
+	var currency string
+	require.NoError(t, migrator.db.QueryRowContext(ctx, `SELECT currency FROM wallets`).Scan(&currency))
+	assert.Equal(t, "USD", currency, "existing wallets get the configured default currency")
+}
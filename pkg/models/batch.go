@@ -0,0 +1,21 @@
+package models
+
+import "github.com/google/uuid"
+
+// BatchOperation is a deposit or withdrawal in a batch. Deposits into a
+// wallet that doesn't exist create it, as single deposits do.
+type BatchOperation struct {
+	WalletID  uuid.UUID
+	Operation string
+	Currency  string
+	Amount    int64
+}
+
+// BatchResult is the outcome of one operation of a batch. Balance and
+// Currency are those of the wallet right after the operation, and are only
+// set if it succeeded.
+type BatchResult struct {
+	Err      error
+	Balance  int64
+	Currency string
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+	"wallet-app/pkg/ratelimit"
+
+	"github.com/google/uuid"
+)
+
+// Client is a caller of the API, identified by its API key. Admin clients
+// can use the admin endpoints and reach every wallet.
+type Client struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Admin     bool      `json:"admin"`
+	CreatedAt time.Time `json:"createdAt"`
+	// RateLimit overrides the default rate limit of clients; nil keeps it.
+	RateLimit *ratelimit.Limit `json:"-"`
+}
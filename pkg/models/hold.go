@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	HoldActive   = "active"
+	HoldCaptured = "captured"
+	HoldReleased = "released"
+	// HoldExpired is never stored: it is reported for an active hold whose
+	// ExpiresAt has passed.
+	HoldExpired = "expired"
+)
+
+// Hold reserves Amount of a wallet's balance without moving it.
+// CapturedAmount is how much of it was withdrawn on capture.
+type Hold struct {
+	ID             uuid.UUID `json:"id"`
+	WalletID       uuid.UUID `json:"walletId"`
+	Currency       string    `json:"currency"`
+	Amount         int64     `json:"amount"`
+	CapturedAmount int64     `json:"capturedAmount"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"createdAt"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// IdempotencyKey ties a client-supplied key to the request it was first used
+// with and to the response that request produced.
+type IdempotencyKey struct {
+	Key         string
+	Fingerprint string
+	StatusCode  int
+	Response    []byte
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of ledger accounts. Every wallet has an account of its own; the
+// system accounts exist once per currency and hold the other side of money
+// entering and leaving the service.
+const (
+	AccountWallet          = "wallet"
+	AccountCashInClearing  = "cash_in_clearing"
+	AccountCashOutClearing = "cash_out_clearing"
+	AccountFees            = "fees"
+)
+
+// SystemAccounts lists the kinds of system accounts, in report order.
+var SystemAccounts = []string{AccountCashInClearing, AccountCashOutClearing, AccountFees}
+
+// Posting moves Amount into or out of an account. Debits are positive and
+// credits negative, so the postings of a journal entry always sum to zero.
+// A wallet is a liability of the service: it is credited when its balance
+// grows.
+type Posting struct {
+	AccountID string `json:"accountId"`
+	Amount    int64  `json:"amount"`
+}
+
+// JournalEntry is one balanced double-entry record of an operation.
+type JournalEntry struct {
+	ID        int64     `json:"id"`
+	Operation string    `json:"operationType"`
+	Postings  []Posting `json:"postings"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TrialBalanceLine totals the debits and credits of all accounts of a kind
+// in one currency. Wallet accounts are summed together.
+type TrialBalanceLine struct {
+	Account  string `json:"account"`
+	Currency string `json:"currency"`
+	Debit    int64  `json:"debit"`
+	Credit   int64  `json:"credit"`
+}
+
+// WalletAccount is the ID of the ledger account of a wallet.
+func WalletAccount(walletID uuid.UUID) string {
+	return walletID.String()
+}
+
+// SystemAccount is the ID of the system account of kind for currency.
+func SystemAccount(kind, currency string) string {
+	return kind + ":" + currency
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of balance change events.
+const (
+	EventDeposited = "wallet.deposited"
+	EventWithdrawn = "wallet.withdrawn"
+	// A transfer records an event for each of its wallets.
+	EventTransferredIn  = "wallet.transferred_in"
+	EventTransferredOut = "wallet.transferred_out"
+)
+
+// Event is a change of a wallet balance, written to the outbox together
+// with the change itself and published to downstream systems from there.
+// IDs increase in the order the changes of a wallet were made.
+type Event struct {
+	ID       int64     `json:"id"`
+	Type     string    `json:"type"`
+	WalletID uuid.UUID `json:"walletId"`
+	// Amount is the unsigned amount of the operation and Balance the
+	// balance of the wallet after it, both in minor units of Currency.
+	Amount    int64     `json:"amount"`
+	Balance   int64     `json:"balance"`
+	Currency  string    `json:"currency"`
+	CreatedAt time.Time `json:"createdAt"`
+}
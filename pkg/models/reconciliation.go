@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reconciliation is a run comparing the stored balance of every wallet with
+// the total of its ledger entries.
+type Reconciliation struct {
+	ID         uuid.UUID `json:"id"`
+	Streaming  bool      `json:"streaming"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	// Wallets is how many wallets were checked, Discrepancies how many of
+	// them had a balance different from their ledger total.
+	Wallets       int64 `json:"wallets"`
+	Discrepancies int64 `json:"discrepancies"`
+}
+
+// Discrepancy is a wallet whose Balance differs from LedgerTotal, the sum of
+// its transactions.
+type Discrepancy struct {
+	WalletID    uuid.UUID `json:"walletId"`
+	Balance     int64     `json:"balance"`
+	LedgerTotal int64     `json:"ledgerTotal"`
+}
+
+// ReconcileOptions choose how a reconciliation reads the wallets. By default
+// they are all checked by a single query. Stream checks them BatchSize at a
+// time instead, each batch in a query of its own, which keeps both memory and
+// the database snapshot small on very large tables.
+type ReconcileOptions struct {
+	Stream    bool
+	BatchSize int
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OperationDeposit        = "deposit"
+	OperationWithdraw       = "withdraw"
+	OperationOpeningBalance = "opening_balance"
+	OperationTransferIn     = "transfer_in"
+	OperationTransferOut    = "transfer_out"
+	// OperationTransfer is the journal entry of both sides of a transfer.
+	OperationTransfer = "transfer"
+)
+
+// Transaction is a single ledger entry. Amount is signed: credits are
+// positive, debits are negative.
+type Transaction struct {
+	ID        int64     `json:"id"`
+	WalletID  uuid.UUID `json:"walletId"`
+	Operation string    `json:"operationType"`
+	Amount    int64     `json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	// CounterpartyID is the other side of a transfer.
+	CounterpartyID *uuid.UUID `json:"counterpartyId,omitempty"`
+}
+
+// TransactionFilter selects a page of a wallet's transactions, newest first.
+// Zero values mean "no restriction".
+type TransactionFilter struct {
+	Operation string
+	From      time.Time
+	To        time.Time
+	Cursor    int64
+	Limit     int
+}
+
+type TransactionPage struct {
+	Transactions []Transaction
+	NextCursor   int64
+	Currency     string
+}
@@ -1,8 +1,37 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
 
+	"github.com/google/uuid"
+)
+
+const (
+	WalletActive = "active"
+	// WalletFrozen wallets can still receive money but can't spend it.
+	WalletFrozen = "frozen"
+	// WalletClosed wallets accept no operations at all.
+	WalletClosed = "closed"
+)
+
+// Wallet's Balance is the ledger balance. Available is what is left of it
+// after subtracting active holds. OwnerID is the client that created the
+// wallet, uuid.Nil for wallets that predate authentication.
 type Wallet struct {
-	ID      uuid.UUID `json:"id"`
-	Balance int       `json:"balance"`
+	ID        uuid.UUID `json:"id"`
+	OwnerID   uuid.UUID `json:"ownerId"`
+	Balance   int64     `json:"balance"`
+	Available int64     `json:"available"`
+	Currency  string    `json:"currency"`
+	Status    string    `json:"status"`
+}
+
+// WalletStatusChange records why a wallet was moved from one status to
+// another.
+type WalletStatusChange struct {
+	WalletID  uuid.UUID `json:"walletId"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"`
 }
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventTypes lists the types of events webhooks can subscribe to.
+var EventTypes = []string{EventDeposited, EventWithdrawn, EventTransferredIn, EventTransferredOut}
+
+// Webhook is a URL a client wants the events of its wallets POSTed to.
+// Admin clients get the events of every wallet.
+type Webhook struct {
+	ID         uuid.UUID `json:"id"`
+	ClientID   uuid.UUID `json:"clientId"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"eventTypes"`
+	// Secret signs the deliveries, so the receiver can tell they came from
+	// us.
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Statuses of a webhook delivery. A delivery is retried while pending and
+// ends up delivered or, once it runs out of attempts, failed.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is the delivery of an event to a webhook.
+type WebhookDelivery struct {
+	ID        int64     `json:"id"`
+	WebhookID uuid.UUID `json:"webhookId"`
+	Event     Event     `json:"event"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	// NextAttemptAt is when a pending delivery is tried next.
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	// LastError and LastStatusCode describe the last failed attempt; the
+	// status code is zero if no response was received.
+	LastError      string    `json:"lastError"`
+	LastStatusCode int       `json:"lastStatusCode"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
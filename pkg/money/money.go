@@ -0,0 +1,96 @@
+package money
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"wallet-app/pkg/currency"
+	custom_errors "wallet-app/pkg/errors"
+)
+
+// Parse converts a decimal string such as "10.50" into minor units of the
+// given currency without going through floating point. The number of digits
+// after the decimal point may not exceed the currency's precision, and
+// currencies with minor units require the decimal point to be present.
+func Parse(value, code string) (int64, error) {
+	digits, ok := currency.MinorUnits(code)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", custom_errors.ErrUnsupportedCurrency, code)
+	}
+
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+
+	whole, fraction, hasPoint := strings.Cut(value, ".")
+	if !isDigits(whole) || (hasPoint && !isDigits(fraction)) {
+		return 0, invalidFormat(digits)
+	}
+	if len(fraction) > digits {
+		return 0, fmt.Errorf("%w: %s allows at most %d", custom_errors.ErrTooManyDecimals, code, digits)
+	}
+	if digits > 0 && !hasPoint {
+		return 0, invalidFormat(digits)
+	}
+
+	var amount int64
+	for _, r := range whole + fraction + strings.Repeat("0", digits-len(fraction)) {
+		d := int64(r - '0')
+		if amount > (math.MaxInt64-d)/10 {
+			return 0, custom_errors.ErrAmountOverflow
+		}
+		amount = amount*10 + d
+	}
+
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// Format renders an amount in minor units as a decimal string using the
+// precision of the given currency, e.g. 1050 EUR -> "10.50", 1050 JPY -> "1050".
+// Unknown currencies are formatted with two decimals.
+func Format(amount int64, code string) string {
+	digits, ok := currency.MinorUnits(code)
+	if !ok {
+		digits = 2
+	}
+
+	sign := ""
+	abs := uint64(amount)
+	if amount < 0 {
+		sign = "-"
+		abs = -abs
+	}
+
+	amountStr := strconv.FormatUint(abs, 10)
+	if digits == 0 {
+		return sign + amountStr
+	}
+
+	if len(amountStr) <= digits {
+		amountStr = strings.Repeat("0", digits-len(amountStr)+1) + amountStr
+	}
+	return sign + amountStr[:len(amountStr)-digits] + "." + amountStr[len(amountStr)-digits:]
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func invalidFormat(digits int) error {
+	example := "100"
+	if digits > 0 {
+		example += "." + strings.Repeat("0", digits)
+	}
+	return fmt.Errorf("%w: expected a decimal number like %s", custom_errors.ErrInvalidAmount, example)
+}
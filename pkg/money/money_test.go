@@ -0,0 +1,78 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+	custom_errors "wallet-app/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		want     int64
+		wantErr  error
+	}{
+		{value: "10.50", currency: "EUR", want: 1050},
+		{value: "10.5", currency: "EUR", want: 1050},
+		{value: "0.01", currency: "EUR", want: 1},
+		{value: "100.00", currency: "RUB", want: 10000},
+		{value: "-3.20", currency: "USD", want: -320},
+		{value: "1500", currency: "JPY", want: 1500},
+		{value: "1.234", currency: "KWD", want: 1234},
+		{value: "1.2", currency: "KWD", want: 1200},
+		{value: "92233720368547758.07", currency: "EUR", want: math.MaxInt64},
+		{value: "10.555", currency: "EUR", wantErr: custom_errors.ErrTooManyDecimals},
+		{value: "1500.0", currency: "JPY", wantErr: custom_errors.ErrTooManyDecimals},
+		{value: "1.2345", currency: "KWD", wantErr: custom_errors.ErrTooManyDecimals},
+		{value: "92233720368547758.08", currency: "EUR", wantErr: custom_errors.ErrAmountOverflow},
+		{value: "99999999999999999999", currency: "JPY", wantErr: custom_errors.ErrAmountOverflow},
+		{value: "5000", currency: "EUR", wantErr: custom_errors.ErrInvalidAmount},
+		{value: "", currency: "EUR", wantErr: custom_errors.ErrInvalidAmount},
+		{value: "10.", currency: "EUR", wantErr: custom_errors.ErrInvalidAmount},
+		{value: ".50", currency: "EUR", wantErr: custom_errors.ErrInvalidAmount},
+		{value: "1e3", currency: "EUR", wantErr: custom_errors.ErrInvalidAmount},
+		{value: "+1.00", currency: "EUR", wantErr: custom_errors.ErrInvalidAmount},
+		{value: "1,00", currency: "EUR", wantErr: custom_errors.ErrInvalidAmount},
+		{value: "1.00", currency: "XYZ", wantErr: custom_errors.ErrUnsupportedCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value+" "+tt.currency, func(t *testing.T) {
+			got, err := Parse(tt.value, tt.currency)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got error %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		currency string
+		want     string
+	}{
+		{amount: 1050, currency: "EUR", want: "10.50"},
+		{amount: 5, currency: "EUR", want: "0.05"},
+		{amount: 0, currency: "EUR", want: "0.00"},
+		{amount: -320, currency: "USD", want: "-3.20"},
+		{amount: 1500, currency: "JPY", want: "1500"},
+		{amount: 1234, currency: "KWD", want: "1.234"},
+		{amount: 7, currency: "KWD", want: "0.007"},
+		{amount: math.MaxInt64, currency: "EUR", want: "92233720368547758.07"},
+		{amount: math.MinInt64, currency: "EUR", want: "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want+" "+tt.currency, func(t *testing.T) {
+			assert.Equal(t, tt.want, Format(tt.amount, tt.currency))
+		})
+	}
+}
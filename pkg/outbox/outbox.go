@@ -0,0 +1,138 @@
+// Package outbox holds the publishers the outbox relay hands balance change
+// events to. The relay publishes every event at least once, so whatever
+// consumes them must tolerate duplicates; the events of a wallet arrive in
+// order.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"wallet-app/pkg/models"
+)
+
+// Publishers.
+const (
+	PublisherInProcess = "inprocess"
+	PublisherStdout    = "stdout"
+	PublisherFile      = "file"
+)
+
+type Publisher interface {
+	// Publish hands event on. It is retried until it succeeds.
+	Publish(ctx context.Context, event models.Event) error
+}
+
+type Config struct {
+	// Publisher is one of the Publisher constants.
+	Publisher string
+	// File is where the file publisher appends events, one JSON object per
+	// line.
+	File string
+}
+
+// New creates the publisher chosen by cfg. The returned function releases
+// it and must be called once the relay has stopped.
+func New(cfg Config) (Publisher, func() error, error) {
+	noop := func() error { return nil }
+
+	switch cfg.Publisher {
+	case PublisherInProcess, "":
+		return NewBus(), noop, nil
+	case PublisherStdout:
+		return NewWriter(os.Stdout), noop, nil
+	case PublisherFile:
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open outbox file: %w", err)
+		}
+		return NewWriter(file), file.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown outbox publisher %q", cfg.Publisher)
+	}
+}
+
+// Handler consumes the events of a Bus. It is also a Publisher.
+type Handler func(ctx context.Context, event models.Event) error
+
+func (h Handler) Publish(ctx context.Context, event models.Event) error {
+	return h(ctx, event)
+}
+
+// Multi publishes every event to each of publishers. If any of them fails,
+// the event is published again to all of them.
+func Multi(publishers ...Publisher) Publisher {
+	return Handler(func(ctx context.Context, event models.Event) error {
+		var errs []error
+		for _, p := range publishers {
+			errs = append(errs, p.Publish(ctx, event))
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// Bus delivers events to the handlers subscribed in the same process.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[int]Handler
+	next     int
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[int]Handler)}
+}
+
+// Subscribe adds h to the handlers of b until the returned function is
+// called.
+func (b *Bus) Subscribe(h Handler) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	b.next++
+	b.handlers[id] = h
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+// Publish calls every handler with event. If any of them fails, the event is
+// published again to all of them.
+func (b *Bus) Publish(ctx context.Context, event models.Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var errs []error
+	for _, h := range b.handlers {
+		errs = append(errs, h(ctx, event))
+	}
+	return errors.Join(errs...)
+}
+
+// Writer writes each event to an io.Writer as a line of JSON.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Publish(ctx context.Context, event models.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.w.Write(append(line, '\n'))
+	return err
+}
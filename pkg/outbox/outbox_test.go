@@ -0,0 +1,95 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	event := models.Event{ID: 1, Type: models.EventDeposited, WalletID: uuid.New(), Amount: 100, Balance: 100, Currency: "RUB"}
+
+	var got []models.Event
+	unsubscribe := bus.Subscribe(func(ctx context.Context, e models.Event) error {
+		got = append(got, e)
+		return nil
+	})
+	require.NoError(t, bus.Publish(t.Context(), event))
+	assert.Equal(t, []models.Event{event}, got)
+
+	failing := errors.New("unavailable")
+	unsubscribeFailing := bus.Subscribe(func(ctx context.Context, e models.Event) error { return failing })
+	assert.ErrorIs(t, bus.Publish(t.Context(), event), failing)
+	assert.Len(t, got, 2, "the other handlers get the event anyway")
+
+	unsubscribeFailing()
+	unsubscribe()
+	assert.NoError(t, bus.Publish(t.Context(), event))
+	assert.Len(t, got, 2)
+}
+
+func TestMulti(t *testing.T) {
+	var calls int
+	ok := Handler(func(ctx context.Context, e models.Event) error {
+		calls++
+		return nil
+	})
+	failing := errors.New("unavailable")
+
+	assert.NoError(t, Multi(ok, ok).Publish(t.Context(), models.Event{ID: 1}))
+	assert.Equal(t, 2, calls)
+
+	err := Multi(Handler(func(ctx context.Context, e models.Event) error { return failing }), ok).Publish(t.Context(), models.Event{ID: 1})
+	assert.ErrorIs(t, err, failing)
+	assert.Equal(t, 3, calls, "the other publishers get the event anyway")
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	events := []models.Event{
+		{ID: 1, Type: models.EventDeposited, WalletID: uuid.New(), Amount: 100, Balance: 100, Currency: "RUB"},
+		{ID: 2, Type: models.EventWithdrawn, WalletID: uuid.New(), Amount: 40, Balance: 60, Currency: "RUB"},
+	}
+	for _, e := range events {
+		require.NoError(t, w.Publish(t.Context(), e))
+	}
+
+	dec := json.NewDecoder(&buf)
+	for _, want := range events {
+		var got models.Event
+		require.NoError(t, dec.Decode(&got))
+		assert.Equal(t, want, got)
+	}
+	assert.False(t, dec.More())
+}
+
+func TestNew(t *testing.T) {
+	p, closePublisher, err := New(Config{Publisher: PublisherInProcess})
+	require.NoError(t, err)
+	assert.IsType(t, &Bus{}, p)
+	assert.NoError(t, closePublisher())
+
+	file := filepath.Join(t.TempDir(), "events.jsonl")
+	p, closePublisher, err = New(Config{Publisher: PublisherFile, File: file})
+	require.NoError(t, err)
+	require.NoError(t, p.Publish(t.Context(), models.Event{ID: 1}))
+	require.NoError(t, closePublisher())
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"id":1`)
+
+	_, _, err = New(Config{Publisher: "kafka"})
+	assert.Error(t, err)
+}
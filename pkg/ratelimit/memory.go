@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SweepInterval is how often stores drop the buckets that have refilled:
+// a full bucket is the same as no bucket.
+const SweepInterval = time.Minute
+
+type memoryBucket struct {
+	Bucket
+	fullAt time.Time
+}
+
+// MemoryStore keeps the buckets of a single instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]memoryBucket),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, keys []string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= SweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.fullAt) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	keys = slices.Compact(slices.Sorted(slices.Values(keys)))
+	buckets := make([]Bucket, len(keys))
+	for i, key := range keys {
+		buckets[i] = s.buckets[key].Bucket
+	}
+	buckets, res := TakeAll(buckets, limit, now)
+	for i, key := range keys {
+		s.buckets[key] = memoryBucket{Bucket: buckets[i], fullAt: buckets[i].FullAt(limit)}
+	}
+	return res, nil
+}
@@ -0,0 +1,104 @@
+// Package ratelimit implements token bucket rate limits. The buckets are
+// kept in a Store: in memory for a single instance, or in Postgres when
+// several instances have to share the limits.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Stores.
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+)
+
+// Limit is a token bucket: it holds up to Burst requests and refills at Rate
+// requests per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled reports whether l limits anything; a zero Limit doesn't.
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// Bucket is the state of one bucket. The zero Bucket is full.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket size.
+	Limit int
+	// Remaining is the number of requests that can be made right away.
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a token is available; zero if Allowed.
+	RetryAfter time.Duration
+}
+
+// Take refills b up to now and takes a token from it if there is one. It
+// returns the new state of the bucket, which stores must save even if the
+// request is not allowed.
+func Take(b Bucket, limit Limit, now time.Time) (Bucket, Result) {
+	buckets, res := TakeAll([]Bucket{b}, limit, now)
+	return buckets[0], res
+}
+
+// TakeAll is Take on several buckets with the same limit: it takes a token
+// from each of them only if they all have one, so that a request denied by
+// one of the buckets doesn't use up the others. The result is that of the
+// most restrictive bucket.
+func TakeAll(buckets []Bucket, limit Limit, now time.Time) ([]Bucket, Result) {
+	burst := float64(limit.Burst)
+	tokens := make([]float64, len(buckets))
+	allowed := true
+	for i, b := range buckets {
+		tokens[i] = burst
+		if !b.Updated.IsZero() {
+			// Clocks of different instances may disagree; never refill backwards.
+			elapsed := max(now.Sub(b.Updated).Seconds(), 0)
+			tokens[i] = math.Min(burst, b.Tokens+elapsed*limit.Rate)
+		}
+		allowed = allowed && tokens[i] >= 1
+	}
+
+	res := Result{Allowed: allowed, Limit: limit.Burst, Remaining: limit.Burst}
+	updated := make([]Bucket, len(buckets))
+	for i := range buckets {
+		if allowed {
+			tokens[i]--
+		} else if tokens[i] < 1 {
+			res.RetryAfter = max(res.RetryAfter, seconds((1-tokens[i])/limit.Rate))
+		}
+		res.Remaining = min(res.Remaining, int(tokens[i]))
+		res.Reset = max(res.Reset, seconds((burst-tokens[i])/limit.Rate))
+		updated[i] = Bucket{Tokens: tokens[i], Updated: now}
+	}
+	return updated, res
+}
+
+// FullAt returns when b, as last updated, refills completely.
+func (b Bucket) FullAt(limit Limit) time.Time {
+	return b.Updated.Add(seconds((float64(limit.Burst) - b.Tokens) / limit.Rate))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// Store keeps the buckets, identified by key.
+type Store interface {
+	// Take takes a token from each of the buckets keys, as TakeAll does,
+	// creating the buckets full if they don't exist yet.
+	Take(ctx context.Context, keys []string, limit Limit) (Result, error)
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTake(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 3}
+	now := time.Date(2025, 9, 15, 12, 0, 0, 0, time.UTC)
+
+	var (
+		b   Bucket
+		res Result
+	)
+	for i := range 3 {
+		b, res = Take(b, limit, now)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 3, res.Limit)
+		assert.Equal(t, 2-i, res.Remaining)
+	}
+	assert.Equal(t, 1500*time.Millisecond, res.Reset)
+
+	b, res = Take(b, limit, now)
+	assert.False(t, res.Allowed, "the burst is used up")
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	b, res = Take(b, limit, now.Add(250*time.Millisecond))
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 250*time.Millisecond, res.RetryAfter, "a denied request doesn't cost a token")
+
+	b, res = Take(b, limit, now.Add(500*time.Millisecond))
+	assert.True(t, res.Allowed)
+	assert.Zero(t, res.RetryAfter)
+
+	_, res = Take(b, limit, now.Add(time.Hour))
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Remaining, "the bucket refills only up to the burst")
+
+	_, res = Take(Bucket{Tokens: 0, Updated: now}, limit, now.Add(-time.Second))
+	assert.False(t, res.Allowed, "a clock going backwards doesn't refill the bucket")
+}
+
+func TestTakeAll(t *testing.T) {
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Date(2025, 9, 15, 12, 0, 0, 0, time.UTC)
+	empty := Bucket{Tokens: 0.5, Updated: now}
+
+	buckets, res := TakeAll([]Bucket{{}, empty}, limit, now)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, 2.0, buckets[0].Tokens, "no token is taken unless all the buckets have one")
+
+	buckets, res = TakeAll(buckets, limit, now.Add(time.Second))
+	assert.True(t, res.Allowed)
+	assert.Equal(t, []float64{1, 0.5}, []float64{buckets[0].Tokens, buckets[1].Tokens})
+	assert.Equal(t, 0, res.Remaining, "the result is that of the most restrictive bucket")
+	assert.Equal(t, 1500*time.Millisecond, res.Reset)
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2025, 9, 15, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 1}
+
+	res, err := s.Take(t.Context(), []string{"a"}, limit)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	res, err = s.Take(t.Context(), []string{"a"}, limit)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+
+	res, err = s.Take(t.Context(), []string{"b"}, limit)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed, "buckets are kept per key")
+
+	now = now.Add(SweepInterval)
+	res, err = s.Take(t.Context(), []string{"a"}, limit)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Len(t, s.buckets, 1, "refilled buckets are dropped")
+}
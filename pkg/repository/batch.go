@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type batchResponseCtx struct{}
+
+// WithBatchResponse makes Batch store the response rendered by render from
+// its results under the idempotency key of ctx. Unlike other calls, the
+// response of a batch can't be known before it runs.
+func WithBatchResponse(ctx context.Context, render func([]models.BatchResult) ([]byte, error)) context.Context {
+	return context.WithValue(ctx, batchResponseCtx{}, render)
+}
+
+// BatchResponseFromContext returns the renderer set by WithBatchResponse.
+func BatchResponseFromContext(ctx context.Context) (render func([]models.BatchResult) ([]byte, error), ok bool) {
+	render, ok = ctx.Value(batchResponseCtx{}).(func([]models.BatchResult) ([]byte, error))
+	return render, ok
+}
+
+// batchResponse renders the response to store with the idempotency key of
+// ctx, if there is one. ok is false if the key keeps its response.
+func batchResponse(ctx context.Context, results []models.BatchResult) (_ models.IdempotencyKey, ok bool, err error) {
+	key, hasKey := idempotencyKeyFromContext(ctx)
+	render, hasRender := BatchResponseFromContext(ctx)
+	if !hasKey || !hasRender {
+		return key, false, nil
+	}
+
+	key.Response, err = render(results)
+	if err != nil {
+		return key, false, fmt.Errorf("render batch response: %w", err)
+	}
+	return key, true, nil
+}
+
+// batchWallet is a wallet as seen by the operations of a batch.
+type batchWallet struct {
+	models.Wallet
+	held int64
+	// created is set for wallets created by a deposit of the batch.
+	created bool
+}
+
+// batchWalletIDs returns the distinct wallets of ops in the order they are
+// locked in, which is the same as for transfers so that batches and
+// transfers can't deadlock each other.
+func batchWalletIDs(ops []models.BatchOperation) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(ops))
+	for _, op := range ops {
+		ids = append(ids, op.WalletID)
+	}
+	slices.SortFunc(ids, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	return slices.Compact(ids)
+}
+
+// applyBatch runs ops in order against wallets, which holds the existing
+// wallets of the batch and receives the ones it creates. It returns the
+// result of each operation and the transactions to record for those that
+// succeeded. If atomic, the first failure ends the batch with a BatchError.
+func applyBatch(ctx context.Context, ops []models.BatchOperation, atomic bool, wallets map[uuid.UUID]*batchWallet) ([]models.BatchResult, []models.Transaction, error) {
+	results := make([]models.BatchResult, len(ops))
+	transactions := make([]models.Transaction, 0, len(ops))
+
+	for i, op := range ops {
+		wallet, err := applyBatchOperation(ctx, op, wallets)
+		if err != nil {
+			if atomic {
+				return nil, nil, &custom_errors.BatchError{Index: i, Err: err}
+			}
+			results[i].Err = err
+			continue
+		}
+
+		amount := op.Amount
+		if op.Operation == models.OperationWithdraw {
+			amount = -amount
+		}
+		wallet.Balance += amount
+		transactions = append(transactions, models.Transaction{
+			WalletID:  op.WalletID,
+			Operation: op.Operation,
+			Amount:    amount,
+		})
+		results[i] = models.BatchResult{Balance: wallet.Balance, Currency: wallet.Currency}
+	}
+	return results, transactions, nil
+}
+
+// applyBatchOperation checks op against its wallet, creating it for a
+// deposit if needed, and returns the wallet to apply op to.
+func applyBatchOperation(ctx context.Context, op models.BatchOperation, wallets map[uuid.UUID]*batchWallet) (*batchWallet, error) {
+	wallet, ok := wallets[op.WalletID]
+
+	switch op.Operation {
+	case models.OperationDeposit:
+		if !ok {
+			wallet = &batchWallet{
+				Wallet: models.Wallet{
+					ID:       op.WalletID,
+					OwnerID:  ownerFromContext(ctx),
+					Currency: op.Currency,
+					Status:   models.WalletActive,
+				},
+				created: true,
+			}
+			wallets[op.WalletID] = wallet
+		}
+		if err := checkCanReceive(wallet.Status); err != nil {
+			return nil, err
+		}
+		if err := checkCurrency(op.Currency, wallet.Currency); err != nil {
+			return nil, err
+		}
+		if err := checkOverflow(wallet.Balance, op.Amount); err != nil {
+			return nil, err
+		}
+	case models.OperationWithdraw:
+		if !ok {
+			return nil, custom_errors.ErrWalletNotFound
+		}
+		if err := checkCanSpend(wallet.Status); err != nil {
+			return nil, err
+		}
+		if err := checkCurrency(op.Currency, wallet.Currency); err != nil {
+			return nil, err
+		}
+		if wallet.Balance-wallet.held < op.Amount {
+			return nil, custom_errors.ErrNotEnoughFunds
+		}
+	default:
+		return nil, fmt.Errorf("unknown batch operation %q", op.Operation)
+	}
+	return wallet, nil
+}
+
+func (pg *postgresDB) Batch(ctx context.Context, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, error) {
+	ids := batchWalletIDs(ops)
+
+	querySelect := `SELECT id, owner_id, balance, currency, status FROM wallets WHERE id = ANY(@walletIDs) ORDER BY id FOR UPDATE`
+	queryHeld := `SELECT wallet_id, SUM(amount)::BIGINT FROM holds
+		WHERE wallet_id = ANY(@walletIDs) AND status = 'active' AND expires_at > now()
+		GROUP BY wallet_id`
+	args := pgx.NamedArgs{"walletIDs": ids}
+
+	tx, err := pg.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := claimIdempotencyKey(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, querySelect, args)
+	if err != nil {
+		return nil, fmt.Errorf("select for update: %w", err)
+	}
+	wallets := make(map[uuid.UUID]*batchWallet, len(ids))
+	var (
+		wallet  models.Wallet
+		ownerID uuid.NullUUID
+	)
+	_, err = pgx.ForEachRow(rows, []any{&wallet.ID, &ownerID, &wallet.Balance, &wallet.Currency, &wallet.Status}, func() error {
+		wallet.OwnerID = ownerID.UUID
+		wallets[wallet.ID] = &batchWallet{Wallet: wallet}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select for update: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, queryHeld, args)
+	if err != nil {
+		return nil, fmt.Errorf("get held amount: %w", err)
+	}
+	var (
+		walletID uuid.UUID
+		held     int64
+	)
+	_, err = pgx.ForEachRow(rows, []any{&walletID, &held}, func() error {
+		wallets[walletID].held = held
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get held amount: %w", err)
+	}
+
+	results, transactions, err := applyBatch(ctx, ops, atomic, wallets)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		wallet, ok := wallets[id]
+		if !ok {
+			continue
+		}
+		if wallet.created {
+			query := `INSERT INTO wallets (id, owner_id, balance, currency) VALUES (@walletID, @ownerID, @balance, @currency)`
+			args := pgx.NamedArgs{"walletID": id, "ownerID": nullUUID(wallet.OwnerID), "balance": wallet.Balance, "currency": wallet.Currency}
+			if _, err := tx.Exec(ctx, query, args); err != nil {
+				return nil, fmt.Errorf("create wallet: %w", mapPGError(err))
+			}
+			continue
+		}
+		if _, err := tx.Exec(ctx, `UPDATE wallets SET balance = $1 WHERE id = $2`, wallet.Balance, id); err != nil {
+			return nil, fmt.Errorf("update balance: %w", mapPGError(err))
+		}
+	}
+
+	for _, t := range transactions {
+		if err := recordTransaction(ctx, tx, t); err != nil {
+			return nil, err
+		}
+		if err := postEntry(ctx, tx, t.Operation, cashPostings(t, wallets[t.WalletID].Currency)); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range batchEvents(transactions, wallets) {
+		if err := recordEvent(ctx, tx, e); err != nil {
+			return nil, err
+		}
+	}
+
+	key, ok, err := batchResponse(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if _, err := tx.Exec(ctx, `UPDATE idempotency_keys SET response = $1 WHERE key = $2`, key.Response, key.Key); err != nil {
+			return nil, fmt.Errorf("save batch response: %w", err)
+		}
+	}
+
+	return results, tx.Commit(ctx)
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/ratelimit"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type ownerCtx struct{}
+
+// WithOwner makes the wallets created with ctx owned by the client ownerID.
+func WithOwner(ctx context.Context, ownerID uuid.UUID) context.Context {
+	return context.WithValue(ctx, ownerCtx{}, ownerID)
+}
+
+// ownerFromContext returns the owner set by WithOwner, or uuid.Nil.
+func ownerFromContext(ctx context.Context) uuid.UUID {
+	ownerID, _ := ctx.Value(ownerCtx{}).(uuid.UUID)
+	return ownerID
+}
+
+// nullUUID stores uuid.Nil as NULL.
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+func (pg *postgresDB) CreateClient(ctx context.Context, client models.Client, keyHash string) error {
+	query := `INSERT INTO api_clients (id, name, key_hash, admin, created_at, rate_limit, rate_burst)
+		VALUES (@id, @name, @keyHash, @admin, @createdAt, @rateLimit, @rateBurst)`
+	args := pgx.NamedArgs{
+		"id":        client.ID,
+		"name":      client.Name,
+		"keyHash":   keyHash,
+		"admin":     client.Admin,
+		"createdAt": client.CreatedAt,
+		"rateLimit": nil,
+		"rateBurst": nil,
+	}
+	if client.RateLimit != nil {
+		args["rateLimit"] = client.RateLimit.Rate
+		args["rateBurst"] = client.RateLimit.Burst
+	}
+
+	if _, err := pg.db.Exec(ctx, query, args); err != nil {
+		return fmt.Errorf("create client: %w", err)
+	}
+	return nil
+}
+
+func (pg *postgresDB) GetClientByKeyHash(ctx context.Context, keyHash string) (models.Client, error) {
+	query := `SELECT id, name, admin, created_at, rate_limit, rate_burst FROM api_clients
+		WHERE key_hash = $1 AND revoked_at IS NULL`
+
+	var (
+		client    models.Client
+		rateLimit *float64
+		rateBurst *int
+	)
+	err := pg.db.QueryRow(ctx, query, keyHash).Scan(&client.ID, &client.Name, &client.Admin, &client.CreatedAt, &rateLimit, &rateBurst)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return client, custom_errors.ErrClientNotFound
+	}
+	if err != nil {
+		return client, fmt.Errorf("get client: %w", err)
+	}
+	if rateLimit != nil && rateBurst != nil {
+		client.RateLimit = &ratelimit.Limit{Rate: *rateLimit, Burst: *rateBurst}
+	}
+	return client, nil
+}
+
+func (pg *postgresDB) RevokeClient(ctx context.Context, clientID uuid.UUID) error {
+	tag, err := pg.db.Exec(ctx, `UPDATE api_clients SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, clientID)
+	if err != nil {
+		return fmt.Errorf("revoke client: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return custom_errors.ErrClientNotFound
+	}
+	return nil
+}
@@ -0,0 +1,837 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/ratelimit"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// runConformance checks the behaviour every Database implementation must
+// share, so that the in-memory backend can stand in for Postgres in tests.
+func runConformance(t *testing.T, db Database) {
+	t.Run("wallet not found", func(t *testing.T) {
+		walletID := uuid.New()
+
+		_, err := db.GetBalance(ctx, walletID)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletNotFound))
+
+		err = db.Deposit(ctx, walletID, "RUB", 100)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletNotFound))
+
+		err = db.Withdraw(ctx, walletID, "RUB", 100)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletNotFound))
+
+		_, err = db.ListTransactions(ctx, walletID, models.TransactionFilter{Limit: 10})
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletNotFound))
+	})
+
+	t.Run("new wallet", func(t *testing.T) {
+		walletID := uuid.New()
+
+		assert.NoError(t, db.NewWallet(ctx, walletID, "RUB", 1000))
+
+		balance, err := db.GetBalance(ctx, walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1000), balance)
+
+		err = db.NewWallet(ctx, walletID, "RUB", 1000)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletExists))
+
+		err = db.NewWallet(ctx, uuid.New(), "RUB", -1)
+		assert.True(t, errors.Is(err, custom_errors.ErrNegativeBalance))
+	})
+
+	t.Run("deposit and withdraw", func(t *testing.T) {
+		walletID := uuid.New()
+
+		assert.NoError(t, db.NewWallet(ctx, walletID, "RUB", 1000))
+		assert.NoError(t, db.Deposit(ctx, walletID, "RUB", 500))
+		assert.NoError(t, db.Withdraw(ctx, walletID, "RUB", 1200))
+
+		err := db.Withdraw(ctx, walletID, "RUB", 301)
+		assert.True(t, errors.Is(err, custom_errors.ErrNotEnoughFunds))
+
+		err = db.Deposit(ctx, walletID, "RUB", -301)
+		assert.True(t, errors.Is(err, custom_errors.ErrNegativeBalance))
+
+		balance, err := db.GetBalance(ctx, walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(300), balance)
+	})
+
+	t.Run("transfer", func(t *testing.T) {
+		fromID := uuid.New()
+		toID := uuid.New()
+
+		assert.NoError(t, db.NewWallet(ctx, fromID, "RUB", 1000))
+		assert.NoError(t, db.NewWallet(ctx, toID, "RUB", 0))
+		assert.NoError(t, db.Transfer(ctx, fromID, toID, "RUB", 400))
+
+		err := db.Transfer(ctx, fromID, toID, "RUB", 601)
+		assert.True(t, errors.Is(err, custom_errors.ErrNotEnoughFunds))
+
+		err = db.Transfer(ctx, fromID, uuid.New(), "RUB", 1)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletNotFound))
+
+		err = db.Transfer(ctx, fromID, fromID, "RUB", 1)
+		assert.True(t, errors.Is(err, custom_errors.ErrSameWallet))
+
+		fromBalance, err := db.GetBalance(ctx, fromID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(600), fromBalance)
+
+		toBalance, err := db.GetBalance(ctx, toID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(400), toBalance)
+
+		transactions, err := db.ListTransactions(ctx, toID, models.TransactionFilter{Limit: 10})
+		assert.NoError(t, err)
+		if assert.Len(t, transactions, 1) {
+			assert.Equal(t, models.OperationTransferIn, transactions[0].Operation)
+			assert.Equal(t, &fromID, transactions[0].CounterpartyID)
+		}
+	})
+
+	t.Run("currency", func(t *testing.T) {
+		eurID := uuid.New()
+		rubID := uuid.New()
+
+		assert.NoError(t, db.NewWallet(ctx, eurID, "EUR", 1000))
+		assert.NoError(t, db.NewWallet(ctx, rubID, "RUB", 1000))
+
+		wallet, err := db.GetWallet(ctx, eurID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.Wallet{ID: eurID, Balance: 1000, Available: 1000, Currency: "EUR", Status: models.WalletActive}, wallet)
+
+		_, err = db.GetWallet(ctx, uuid.New())
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletNotFound))
+
+		err = db.Deposit(ctx, eurID, "USD", 100)
+		assert.True(t, errors.Is(err, custom_errors.ErrCurrencyMismatch))
+
+		err = db.Withdraw(ctx, eurID, "RUB", 100)
+		assert.True(t, errors.Is(err, custom_errors.ErrCurrencyMismatch))
+
+		err = db.Transfer(ctx, eurID, rubID, "", 100)
+		assert.True(t, errors.Is(err, custom_errors.ErrCurrencyMismatch))
+
+		assert.NoError(t, db.Deposit(ctx, eurID, "", 100))
+
+		balance, err := db.GetBalance(ctx, eurID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1100), balance)
+	})
+
+	t.Run("list transactions", func(t *testing.T) {
+		walletID := uuid.New()
+
+		assert.NoError(t, db.NewWallet(ctx, walletID, "RUB", 100))
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, db.Deposit(ctx, walletID, "RUB", 10))
+		}
+		assert.NoError(t, db.Withdraw(ctx, walletID, "RUB", 50))
+
+		page, err := db.ListTransactions(ctx, walletID, models.TransactionFilter{Limit: 4})
+		assert.NoError(t, err)
+		assert.Len(t, page, 4)
+		assert.Equal(t, models.OperationWithdraw, page[0].Operation)
+		assert.Equal(t, int64(-50), page[0].Amount)
+
+		rest, err := db.ListTransactions(ctx, walletID, models.TransactionFilter{Cursor: page[3].ID, Limit: 4})
+		assert.NoError(t, err)
+		assert.Len(t, rest, 3)
+
+		withdrawals, err := db.ListTransactions(ctx, walletID, models.TransactionFilter{Operation: models.OperationWithdraw, Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, withdrawals, 1)
+
+		future, err := db.ListTransactions(ctx, walletID, models.TransactionFilter{From: page[0].CreatedAt.Add(time.Millisecond), Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, future)
+	})
+
+	t.Run("balance as of", func(t *testing.T) {
+		walletID := uuid.New()
+
+		assert.NoError(t, db.NewWallet(ctx, walletID, "RUB", 100))
+		assert.NoError(t, db.Deposit(ctx, walletID, "RUB", 50))
+		assert.NoError(t, db.Withdraw(ctx, walletID, "RUB", 30))
+
+		txs, err := db.ListTransactions(ctx, walletID, models.TransactionFilter{Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, txs, 3)
+		opened, deposited, withdrawn := txs[2].CreatedAt, txs[1].CreatedAt, txs[0].CreatedAt
+
+		check := func() {
+			for at, want := range map[time.Time]int64{
+				opened.Add(-time.Millisecond): 0,
+				opened:                        100,
+				deposited:                     150,
+				withdrawn:                     120,
+				withdrawn.Add(time.Hour):      120,
+			} {
+				balance, err := db.GetBalanceAt(ctx, walletID, at)
+				assert.NoError(t, err)
+				assert.Equal(t, want, balance, "as of %v", at)
+			}
+		}
+		check()
+
+		// Snapshots change how balances are computed, not what they are.
+		_, err = db.TakeBalanceSnapshots(ctx, deposited)
+		assert.NoError(t, err)
+		check()
+		_, err = db.TakeBalanceSnapshots(ctx, withdrawn)
+		assert.NoError(t, err)
+		check()
+
+		_, err = db.GetBalanceAt(ctx, uuid.New(), withdrawn)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletNotFound))
+	})
+
+	t.Run("idempotency key", func(t *testing.T) {
+		walletID := uuid.New()
+		key := models.IdempotencyKey{
+			Key:         uuid.NewString(),
+			Fingerprint: "deposit-100",
+			StatusCode:  200,
+			Response:    []byte(`{"success":"balance updated"}`),
+		}
+
+		assert.NoError(t, db.NewWallet(ctx, walletID, "RUB", 0))
+
+		keyCtx := WithIdempotencyKey(ctx, key)
+		assert.NoError(t, db.Deposit(keyCtx, walletID, "RUB", 100))
+
+		err := db.Deposit(keyCtx, walletID, "RUB", 100)
+		assert.True(t, errors.Is(err, custom_errors.ErrIdempotentReplay))
+
+		reused := key
+		reused.Fingerprint = "deposit-200"
+		err = db.Deposit(WithIdempotencyKey(ctx, reused), walletID, "RUB", 200)
+		assert.True(t, errors.Is(err, custom_errors.ErrIdempotencyKeyReused))
+
+		stored, err := db.GetIdempotencyKey(ctx, key.Key)
+		assert.NoError(t, err)
+		assert.Equal(t, key, stored)
+
+		_, err = db.GetIdempotencyKey(ctx, uuid.NewString())
+		assert.True(t, errors.Is(err, custom_errors.ErrIdempotencyKeyNotFound))
+
+		// A failed operation must not consume the key.
+		failedKey := key
+		failedKey.Key = uuid.NewString()
+		err = db.Withdraw(WithIdempotencyKey(ctx, failedKey), walletID, "RUB", 1000)
+		assert.True(t, errors.Is(err, custom_errors.ErrNotEnoughFunds))
+		assert.NoError(t, db.Withdraw(WithIdempotencyKey(ctx, failedKey), walletID, "RUB", 100))
+
+		balance, err := db.GetBalance(ctx, walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), balance)
+	})
+
+	t.Run("concurrent withdrawals never overdraw", func(t *testing.T) {
+		walletID := uuid.New()
+		assert.NoError(t, db.NewWallet(ctx, walletID, "RUB", 1000))
+
+		var wg sync.WaitGroup
+		var succeeded int64
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := db.Withdraw(ctx, walletID, "RUB", 30)
+				if err == nil {
+					atomic.AddInt64(&succeeded, 1)
+				} else {
+					assert.True(t, errors.Is(err, custom_errors.ErrNotEnoughFunds))
+				}
+			}()
+		}
+		wg.Wait()
+
+		balance, err := db.GetBalance(ctx, walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(33), succeeded)
+		assert.Equal(t, int64(10), balance)
+	})
+
+	t.Run("holds", func(t *testing.T) {
+		walletID := uuid.New()
+		assert.NoError(t, db.NewWallet(ctx, walletID, "RUB", 1000))
+
+		newHold := func(amount int64, ttl time.Duration) models.Hold {
+			now := time.Now().UTC().Truncate(time.Microsecond)
+			return models.Hold{
+				ID:        uuid.New(),
+				WalletID:  walletID,
+				Currency:  "RUB",
+				Amount:    amount,
+				Status:    models.HoldActive,
+				CreatedAt: now,
+				ExpiresAt: now.Add(ttl),
+			}
+		}
+
+		captured := newHold(600, time.Hour)
+		assert.NoError(t, db.Authorize(ctx, captured))
+
+		err := db.Authorize(ctx, newHold(401, time.Hour))
+		assert.True(t, errors.Is(err, custom_errors.ErrNotEnoughFunds))
+
+		wallet, err := db.GetWallet(ctx, walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1000), wallet.Balance)
+		assert.Equal(t, int64(400), wallet.Available)
+
+		err = db.Withdraw(ctx, walletID, "RUB", 401)
+		assert.True(t, errors.Is(err, custom_errors.ErrNotEnoughFunds))
+
+		err = db.Capture(ctx, captured.ID, 601)
+		assert.True(t, errors.Is(err, custom_errors.ErrCaptureExceedsHold))
+		assert.NoError(t, db.Capture(ctx, captured.ID, 500))
+
+		err = db.Capture(ctx, captured.ID, 100)
+		assert.True(t, errors.Is(err, custom_errors.ErrHoldNotActive))
+
+		hold, err := db.GetHold(ctx, captured.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldCaptured, hold.Status)
+		assert.Equal(t, int64(500), hold.CapturedAmount)
+		assert.Equal(t, "RUB", hold.Currency)
+		assert.True(t, captured.ExpiresAt.Equal(hold.ExpiresAt))
+
+		// The uncaptured 1.00 goes back to the available balance.
+		wallet, err = db.GetWallet(ctx, walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(500), wallet.Balance)
+		assert.Equal(t, int64(500), wallet.Available)
+
+		released := newHold(300, time.Hour)
+		assert.NoError(t, db.Authorize(ctx, released))
+		assert.NoError(t, db.Release(ctx, released.ID))
+
+		err = db.Release(ctx, released.ID)
+		assert.True(t, errors.Is(err, custom_errors.ErrHoldNotActive))
+
+		expired := newHold(300, -time.Second)
+		assert.NoError(t, db.Authorize(ctx, expired))
+
+		err = db.Capture(ctx, expired.ID, 0)
+		assert.True(t, errors.Is(err, custom_errors.ErrHoldExpired))
+
+		hold, err = db.GetHold(ctx, expired.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldExpired, hold.Status)
+
+		wallet, err = db.GetWallet(ctx, walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(500), wallet.Available)
+
+		_, err = db.GetHold(ctx, uuid.New())
+		assert.True(t, errors.Is(err, custom_errors.ErrHoldNotFound))
+
+		err = db.Capture(ctx, uuid.New(), 0)
+		assert.True(t, errors.Is(err, custom_errors.ErrHoldNotFound))
+	})
+
+	t.Run("wallet status", func(t *testing.T) {
+		walletID, otherID := uuid.New(), uuid.New()
+		assert.NoError(t, db.NewWallet(ctx, walletID, "RUB", 1000))
+		assert.NoError(t, db.NewWallet(ctx, otherID, "RUB", 1000))
+
+		change, err := db.SetWalletStatus(ctx, walletID, models.WalletFrozen, "suspicious activity")
+		assert.NoError(t, err)
+		assert.Equal(t, models.WalletActive, change.From)
+		assert.Equal(t, models.WalletFrozen, change.To)
+		assert.Equal(t, "suspicious activity", change.Reason)
+		assert.False(t, change.CreatedAt.IsZero())
+
+		// A frozen wallet can receive money but not spend it.
+		assert.NoError(t, db.Deposit(ctx, walletID, "RUB", 100))
+		assert.NoError(t, db.Transfer(ctx, otherID, walletID, "RUB", 100))
+
+		err = db.Withdraw(ctx, walletID, "RUB", 100)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletFrozen))
+		err = db.Transfer(ctx, walletID, otherID, "RUB", 100)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletFrozen))
+
+		_, err = db.SetWalletStatus(ctx, walletID, models.WalletClosed, "closing")
+		assert.True(t, errors.Is(err, custom_errors.ErrInvalidStatusTransition))
+
+		_, err = db.SetWalletStatus(ctx, walletID, models.WalletActive, "checked")
+		assert.NoError(t, err)
+		assert.NoError(t, db.Withdraw(ctx, walletID, "RUB", 100))
+
+		_, err = db.SetWalletStatus(ctx, walletID, models.WalletClosed, "closed by owner")
+		assert.NoError(t, err)
+
+		err = db.Deposit(ctx, walletID, "RUB", 100)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletClosed))
+		err = db.Withdraw(ctx, walletID, "RUB", 100)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletClosed))
+		err = db.Transfer(ctx, otherID, walletID, "RUB", 100)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletClosed))
+
+		_, err = db.SetWalletStatus(ctx, walletID, models.WalletActive, "reopen")
+		assert.True(t, errors.Is(err, custom_errors.ErrInvalidStatusTransition))
+
+		wallet, err := db.GetWallet(ctx, walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.WalletClosed, wallet.Status)
+		assert.Equal(t, int64(1100), wallet.Balance)
+
+		_, err = db.SetWalletStatus(ctx, uuid.New(), models.WalletFrozen, "unknown")
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletNotFound))
+	})
+
+	t.Run("balances beyond 32 bits and overflow", func(t *testing.T) {
+		fromID, toID := uuid.New(), uuid.New()
+		assert.NoError(t, db.NewWallet(ctx, fromID, "RUB", math.MaxInt64-10))
+		assert.NoError(t, db.NewWallet(ctx, toID, "RUB", 1<<40))
+
+		err := db.Deposit(ctx, fromID, "RUB", 11)
+		assert.True(t, errors.Is(err, custom_errors.ErrBalanceOverflow))
+		assert.NoError(t, db.Deposit(ctx, fromID, "RUB", 10))
+
+		err = db.Transfer(ctx, toID, fromID, "RUB", 1)
+		assert.True(t, errors.Is(err, custom_errors.ErrBalanceOverflow))
+
+		assert.NoError(t, db.Transfer(ctx, fromID, toID, "RUB", 1<<40))
+
+		balance, err := db.GetBalance(ctx, fromID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(math.MaxInt64-1<<40), balance)
+
+		balance, err = db.GetBalance(ctx, toID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1<<41), balance)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		a, b, created := uuid.New(), uuid.New(), uuid.New()
+		assert.NoError(t, db.NewWallet(ctx, a, "RUB", 1000))
+		assert.NoError(t, db.NewWallet(ctx, b, "RUB", 100))
+		assert.NoError(t, db.Authorize(ctx, models.Hold{ID: uuid.New(), WalletID: a, Amount: 500, ExpiresAt: time.Now().Add(time.Hour)}))
+
+		ops := []models.BatchOperation{
+			{WalletID: a, Operation: models.OperationWithdraw, Currency: "RUB", Amount: 300},
+			{WalletID: created, Operation: models.OperationDeposit, Currency: "RUB", Amount: 300},
+			{WalletID: a, Operation: models.OperationWithdraw, Currency: "RUB", Amount: 300},
+			{WalletID: b, Operation: models.OperationDeposit, Currency: "USD", Amount: 1},
+			{WalletID: b, Operation: models.OperationWithdraw, Currency: "RUB", Amount: 50},
+		}
+
+		// All or nothing: the held funds make the second withdrawal fail.
+		_, err := db.Batch(ctx, ops, true)
+		var batchErr *custom_errors.BatchError
+		assert.True(t, errors.As(err, &batchErr))
+		assert.Equal(t, 2, batchErr.Index)
+		assert.True(t, errors.Is(err, custom_errors.ErrNotEnoughFunds))
+
+		balance, err := db.GetBalance(ctx, a)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1000), balance, "a failed atomic batch changes nothing")
+		_, err = db.GetWallet(ctx, created)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletNotFound))
+
+		// Best effort: the failures are reported and the rest applied.
+		results, err := db.Batch(ctx, ops, false)
+		assert.NoError(t, err)
+		assert.Len(t, results, 5)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, int64(700), results[0].Balance)
+		assert.Equal(t, "RUB", results[0].Currency)
+		assert.NoError(t, results[1].Err)
+		assert.Equal(t, int64(300), results[1].Balance)
+		assert.True(t, errors.Is(results[2].Err, custom_errors.ErrNotEnoughFunds))
+		assert.True(t, errors.Is(results[3].Err, custom_errors.ErrCurrencyMismatch))
+		assert.NoError(t, results[4].Err)
+		assert.Equal(t, int64(50), results[4].Balance)
+
+		for id, want := range map[uuid.UUID]int64{a: 700, b: 50, created: 300} {
+			balance, err := db.GetBalance(ctx, id)
+			assert.NoError(t, err)
+			assert.Equal(t, want, balance)
+		}
+
+		txs, err := db.ListTransactions(ctx, a, models.TransactionFilter{Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, txs, 2, "only the applied withdrawal is recorded")
+		assert.Equal(t, int64(-300), txs[0].Amount)
+
+		_, err = db.Batch(ctx, []models.BatchOperation{{WalletID: uuid.New(), Operation: models.OperationWithdraw, Currency: "RUB", Amount: 1}}, true)
+		assert.True(t, errors.Is(err, custom_errors.ErrWalletNotFound))
+	})
+
+	t.Run("reconcile", func(t *testing.T) {
+		walletID := uuid.New()
+		assert.NoError(t, db.NewWallet(ctx, walletID, "RUB", 100))
+		assert.NoError(t, db.Withdraw(ctx, walletID, "RUB", 40))
+
+		for _, opts := range []models.ReconcileOptions{{}, {Stream: true, BatchSize: 2}} {
+			run, err := db.Reconcile(ctx, opts)
+			assert.NoError(t, err)
+			assert.Equal(t, opts.Stream, run.Streaming)
+			assert.Positive(t, run.Wallets)
+			assert.Zero(t, run.Discrepancies, "every operation keeps the balance and the ledger in step")
+			assert.False(t, run.FinishedAt.Before(run.StartedAt))
+
+			latest, err := db.GetLatestReconciliation(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, run.ID, latest.ID)
+			assert.Equal(t, run.Wallets, latest.Wallets)
+		}
+	})
+
+	t.Run("journal", func(t *testing.T) {
+		// Other subtests post entries too, so only the changes of the trial
+		// balance for a currency no one else uses are checked.
+		const currency = "CHF"
+		trialBalance := func() map[string]models.TrialBalanceLine {
+			lines, err := db.TrialBalance(ctx)
+			assert.NoError(t, err)
+			res := make(map[string]models.TrialBalanceLine)
+			for _, line := range lines {
+				if line.Currency == currency {
+					res[line.Account] = line
+				}
+			}
+			return res
+		}
+		before := trialBalance()
+
+		a, b := uuid.New(), uuid.New()
+		assert.NoError(t, db.NewWallet(ctx, a, currency, 1000))
+		assert.NoError(t, db.NewWallet(ctx, b, currency, 0))
+		assert.NoError(t, db.Deposit(ctx, a, currency, 500))
+		assert.NoError(t, db.Deposit(ctx, a, currency, 0))
+		assert.NoError(t, db.Withdraw(ctx, a, currency, 200))
+		assert.NoError(t, db.Transfer(ctx, a, b, currency, 300))
+		hold := models.Hold{ID: uuid.New(), WalletID: b, Currency: currency, Amount: 100, Status: models.HoldActive, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+		assert.NoError(t, db.Authorize(ctx, hold))
+		assert.NoError(t, db.Capture(ctx, hold.ID, 0))
+		_, err := db.Batch(ctx, []models.BatchOperation{
+			{WalletID: a, Operation: models.OperationWithdraw, Currency: currency, Amount: 50},
+			{WalletID: uuid.New(), Operation: models.OperationDeposit, Currency: currency, Amount: 70},
+		}, true)
+		assert.NoError(t, err)
+
+		after := trialBalance()
+		change := func(account string) (debit, credit int64) {
+			return after[account].Debit - before[account].Debit, after[account].Credit - before[account].Credit
+		}
+		for account, want := range map[string][2]int64{
+			models.AccountCashInClearing:  {1000 + 500 + 70, 0},
+			models.AccountCashOutClearing: {0, 200 + 100 + 50},
+			models.AccountWallet:          {200 + 300 + 100 + 50, 1000 + 500 + 300 + 70},
+		} {
+			debit, credit := change(account)
+			assert.Equal(t, want, [2]int64{debit, credit}, account)
+		}
+
+		var debits, credits int64
+		for _, line := range after {
+			debits += line.Debit
+			credits += line.Credit
+		}
+		assert.Equal(t, debits, credits, "the ledger balances")
+	})
+
+	t.Run("outbox", func(t *testing.T) {
+		relay := func(publish PublishFunc) error {
+			for {
+				n, err := db.RelayOutbox(ctx, 10, publish)
+				if err != nil || n < 10 {
+					return err
+				}
+			}
+		}
+		// Publish what earlier subtests left in the outbox.
+		assert.NoError(t, relay(func(ctx context.Context, e models.Event) error { return nil }))
+
+		a, b := uuid.New(), uuid.New()
+		assert.NoError(t, db.NewWallet(ctx, a, "RUB", 1000))
+		assert.NoError(t, db.NewWallet(ctx, b, "RUB", 0))
+		assert.NoError(t, db.Deposit(ctx, a, "RUB", 500))
+		assert.NoError(t, db.Deposit(ctx, b, "RUB", 10))
+		assert.NoError(t, db.Withdraw(ctx, a, "RUB", 200))
+		assert.ErrorIs(t, db.Withdraw(ctx, a, "RUB", 1_000_000), custom_errors.ErrNotEnoughFunds)
+		assert.NoError(t, db.Transfer(ctx, a, b, "RUB", 300))
+		hold := models.Hold{ID: uuid.New(), WalletID: a, Currency: "RUB", Amount: 100, Status: models.HoldActive, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+		assert.NoError(t, db.Authorize(ctx, hold))
+		assert.NoError(t, db.Capture(ctx, hold.ID, 0))
+		_, err := db.Batch(ctx, []models.BatchOperation{
+			{WalletID: a, Operation: models.OperationDeposit, Currency: "RUB", Amount: 70},
+			{WalletID: a, Operation: models.OperationWithdraw, Currency: "RUB", Amount: 30},
+		}, true)
+		assert.NoError(t, err)
+
+		type change struct {
+			Type            string
+			Amount, Balance int64
+		}
+		var published, publishedB []change
+		err = relay(func(ctx context.Context, e models.Event) error {
+			switch e.WalletID {
+			case a:
+				return errors.New("unavailable")
+			case b:
+				publishedB = append(publishedB, change{e.Type, e.Amount, e.Balance})
+			}
+			return nil
+		})
+		assert.Error(t, err)
+		assert.Equal(t, []change{
+			{models.EventDeposited, 10, 10},
+			{models.EventTransferredIn, 300, 310},
+		}, publishedB, "the events of other wallets are published")
+
+		err = relay(func(ctx context.Context, e models.Event) error {
+			switch e.WalletID {
+			case a:
+				assert.Equal(t, "RUB", e.Currency)
+				assert.Positive(t, e.ID)
+				published = append(published, change{e.Type, e.Amount, e.Balance})
+			case b:
+				publishedB = append(publishedB, change{e.Type, e.Amount, e.Balance})
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Len(t, publishedB, 2, "published events are not published again")
+		assert.Equal(t, []change{
+			{models.EventDeposited, 1000, 1000},
+			{models.EventDeposited, 500, 1500},
+			{models.EventWithdrawn, 200, 1300},
+			{models.EventTransferredOut, 300, 1000},
+			{models.EventWithdrawn, 100, 900},
+			{models.EventDeposited, 70, 970},
+			{models.EventWithdrawn, 30, 940},
+		}, published, "the events of a wallet are published in order")
+
+		events, err := db.ListEvents(ctx, a, 0, 100)
+		assert.NoError(t, err)
+		if assert.Len(t, events, len(published), "published events are kept") {
+			last, err := db.LastEventID(ctx, a)
+			assert.NoError(t, err)
+			assert.Equal(t, events[len(events)-1].ID, last)
+
+			after, err := db.ListEvents(ctx, a, events[1].ID, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, events[2:4], after)
+		}
+		for _, e := range events {
+			assert.Equal(t, a, e.WalletID)
+		}
+
+		last, err := db.LastEventID(ctx, uuid.New())
+		assert.NoError(t, err)
+		assert.Zero(t, last)
+	})
+
+	t.Run("transfer events", func(t *testing.T) {
+		from, to := uuid.New(), uuid.New()
+		assert.NoError(t, db.NewWallet(ctx, from, "RUB", 1000))
+		assert.NoError(t, db.NewWallet(ctx, to, "RUB", 0))
+		assert.NoError(t, db.Transfer(ctx, from, to, "RUB", 250))
+
+		events, err := db.ListEvents(ctx, from, 0, 10)
+		assert.NoError(t, err)
+		if assert.Len(t, events, 2) {
+			assert.Equal(t, models.EventTransferredOut, events[1].Type)
+			assert.Equal(t, int64(250), events[1].Amount)
+			assert.Equal(t, int64(750), events[1].Balance)
+		}
+
+		events, err = db.ListEvents(ctx, to, 0, 10)
+		assert.NoError(t, err)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, models.EventTransferredIn, events[0].Type)
+			assert.Equal(t, int64(250), events[0].Amount)
+			assert.Equal(t, int64(250), events[0].Balance)
+			assert.Equal(t, "RUB", events[0].Currency)
+		}
+
+		assert.ErrorIs(t, db.Transfer(ctx, from, to, "RUB", 1_000_000), custom_errors.ErrNotEnoughFunds)
+		events, err = db.ListEvents(ctx, to, 0, 10)
+		assert.NoError(t, err)
+		assert.Len(t, events, 1, "a failed transfer records no events")
+	})
+
+	t.Run("webhooks", func(t *testing.T) {
+		newClient := func(admin bool) models.Client {
+			client := models.Client{ID: uuid.New(), Name: "webhooks", Admin: admin, CreatedAt: time.Now().UTC().Truncate(time.Microsecond)}
+			assert.NoError(t, db.CreateClient(ctx, client, uuid.NewString()))
+			return client
+		}
+		newWebhook := func(client models.Client, eventTypes ...string) models.Webhook {
+			webhook := models.Webhook{ID: uuid.New(), ClientID: client.ID, URL: "https://example.com/hooks", EventTypes: eventTypes, Secret: "secret", CreatedAt: time.Now().UTC().Truncate(time.Microsecond)}
+			assert.NoError(t, db.CreateWebhook(ctx, webhook))
+			return webhook
+		}
+		owner, other, admin := newClient(false), newClient(false), newClient(true)
+		ownerHook := newWebhook(owner, models.EventDeposited)
+		newWebhook(other, models.EventDeposited, models.EventWithdrawn)
+		adminHook := newWebhook(admin, models.EventWithdrawn)
+
+		got, err := db.GetWebhook(ctx, ownerHook.ID)
+		assert.NoError(t, err)
+		assert.True(t, ownerHook.CreatedAt.Equal(got.CreatedAt))
+		got.CreatedAt = ownerHook.CreatedAt
+		assert.Equal(t, ownerHook, got)
+		hooks, err := db.ListWebhooks(ctx, owner.ID)
+		assert.NoError(t, err)
+		if assert.Len(t, hooks, 1) {
+			assert.Equal(t, ownerHook.ID, hooks[0].ID)
+		}
+
+		// Deliveries refer to published events of a real wallet.
+		walletID := uuid.New()
+		assert.NoError(t, db.NewWallet(WithOwner(ctx, owner.ID), walletID, "RUB", 100))
+		assert.NoError(t, db.Withdraw(ctx, walletID, "RUB", 40))
+		var events []models.Event
+		for {
+			n, err := db.RelayOutbox(ctx, 100, func(ctx context.Context, e models.Event) error {
+				if e.WalletID == walletID {
+					events = append(events, e)
+				}
+				return nil
+			})
+			assert.NoError(t, err)
+			if n < 100 {
+				break
+			}
+		}
+		if !assert.Len(t, events, 2) {
+			return
+		}
+		deposited, withdrawn := events[0], events[1]
+
+		n, err := db.EnqueueWebhookDeliveries(ctx, deposited)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n, "only the owner's webhook gets the deposit")
+		n, err = db.EnqueueWebhookDeliveries(ctx, deposited)
+		assert.NoError(t, err)
+		assert.Zero(t, n, "an event published twice is delivered once")
+		n, err = db.EnqueueWebhookDeliveries(ctx, withdrawn)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n, "admins get the events of every wallet")
+
+		claimed, err := db.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+		assert.NoError(t, err)
+		var ours []models.WebhookDelivery
+		for _, d := range claimed {
+			if d.Event.WalletID == walletID {
+				ours = append(ours, d)
+			}
+		}
+		if !assert.Len(t, ours, 2) {
+			return
+		}
+		assert.Equal(t, ownerHook.ID, ours[0].WebhookID)
+		assert.Equal(t, deposited.ID, ours[0].Event.ID)
+		assert.Equal(t, deposited.Balance, ours[0].Event.Balance)
+		assert.Equal(t, models.DeliveryPending, ours[0].Status)
+		assert.Equal(t, adminHook.ID, ours[1].WebhookID)
+
+		claimed, err = db.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+		assert.NoError(t, err)
+		for _, d := range claimed {
+			assert.NotEqual(t, walletID, d.Event.WalletID, "claimed deliveries are leased")
+		}
+
+		failed := ours[0]
+		failed.Status = models.DeliveryFailed
+		failed.Attempts = 3
+		failed.LastError = "receiver answered 503"
+		failed.LastStatusCode = 503
+		assert.NoError(t, db.UpdateWebhookDelivery(ctx, failed))
+		got2, err := db.GetWebhookDelivery(ctx, failed.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, failed.Status, got2.Status)
+		assert.Equal(t, failed.Attempts, got2.Attempts)
+		assert.Equal(t, failed.LastError, got2.LastError)
+		assert.Equal(t, failed.LastStatusCode, got2.LastStatusCode)
+
+		list, err := db.ListWebhookDeliveries(ctx, ownerHook.ID, models.DeliveryFailed, 10)
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		list, err = db.ListWebhookDeliveries(ctx, ownerHook.ID, models.DeliveryPending, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, list)
+
+		assert.NoError(t, db.DeleteWebhook(ctx, ownerHook.ID))
+		assert.ErrorIs(t, db.DeleteWebhook(ctx, ownerHook.ID), custom_errors.ErrWebhookNotFound)
+		_, err = db.GetWebhook(ctx, ownerHook.ID)
+		assert.ErrorIs(t, err, custom_errors.ErrWebhookNotFound)
+		_, err = db.GetWebhookDelivery(ctx, failed.ID)
+		assert.ErrorIs(t, err, custom_errors.ErrDeliveryNotFound, "deliveries go with their webhook")
+	})
+
+	t.Run("clients", func(t *testing.T) {
+		client := models.Client{
+			ID:        uuid.New(),
+			Name:      "shop",
+			CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		}
+		keyHash := uuid.NewString()
+		assert.NoError(t, db.CreateClient(ctx, client, keyHash))
+
+		got, err := db.GetClientByKeyHash(ctx, keyHash)
+		assert.NoError(t, err)
+		assert.Equal(t, client.ID, got.ID)
+		assert.Equal(t, "shop", got.Name)
+		assert.False(t, got.Admin)
+		assert.True(t, client.CreatedAt.Equal(got.CreatedAt))
+
+		_, err = db.GetClientByKeyHash(ctx, uuid.NewString())
+		assert.True(t, errors.Is(err, custom_errors.ErrClientNotFound))
+
+		// Wallets created on behalf of a client are owned by it.
+		walletID := uuid.New()
+		assert.NoError(t, db.NewWallet(WithOwner(ctx, client.ID), walletID, "RUB", 0))
+		wallet, err := db.GetWallet(ctx, walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, client.ID, wallet.OwnerID)
+
+		limited := models.Client{
+			ID:        uuid.New(),
+			Name:      "limited",
+			CreatedAt: client.CreatedAt,
+			RateLimit: &ratelimit.Limit{Rate: 0.5, Burst: 5},
+		}
+		limitedKeyHash := uuid.NewString()
+		assert.NoError(t, db.CreateClient(ctx, limited, limitedKeyHash))
+		got, err = db.GetClientByKeyHash(ctx, limitedKeyHash)
+		assert.NoError(t, err)
+		assert.Equal(t, limited.RateLimit, got.RateLimit)
+
+		assert.NoError(t, db.RevokeClient(ctx, client.ID))
+		_, err = db.GetClientByKeyHash(ctx, keyHash)
+		assert.True(t, errors.Is(err, custom_errors.ErrClientNotFound), "revoked keys no longer authenticate")
+
+		err = db.RevokeClient(ctx, client.ID)
+		assert.True(t, errors.Is(err, custom_errors.ErrClientNotFound))
+	})
+}
+
+func TestMemoryConformance(t *testing.T) {
+	runConformance(t, NewMemory())
+}
+
+func TestPostgresConformance(t *testing.T) {
+	requirePG(t)
+
+	runConformance(t, testPG)
+}
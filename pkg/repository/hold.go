@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// heldAmount sums the wallet's active, unexpired holds. Callers that act on
+// the result must hold the wallet row lock, which Authorize also takes.
+func heldAmount(ctx context.Context, q querier, walletID uuid.UUID) (int64, error) {
+	query := `SELECT COALESCE(SUM(amount), 0)::BIGINT FROM holds
+		WHERE wallet_id = $1 AND status = 'active' AND expires_at > now()`
+
+	var held int64
+	if err := q.QueryRow(ctx, query, walletID).Scan(&held); err != nil {
+		return 0, fmt.Errorf("get held amount: %w", err)
+	}
+	return held, nil
+}
+
+// checkHold fails unless a hold with status can still be captured or released.
+func checkHold(status string) error {
+	switch status {
+	case models.HoldActive:
+		return nil
+	case models.HoldExpired:
+		return custom_errors.ErrHoldExpired
+	default:
+		return custom_errors.ErrHoldNotActive
+	}
+}
+
+const holdColumns = `h.id, h.wallet_id, w.currency, h.amount, h.captured_amount,
+	CASE WHEN h.status = 'active' AND h.expires_at <= now() THEN 'expired' ELSE h.status END,
+	h.created_at, h.expires_at`
+
+func getHold(ctx context.Context, q querier, holdID uuid.UUID, forUpdate bool) (models.Hold, error) {
+	query := `SELECT ` + holdColumns + ` FROM holds h JOIN wallets w ON w.id = h.wallet_id WHERE h.id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE OF h`
+	}
+
+	var hold models.Hold
+	err := q.QueryRow(ctx, query, holdID).Scan(&hold.ID, &hold.WalletID, &hold.Currency, &hold.Amount,
+		&hold.CapturedAmount, &hold.Status, &hold.CreatedAt, &hold.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return hold, custom_errors.ErrHoldNotFound
+	}
+	if err != nil {
+		return hold, fmt.Errorf("get hold: %w", err)
+	}
+	return hold, nil
+}
+
+func (pg *postgresDB) Authorize(ctx context.Context, hold models.Hold) error {
+	querySelect := `SELECT balance, currency, status FROM wallets WHERE id=@walletID FOR UPDATE`
+	argsSelect := pgx.NamedArgs{
+		"walletID": hold.WalletID,
+	}
+
+	queryInsert := `INSERT INTO holds (id, wallet_id, amount, created_at, expires_at)
+		VALUES (@id, @walletID, @amount, @createdAt, @expiresAt)`
+	argsInsert := pgx.NamedArgs{
+		"id":        hold.ID,
+		"walletID":  hold.WalletID,
+		"amount":    hold.Amount,
+		"createdAt": hold.CreatedAt,
+		"expiresAt": hold.ExpiresAt,
+	}
+
+	tx, err := pg.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := claimIdempotencyKey(ctx, tx); err != nil {
+		return err
+	}
+
+	var (
+		balance        int64
+		walletCurrency string
+		status         string
+	)
+
+	if err := tx.QueryRow(ctx, querySelect, argsSelect).Scan(&balance, &walletCurrency, &status); err != nil {
+		return fmt.Errorf("select for update: %w", err)
+	}
+
+	if err := checkCanSpend(status); err != nil {
+		return err
+	}
+
+	if err := checkCurrency(hold.Currency, walletCurrency); err != nil {
+		return err
+	}
+
+	held, err := heldAmount(ctx, tx, hold.WalletID)
+	if err != nil {
+		return err
+	}
+	if balance-held < hold.Amount {
+		return custom_errors.ErrNotEnoughFunds
+	}
+
+	if _, err := tx.Exec(ctx, queryInsert, argsInsert); err != nil {
+		return fmt.Errorf("create hold: %w", mapPGError(err))
+	}
+	return tx.Commit(ctx)
+}
+
+func (pg *postgresDB) Capture(ctx context.Context, holdID uuid.UUID, amount int64) error {
+	tx, err := pg.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := claimIdempotencyKey(ctx, tx); err != nil {
+		return err
+	}
+
+	// The wallet is locked before the hold, in the same order as Authorize
+	// and Withdraw take their locks.
+	var walletID uuid.UUID
+	err = tx.QueryRow(ctx, `SELECT wallet_id FROM holds WHERE id = $1`, holdID).Scan(&walletID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return custom_errors.ErrHoldNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("get hold: %w", err)
+	}
+
+	var (
+		balance int64
+		status  string
+	)
+	if err := tx.QueryRow(ctx, `SELECT balance, status FROM wallets WHERE id = $1 FOR UPDATE`, walletID).Scan(&balance, &status); err != nil {
+		return fmt.Errorf("select for update: %w", err)
+	}
+
+	if err := checkCanSpend(status); err != nil {
+		return err
+	}
+
+	hold, err := getHold(ctx, tx, holdID, true)
+	if err != nil {
+		return err
+	}
+	if err := checkHold(hold.Status); err != nil {
+		return err
+	}
+
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount > hold.Amount {
+		return custom_errors.ErrCaptureExceedsHold
+	}
+	if balance < amount {
+		return custom_errors.ErrNotEnoughFunds
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE wallets SET balance = balance - $1 WHERE id = $2`, amount, walletID); err != nil {
+		return fmt.Errorf("update balance: %w", mapPGError(err))
+	}
+
+	queryUpdate := `UPDATE holds SET status = 'captured', captured_amount = @amount WHERE id = @holdID`
+	if _, err := tx.Exec(ctx, queryUpdate, pgx.NamedArgs{"holdID": holdID, "amount": amount}); err != nil {
+		return fmt.Errorf("capture hold: %w", err)
+	}
+
+	t := models.Transaction{
+		WalletID:  walletID,
+		Operation: models.OperationWithdraw,
+		Amount:    -amount,
+	}
+	if err := recordTransaction(ctx, tx, t); err != nil {
+		return err
+	}
+	if err := postEntry(ctx, tx, t.Operation, cashPostings(t, hold.Currency)); err != nil {
+		return err
+	}
+	if err := recordEvent(ctx, tx, balanceEvent(t, balance+t.Amount, hold.Currency)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (pg *postgresDB) Release(ctx context.Context, holdID uuid.UUID) error {
+	tx, err := pg.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := claimIdempotencyKey(ctx, tx); err != nil {
+		return err
+	}
+
+	hold, err := getHold(ctx, tx, holdID, true)
+	if err != nil {
+		return err
+	}
+	if err := checkHold(hold.Status); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE holds SET status = 'released' WHERE id = $1`, holdID); err != nil {
+		return fmt.Errorf("release hold: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (pg *postgresDB) GetHold(ctx context.Context, holdID uuid.UUID) (models.Hold, error) {
+	return getHold(ctx, pg.db, holdID, false)
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type idempotencyKeyCtx struct{}
+
+// WithIdempotencyKey makes the next balance-changing call made with ctx
+// claim key in the same transaction as the balance change. If the key was
+// already claimed the call fails with ErrIdempotentReplay, or with
+// ErrIdempotencyKeyReused when the fingerprints differ.
+func WithIdempotencyKey(ctx context.Context, key models.IdempotencyKey) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtx{}, key)
+}
+
+func idempotencyKeyFromContext(ctx context.Context) (models.IdempotencyKey, bool) {
+	key, ok := ctx.Value(idempotencyKeyCtx{}).(models.IdempotencyKey)
+	return key, ok
+}
+
+func claimIdempotencyKey(ctx context.Context, tx pgx.Tx) error {
+	key, ok := idempotencyKeyFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	// A concurrent request with the same key blocks on the insert until the
+	// first one commits or rolls back, so only one of them can proceed.
+	queryInsert := `INSERT INTO idempotency_keys (key, fingerprint, status_code, response)
+		VALUES (@key, @fingerprint, @statusCode, @response)
+		ON CONFLICT (key) DO NOTHING`
+	argsInsert := pgx.NamedArgs{
+		"key":         key.Key,
+		"fingerprint": key.Fingerprint,
+		"statusCode":  key.StatusCode,
+		"response":    key.Response,
+	}
+
+	tag, err := tx.Exec(ctx, queryInsert, argsInsert)
+	if err != nil {
+		return fmt.Errorf("claim idempotency key: %w", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+
+	var fingerprint string
+	if err := tx.QueryRow(ctx, `SELECT fingerprint FROM idempotency_keys WHERE key = $1`, key.Key).Scan(&fingerprint); err != nil {
+		return fmt.Errorf("get idempotency key: %w", err)
+	}
+
+	if fingerprint != key.Fingerprint {
+		return custom_errors.ErrIdempotencyKeyReused
+	}
+	return custom_errors.ErrIdempotentReplay
+}
+
+func (pg *postgresDB) GetIdempotencyKey(ctx context.Context, key string) (models.IdempotencyKey, error) {
+	query := `SELECT key, fingerprint, status_code, response FROM idempotency_keys WHERE key = $1`
+
+	var res models.IdempotencyKey
+	err := pg.db.QueryRow(ctx, query, key).Scan(&res.Key, &res.Fingerprint, &res.StatusCode, &res.Response)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return res, custom_errors.ErrIdempotencyKeyNotFound
+	}
+	if err != nil {
+		return res, fmt.Errorf("get idempotency key: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// cashPostings are the postings of a deposit or withdrawal recorded as t:
+// money enters a wallet through the cash-in clearing account of its
+// currency and leaves it through the cash-out clearing account.
+func cashPostings(t models.Transaction, currency string) []models.Posting {
+	if t.Amount > 0 {
+		return []models.Posting{
+			{AccountID: models.SystemAccount(models.AccountCashInClearing, currency), Amount: t.Amount},
+			{AccountID: models.WalletAccount(t.WalletID), Amount: -t.Amount},
+		}
+	}
+	return []models.Posting{
+		{AccountID: models.WalletAccount(t.WalletID), Amount: -t.Amount},
+		{AccountID: models.SystemAccount(models.AccountCashOutClearing, currency), Amount: t.Amount},
+	}
+}
+
+// transferPostings move amount from the wallet fromID to toID.
+func transferPostings(fromID, toID uuid.UUID, amount int64) []models.Posting {
+	return []models.Posting{
+		{AccountID: models.WalletAccount(fromID), Amount: amount},
+		{AccountID: models.WalletAccount(toID), Amount: -amount},
+	}
+}
+
+// nonZeroPostings drops the postings that move nothing, such as those of a
+// zero deposit.
+func nonZeroPostings(postings []models.Posting) []models.Posting {
+	return slices.DeleteFunc(slices.Clone(postings), func(p models.Posting) bool { return p.Amount == 0 })
+}
+
+// postEntry records a journal entry for operation. The database rejects the
+// transaction at commit if the postings of the entry don't sum to zero.
+func postEntry(ctx context.Context, tx pgx.Tx, operation string, postings []models.Posting) error {
+	postings = nonZeroPostings(postings)
+	if len(postings) == 0 {
+		return nil
+	}
+
+	accounts := make([]string, len(postings))
+	amounts := make([]int64, len(postings))
+	for i, p := range postings {
+		accounts[i], amounts[i] = p.AccountID, p.Amount
+	}
+
+	query := `WITH entry AS (
+			INSERT INTO journal_entries (operation) VALUES (@operation) RETURNING id
+		)
+		INSERT INTO journal_postings (entry_id, account_id, amount)
+		SELECT entry.id, p.account_id, p.amount
+		FROM entry, unnest(@accounts::TEXT[], @amounts::BIGINT[]) AS p(account_id, amount)`
+	args := pgx.NamedArgs{"operation": operation, "accounts": accounts, "amounts": amounts}
+
+	if _, err := tx.Exec(ctx, query, args); err != nil {
+		return fmt.Errorf("post journal entry: %w", err)
+	}
+	return nil
+}
+
+// TrialBalance totals the debits and credits of the ledger by kind of
+// account and currency.
+func (pg *postgresDB) TrialBalance(ctx context.Context) ([]models.TrialBalanceLine, error) {
+	query := `SELECT a.kind, a.currency,
+			COALESCE(SUM(p.amount) FILTER (WHERE p.amount > 0), 0)::BIGINT,
+			COALESCE(-SUM(p.amount) FILTER (WHERE p.amount < 0), 0)::BIGINT
+		FROM journal_postings p
+		JOIN ledger_accounts a ON a.id = p.account_id
+		GROUP BY a.currency, a.kind
+		ORDER BY a.currency, a.kind`
+
+	rows, err := pg.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("trial balance: %w", err)
+	}
+	lines, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.TrialBalanceLine])
+	if err != nil {
+		return nil, fmt.Errorf("trial balance: %w", err)
+	}
+	return lines, nil
+}
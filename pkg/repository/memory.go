@@ -0,0 +1,903 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// memoryDB is an in-memory Database. It follows the same rules as
+// postgresDB and is meant for tests and local runs without Postgres.
+type memoryDB struct {
+	mu              sync.Mutex
+	wallets         map[uuid.UUID]*models.Wallet
+	transactions    []models.Transaction
+	idempotencyKeys map[string]models.IdempotencyKey
+	holds           map[uuid.UUID]*models.Hold
+	statusChanges   []models.WalletStatusChange
+	clients         map[uuid.UUID]*memoryClient
+	reconciliations []models.Reconciliation
+	discrepancies   map[uuid.UUID][]models.Discrepancy
+	journal         []models.JournalEntry
+	// events holds every event and outbox the unpublished ones, oldest
+	// first.
+	events []models.Event
+	outbox []models.Event
+	// relayMu lets one RelayOutbox run at a time, like the advisory lock
+	// of postgresDB.
+	relayMu sync.Mutex
+
+	webhooks     map[uuid.UUID]models.Webhook
+	deliveries   []models.WebhookDelivery
+	lastDelivery int64
+}
+
+type memoryClient struct {
+	models.Client
+	keyHash string
+	revoked bool
+}
+
+func NewMemory() *memoryDB {
+	return &memoryDB{
+		wallets:         make(map[uuid.UUID]*models.Wallet),
+		idempotencyKeys: make(map[string]models.IdempotencyKey),
+		holds:           make(map[uuid.UUID]*models.Hold),
+		clients:         make(map[uuid.UUID]*memoryClient),
+		discrepancies:   make(map[uuid.UUID][]models.Discrepancy),
+		webhooks:        make(map[uuid.UUID]models.Webhook),
+	}
+}
+
+func (m *memoryDB) Close() {}
+
+func (m *memoryDB) Ping(ctx context.Context) error {
+	return nil
+}
+
+// SchemaVersion is always 0: there is no schema to migrate.
+func (m *memoryDB) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	return 0, false, nil
+}
+
+func (m *memoryDB) NewWallet(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkIdempotencyKey(ctx); err != nil {
+		return err
+	}
+
+	if _, ok := m.wallets[walletID]; ok {
+		return fmt.Errorf("create wallet: %w", custom_errors.ErrWalletExists)
+	}
+	if amount < 0 {
+		return fmt.Errorf("create wallet: %w", custom_errors.ErrNegativeBalance)
+	}
+
+	m.wallets[walletID] = &models.Wallet{
+		ID:       walletID,
+		OwnerID:  ownerFromContext(ctx),
+		Balance:  amount,
+		Currency: currency,
+		Status:   models.WalletActive,
+	}
+	if amount > 0 {
+		t := models.Transaction{
+			WalletID:  walletID,
+			Operation: models.OperationDeposit,
+			Amount:    amount,
+		}
+		m.recordTransaction(t)
+		m.postEntry(t.Operation, cashPostings(t, currency))
+		m.recordEvent(balanceEvent(t, amount, currency))
+	}
+	m.saveIdempotencyKey(ctx)
+	return nil
+}
+
+func (m *memoryDB) Deposit(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkIdempotencyKey(ctx); err != nil {
+		return err
+	}
+
+	wallet, ok := m.wallets[walletID]
+	if !ok {
+		return fmt.Errorf("select for update: %w", custom_errors.ErrWalletNotFound)
+	}
+	if err := checkCanReceive(wallet.Status); err != nil {
+		return err
+	}
+	if err := checkCurrency(currency, wallet.Currency); err != nil {
+		return err
+	}
+	if err := checkOverflow(wallet.Balance, amount); err != nil {
+		return err
+	}
+	if wallet.Balance+amount < 0 {
+		return fmt.Errorf("update balance: %w", custom_errors.ErrNegativeBalance)
+	}
+
+	wallet.Balance += amount
+	t := models.Transaction{
+		WalletID:  walletID,
+		Operation: models.OperationDeposit,
+		Amount:    amount,
+	}
+	m.recordTransaction(t)
+	m.postEntry(t.Operation, cashPostings(t, wallet.Currency))
+	m.recordEvent(balanceEvent(t, wallet.Balance, wallet.Currency))
+	m.saveIdempotencyKey(ctx)
+	return nil
+}
+
+func (m *memoryDB) Withdraw(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkIdempotencyKey(ctx); err != nil {
+		return err
+	}
+
+	wallet, ok := m.wallets[walletID]
+	if !ok {
+		return fmt.Errorf("select for update: %w", custom_errors.ErrWalletNotFound)
+	}
+	if err := checkCanSpend(wallet.Status); err != nil {
+		return err
+	}
+	if err := checkCurrency(currency, wallet.Currency); err != nil {
+		return err
+	}
+	if wallet.Balance-m.heldAmount(walletID) < amount {
+		return custom_errors.ErrNotEnoughFunds
+	}
+
+	wallet.Balance -= amount
+	t := models.Transaction{
+		WalletID:  walletID,
+		Operation: models.OperationWithdraw,
+		Amount:    -amount,
+	}
+	m.recordTransaction(t)
+	m.postEntry(t.Operation, cashPostings(t, wallet.Currency))
+	m.recordEvent(balanceEvent(t, wallet.Balance, wallet.Currency))
+	m.saveIdempotencyKey(ctx)
+	return nil
+}
+
+func (m *memoryDB) Transfer(ctx context.Context, fromID, toID uuid.UUID, currency string, amount int64) error {
+	if fromID == toID {
+		return custom_errors.ErrSameWallet
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkIdempotencyKey(ctx); err != nil {
+		return err
+	}
+
+	from, fromOK := m.wallets[fromID]
+	to, toOK := m.wallets[toID]
+	if !fromOK || !toOK {
+		return fmt.Errorf("select for update: %w", custom_errors.ErrWalletNotFound)
+	}
+	if err := checkCanSpend(from.Status); err != nil {
+		return err
+	}
+	if err := checkCanReceive(to.Status); err != nil {
+		return err
+	}
+	if err := checkCurrency(currency, from.Currency); err != nil {
+		return err
+	}
+	if err := checkCurrency(from.Currency, to.Currency); err != nil {
+		return err
+	}
+	if from.Balance-m.heldAmount(fromID) < amount {
+		return custom_errors.ErrNotEnoughFunds
+	}
+	if err := checkOverflow(to.Balance, amount); err != nil {
+		return err
+	}
+
+	from.Balance -= amount
+	to.Balance += amount
+	out := models.Transaction{
+		WalletID:       fromID,
+		Operation:      models.OperationTransferOut,
+		Amount:         -amount,
+		CounterpartyID: &toID,
+	}
+	in := models.Transaction{
+		WalletID:       toID,
+		Operation:      models.OperationTransferIn,
+		Amount:         amount,
+		CounterpartyID: &fromID,
+	}
+	m.recordTransaction(out)
+	m.recordTransaction(in)
+	m.postEntry(models.OperationTransfer, transferPostings(fromID, toID, amount))
+	m.recordEvent(balanceEvent(out, from.Balance, from.Currency))
+	m.recordEvent(balanceEvent(in, to.Balance, to.Currency))
+	m.saveIdempotencyKey(ctx)
+	return nil
+}
+
+func (m *memoryDB) GetBalance(ctx context.Context, walletID uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wallet, ok := m.wallets[walletID]
+	if !ok {
+		return 0, fmt.Errorf("get balance: %w", custom_errors.ErrWalletNotFound)
+	}
+	return wallet.Balance, nil
+}
+
+func (m *memoryDB) GetWallet(ctx context.Context, walletID uuid.UUID) (models.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wallet, ok := m.wallets[walletID]
+	if !ok {
+		return models.Wallet{}, fmt.Errorf("get wallet: %w", custom_errors.ErrWalletNotFound)
+	}
+
+	res := *wallet
+	res.Available = res.Balance - m.heldAmount(walletID)
+	return res, nil
+}
+
+func (m *memoryDB) SetWalletStatus(ctx context.Context, walletID uuid.UUID, status, reason string) (models.WalletStatusChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	change := models.WalletStatusChange{
+		WalletID: walletID,
+		To:       status,
+		Reason:   reason,
+	}
+
+	wallet, ok := m.wallets[walletID]
+	if !ok {
+		return change, fmt.Errorf("select for update: %w", custom_errors.ErrWalletNotFound)
+	}
+	change.From = wallet.Status
+	if err := checkStatusTransition(change.From, status); err != nil {
+		return change, err
+	}
+
+	wallet.Status = status
+	change.CreatedAt = time.Now()
+	m.statusChanges = append(m.statusChanges, change)
+	return change, nil
+}
+
+func (m *memoryDB) ListTransactions(ctx context.Context, walletID uuid.UUID, filter models.TransactionFilter) ([]models.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.wallets[walletID]; !ok {
+		return nil, fmt.Errorf("get wallet: %w", custom_errors.ErrWalletNotFound)
+	}
+
+	var transactions []models.Transaction
+	for i := len(m.transactions) - 1; i >= 0 && len(transactions) < filter.Limit; i-- {
+		t := m.transactions[i]
+		switch {
+		case t.WalletID != walletID:
+		case filter.Operation != "" && t.Operation != filter.Operation:
+		case !filter.From.IsZero() && t.CreatedAt.Before(filter.From):
+		case !filter.To.IsZero() && t.CreatedAt.After(filter.To):
+		case filter.Cursor > 0 && t.ID >= filter.Cursor:
+		default:
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions, nil
+}
+
+// GetBalanceAt sums the transactions of walletID up to at. The memory
+// backend keeps no snapshots, its history is short enough to scan.
+func (m *memoryDB) GetBalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.wallets[walletID]; !ok {
+		return 0, fmt.Errorf("get wallet: %w", custom_errors.ErrWalletNotFound)
+	}
+
+	var balance int64
+	for _, t := range m.transactions {
+		if t.WalletID == walletID && !t.CreatedAt.After(at) {
+			balance += t.Amount
+		}
+	}
+	return balance, nil
+}
+
+func (m *memoryDB) TakeBalanceSnapshots(ctx context.Context, at time.Time) (int64, error) {
+	return 0, nil
+}
+
+// Reconcile checks all wallets at once under the lock; there are never
+// enough of them in memory for streaming to matter.
+func (m *memoryDB) Reconcile(ctx context.Context, opts models.ReconcileOptions) (models.Reconciliation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	run := models.Reconciliation{ID: uuid.New(), Streaming: opts.Stream, StartedAt: time.Now()}
+
+	totals := make(map[uuid.UUID]int64, len(m.wallets))
+	for _, t := range m.transactions {
+		totals[t.WalletID] += t.Amount
+	}
+	for _, wallet := range m.wallets {
+		run.Wallets++
+		if wallet.Balance != totals[wallet.ID] {
+			run.Discrepancies++
+			m.discrepancies[run.ID] = append(m.discrepancies[run.ID], models.Discrepancy{
+				WalletID:    wallet.ID,
+				Balance:     wallet.Balance,
+				LedgerTotal: totals[wallet.ID],
+			})
+		}
+	}
+
+	run.FinishedAt = time.Now()
+	m.reconciliations = append(m.reconciliations, run)
+	return run, nil
+}
+
+func (m *memoryDB) GetLatestReconciliation(ctx context.Context) (models.Reconciliation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.reconciliations) == 0 {
+		return models.Reconciliation{}, custom_errors.ErrReconciliationNotFound
+	}
+	return m.reconciliations[len(m.reconciliations)-1], nil
+}
+
+// postEntry must be called with m.mu held. Postgres checks that entries
+// balance; here they are built by the same functions.
+func (m *memoryDB) postEntry(operation string, postings []models.Posting) {
+	postings = nonZeroPostings(postings)
+	if len(postings) == 0 {
+		return
+	}
+	m.journal = append(m.journal, models.JournalEntry{
+		ID:        int64(len(m.journal) + 1),
+		Operation: operation,
+		Postings:  postings,
+		CreatedAt: time.Now(),
+	})
+}
+
+func (m *memoryDB) recordEvent(e models.Event) {
+	e.ID = int64(len(m.events) + 1)
+	e.CreatedAt = time.Now()
+	m.events = append(m.events, e)
+	m.outbox = append(m.outbox, e)
+}
+
+func (m *memoryDB) ListEvents(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var events []models.Event
+	for _, e := range m.events[min(max(afterID, 0), int64(len(m.events))):] {
+		if len(events) == limit {
+			break
+		}
+		if e.WalletID == walletID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (m *memoryDB) LastEventID(ctx context.Context, walletID uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.events) - 1; i >= 0; i-- {
+		if m.events[i].WalletID == walletID {
+			return m.events[i].ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// RelayOutbox publishes without holding m.mu, so that publishers may call
+// back into the database.
+func (m *memoryDB) RelayOutbox(ctx context.Context, limit int, publish PublishFunc) (int, error) {
+	if !m.relayMu.TryLock() {
+		return 0, nil
+	}
+	defer m.relayMu.Unlock()
+
+	m.mu.Lock()
+	events := slices.Clone(m.outbox[:min(limit, len(m.outbox))])
+	m.mu.Unlock()
+
+	published, err := publishEvents(ctx, events, publish)
+
+	m.mu.Lock()
+	m.outbox = slices.DeleteFunc(m.outbox, func(e models.Event) bool {
+		return slices.Contains(published, e.ID)
+	})
+	m.mu.Unlock()
+	return len(published), err
+}
+
+func (m *memoryDB) TrialBalance(ctx context.Context) ([]models.TrialBalanceLine, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type lineKey struct{ account, currency string }
+	totals := make(map[lineKey]*models.TrialBalanceLine)
+	for _, entry := range m.journal {
+		for _, p := range entry.Postings {
+			// System accounts are kind:currency, wallet accounts the ID of
+			// the wallet.
+			key := lineKey{account: models.AccountWallet}
+			if kind, currency, ok := strings.Cut(p.AccountID, ":"); ok {
+				key = lineKey{account: kind, currency: currency}
+			} else {
+				key.currency = m.wallets[uuid.MustParse(p.AccountID)].Currency
+			}
+
+			line, ok := totals[key]
+			if !ok {
+				line = &models.TrialBalanceLine{Account: key.account, Currency: key.currency}
+				totals[key] = line
+			}
+			if p.Amount > 0 {
+				line.Debit += p.Amount
+			} else {
+				line.Credit -= p.Amount
+			}
+		}
+	}
+
+	lines := make([]models.TrialBalanceLine, 0, len(totals))
+	for _, line := range totals {
+		lines = append(lines, *line)
+	}
+	slices.SortFunc(lines, func(a, b models.TrialBalanceLine) int {
+		return cmp.Or(strings.Compare(a.Currency, b.Currency), strings.Compare(a.Account, b.Account))
+	})
+	return lines, nil
+}
+
+func (m *memoryDB) GetIdempotencyKey(ctx context.Context, key string) (models.IdempotencyKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.idempotencyKeys[key]
+	if !ok {
+		return stored, custom_errors.ErrIdempotencyKeyNotFound
+	}
+	return stored, nil
+}
+
+func (m *memoryDB) Authorize(ctx context.Context, hold models.Hold) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkIdempotencyKey(ctx); err != nil {
+		return err
+	}
+
+	wallet, ok := m.wallets[hold.WalletID]
+	if !ok {
+		return fmt.Errorf("select for update: %w", custom_errors.ErrWalletNotFound)
+	}
+	if err := checkCanSpend(wallet.Status); err != nil {
+		return err
+	}
+	if err := checkCurrency(hold.Currency, wallet.Currency); err != nil {
+		return err
+	}
+	if _, ok := m.holds[hold.ID]; ok {
+		return fmt.Errorf("create hold: %w", custom_errors.ErrWalletExists)
+	}
+	if wallet.Balance-m.heldAmount(hold.WalletID) < hold.Amount {
+		return custom_errors.ErrNotEnoughFunds
+	}
+
+	hold.Currency = wallet.Currency
+	hold.CapturedAmount = 0
+	hold.Status = models.HoldActive
+	m.holds[hold.ID] = &hold
+	m.saveIdempotencyKey(ctx)
+	return nil
+}
+
+func (m *memoryDB) Capture(ctx context.Context, holdID uuid.UUID, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkIdempotencyKey(ctx); err != nil {
+		return err
+	}
+
+	hold, ok := m.holds[holdID]
+	if !ok {
+		return custom_errors.ErrHoldNotFound
+	}
+	wallet := m.wallets[hold.WalletID]
+	if err := checkCanSpend(wallet.Status); err != nil {
+		return err
+	}
+	if err := checkHold(holdStatus(*hold)); err != nil {
+		return err
+	}
+
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount > hold.Amount {
+		return custom_errors.ErrCaptureExceedsHold
+	}
+	if wallet.Balance < amount {
+		return custom_errors.ErrNotEnoughFunds
+	}
+
+	wallet.Balance -= amount
+	hold.Status = models.HoldCaptured
+	hold.CapturedAmount = amount
+	t := models.Transaction{
+		WalletID:  hold.WalletID,
+		Operation: models.OperationWithdraw,
+		Amount:    -amount,
+	}
+	m.recordTransaction(t)
+	m.postEntry(t.Operation, cashPostings(t, wallet.Currency))
+	m.recordEvent(balanceEvent(t, wallet.Balance, wallet.Currency))
+	m.saveIdempotencyKey(ctx)
+	return nil
+}
+
+func (m *memoryDB) Release(ctx context.Context, holdID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkIdempotencyKey(ctx); err != nil {
+		return err
+	}
+
+	hold, ok := m.holds[holdID]
+	if !ok {
+		return custom_errors.ErrHoldNotFound
+	}
+	if err := checkHold(holdStatus(*hold)); err != nil {
+		return err
+	}
+
+	hold.Status = models.HoldReleased
+	m.saveIdempotencyKey(ctx)
+	return nil
+}
+
+func (m *memoryDB) GetHold(ctx context.Context, holdID uuid.UUID) (models.Hold, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hold, ok := m.holds[holdID]
+	if !ok {
+		return models.Hold{}, custom_errors.ErrHoldNotFound
+	}
+
+	res := *hold
+	res.Status = holdStatus(res)
+	return res, nil
+}
+
+// holdStatus reports an active hold past its expiry as expired.
+func holdStatus(hold models.Hold) string {
+	if hold.Status == models.HoldActive && !hold.ExpiresAt.After(time.Now()) {
+		return models.HoldExpired
+	}
+	return hold.Status
+}
+
+func (m *memoryDB) CreateClient(ctx context.Context, client models.Client, keyHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.clients {
+		if c.ID == client.ID || c.keyHash == keyHash {
+			return fmt.Errorf("create client: duplicate id or key")
+		}
+	}
+	m.clients[client.ID] = &memoryClient{Client: client, keyHash: keyHash}
+	return nil
+}
+
+func (m *memoryDB) GetClientByKeyHash(ctx context.Context, keyHash string) (models.Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.clients {
+		if c.keyHash == keyHash && !c.revoked {
+			return c.Client, nil
+		}
+	}
+	return models.Client{}, custom_errors.ErrClientNotFound
+}
+
+func (m *memoryDB) RevokeClient(ctx context.Context, clientID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.clients[clientID]
+	if !ok || c.revoked {
+		return custom_errors.ErrClientNotFound
+	}
+	c.revoked = true
+	return nil
+}
+
+// heldAmount must be called with m.mu held.
+func (m *memoryDB) heldAmount(walletID uuid.UUID) int64 {
+	var held int64
+	for _, hold := range m.holds {
+		if hold.WalletID == walletID && holdStatus(*hold) == models.HoldActive {
+			held += hold.Amount
+		}
+	}
+	return held
+}
+
+// recordTransaction must be called with m.mu held.
+func (m *memoryDB) recordTransaction(t models.Transaction) {
+	t.ID = int64(len(m.transactions) + 1)
+	t.CreatedAt = time.Now()
+	m.transactions = append(m.transactions, t)
+}
+
+// checkIdempotencyKey must be called with m.mu held. The key is only stored
+// by saveIdempotencyKey once the operation has succeeded, which mirrors the
+// rollback of the Postgres transaction on failure.
+func (m *memoryDB) checkIdempotencyKey(ctx context.Context) error {
+	key, ok := idempotencyKeyFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	stored, ok := m.idempotencyKeys[key.Key]
+	if !ok {
+		return nil
+	}
+	if stored.Fingerprint != key.Fingerprint {
+		return custom_errors.ErrIdempotencyKeyReused
+	}
+	return custom_errors.ErrIdempotentReplay
+}
+
+// saveIdempotencyKey must be called with m.mu held.
+func (m *memoryDB) saveIdempotencyKey(ctx context.Context) {
+	if key, ok := idempotencyKeyFromContext(ctx); ok {
+		m.idempotencyKeys[key.Key] = key
+	}
+}
+
+func (m *memoryDB) Batch(ctx context.Context, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkIdempotencyKey(ctx); err != nil {
+		return nil, err
+	}
+
+	// The batch works on copies, so that an atomic batch that fails leaves
+	// the wallets untouched.
+	wallets := make(map[uuid.UUID]*batchWallet)
+	for _, id := range batchWalletIDs(ops) {
+		if wallet, ok := m.wallets[id]; ok {
+			wallets[id] = &batchWallet{Wallet: *wallet, held: m.heldAmount(id)}
+		}
+	}
+
+	results, transactions, err := applyBatch(ctx, ops, atomic, wallets)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok, err := batchResponse(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, wallet := range wallets {
+		if wallet.created {
+			created := wallet.Wallet
+			m.wallets[id] = &created
+		} else {
+			m.wallets[id].Balance = wallet.Balance
+		}
+	}
+	for _, t := range transactions {
+		m.recordTransaction(t)
+		m.postEntry(t.Operation, cashPostings(t, wallets[t.WalletID].Currency))
+	}
+	for _, e := range batchEvents(transactions, wallets) {
+		m.recordEvent(e)
+	}
+	if ok {
+		m.idempotencyKeys[key.Key] = key
+	} else {
+		m.saveIdempotencyKey(ctx)
+	}
+	return results, nil
+}
+
+func (m *memoryDB) CreateWebhook(ctx context.Context, webhook models.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook.EventTypes = slices.Clone(webhook.EventTypes)
+	m.webhooks[webhook.ID] = webhook
+	return nil
+}
+
+func (m *memoryDB) GetWebhook(ctx context.Context, webhookID uuid.UUID) (models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook, ok := m.webhooks[webhookID]
+	if !ok {
+		return webhook, custom_errors.ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+func (m *memoryDB) ListWebhooks(ctx context.Context, clientID uuid.UUID) ([]models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var webhooks []models.Webhook
+	for _, webhook := range m.webhooks {
+		if webhook.ClientID == clientID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	slices.SortFunc(webhooks, func(a, b models.Webhook) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return slices.Compare(a.ID[:], b.ID[:])
+	})
+	return webhooks, nil
+}
+
+func (m *memoryDB) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.webhooks[webhookID]; !ok {
+		return custom_errors.ErrWebhookNotFound
+	}
+	delete(m.webhooks, webhookID)
+	m.deliveries = slices.DeleteFunc(m.deliveries, func(d models.WebhookDelivery) bool {
+		return d.WebhookID == webhookID
+	})
+	return nil
+}
+
+func (m *memoryDB) EnqueueWebhookDeliveries(ctx context.Context, event models.Event) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var owner uuid.UUID
+	if wallet, ok := m.wallets[event.WalletID]; ok {
+		owner = wallet.OwnerID
+	}
+
+	var n int64
+	for _, webhook := range m.webhooks {
+		client, ok := m.clients[webhook.ClientID]
+		if !ok || client.revoked || !(client.Admin || client.ID == owner) || !slices.Contains(webhook.EventTypes, event.Type) {
+			continue
+		}
+		if slices.ContainsFunc(m.deliveries, func(d models.WebhookDelivery) bool {
+			return d.WebhookID == webhook.ID && d.Event.ID == event.ID
+		}) {
+			continue
+		}
+
+		m.lastDelivery++
+		now := time.Now()
+		m.deliveries = append(m.deliveries, models.WebhookDelivery{
+			ID:            m.lastDelivery,
+			WebhookID:     webhook.ID,
+			Event:         event,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		n++
+	}
+	return n, nil
+}
+
+func (m *memoryDB) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var due []*models.WebhookDelivery
+	for i := range m.deliveries {
+		d := &m.deliveries[i]
+		if d.Status == models.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b *models.WebhookDelivery) int { return a.NextAttemptAt.Compare(b.NextAttemptAt) })
+	due = due[:min(limit, len(due))]
+
+	claimed := make([]models.WebhookDelivery, len(due))
+	for i, d := range due {
+		d.NextAttemptAt = now.Add(lease)
+		claimed[i] = *d
+	}
+	slices.SortFunc(claimed, func(a, b models.WebhookDelivery) int { return cmp.Compare(a.ID, b.ID) })
+	return claimed, nil
+}
+
+func (m *memoryDB) UpdateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.deliveries, func(stored models.WebhookDelivery) bool { return stored.ID == d.ID })
+	if i < 0 {
+		return custom_errors.ErrDeliveryNotFound
+	}
+	stored := &m.deliveries[i]
+	stored.Status = d.Status
+	stored.Attempts = d.Attempts
+	stored.NextAttemptAt = d.NextAttemptAt
+	stored.LastError = d.LastError
+	stored.LastStatusCode = d.LastStatusCode
+	return nil
+}
+
+func (m *memoryDB) GetWebhookDelivery(ctx context.Context, deliveryID int64) (models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.deliveries, func(d models.WebhookDelivery) bool { return d.ID == deliveryID })
+	if i < 0 {
+		return models.WebhookDelivery{}, custom_errors.ErrDeliveryNotFound
+	}
+	return m.deliveries[i], nil
+}
+
+func (m *memoryDB) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit int) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deliveries []models.WebhookDelivery
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		d := m.deliveries[i]
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// PublishFunc hands an event to downstream systems. An event is published
+// again, after the events of other wallets, until PublishFunc succeeds.
+type PublishFunc func(ctx context.Context, event models.Event) error
+
+// balanceEvent is the event of t, which left its wallet with balance.
+func balanceEvent(t models.Transaction, balance int64, currency string) models.Event {
+	e := models.Event{
+		Type:     models.EventDeposited,
+		WalletID: t.WalletID,
+		Amount:   t.Amount,
+		Balance:  balance,
+		Currency: currency,
+	}
+	switch t.Operation {
+	case models.OperationWithdraw:
+		e.Type = models.EventWithdrawn
+		e.Amount = -t.Amount
+	case models.OperationTransferIn:
+		e.Type = models.EventTransferredIn
+	case models.OperationTransferOut:
+		e.Type = models.EventTransferredOut
+		e.Amount = -t.Amount
+	}
+	return e
+}
+
+// batchEvents returns the events of the transactions of a batch, in order.
+// wallets hold the balances the batch ended with, so the balance after each
+// transaction is found by undoing the transactions that followed it.
+func batchEvents(transactions []models.Transaction, wallets map[uuid.UUID]*batchWallet) []models.Event {
+	balances := make(map[uuid.UUID]int64, len(wallets))
+	for id, wallet := range wallets {
+		balances[id] = wallet.Balance
+	}
+
+	events := make([]models.Event, len(transactions))
+	for i := len(transactions) - 1; i >= 0; i-- {
+		t := transactions[i]
+		events[i] = balanceEvent(t, balances[t.WalletID], wallets[t.WalletID].Currency)
+		balances[t.WalletID] -= t.Amount
+	}
+	return events
+}
+
+// recordEvent writes e to the outbox in tx, so that it gets published if and
+// only if tx commits.
+func recordEvent(ctx context.Context, tx pgx.Tx, e models.Event) error {
+	query := `INSERT INTO outbox_events (type, wallet_id, amount, balance, currency)
+		VALUES (@type, @walletID, @amount, @balance, @currency)`
+	args := pgx.NamedArgs{
+		"type":     e.Type,
+		"walletID": e.WalletID,
+		"amount":   e.Amount,
+		"balance":  e.Balance,
+		"currency": e.Currency,
+	}
+
+	if _, err := tx.Exec(ctx, query, args); err != nil {
+		return fmt.Errorf("record event: %w", err)
+	}
+	return nil
+}
+
+// publishEvents publishes events in order and returns the IDs of those
+// published. Once an event of a wallet fails, the later events of that
+// wallet are held back, so that each wallet's events arrive in order.
+func publishEvents(ctx context.Context, events []models.Event, publish PublishFunc) ([]int64, error) {
+	var (
+		published []int64
+		failed    = make(map[uuid.UUID]bool)
+		errs      []error
+	)
+	for _, e := range events {
+		if failed[e.WalletID] {
+			continue
+		}
+		if err := publish(ctx, e); err != nil {
+			failed[e.WalletID] = true
+			errs = append(errs, fmt.Errorf("publish event %d: %w", e.ID, err))
+			continue
+		}
+		published = append(published, e.ID)
+	}
+	return published, errors.Join(errs...)
+}
+
+// RelayOutbox publishes up to limit of the oldest unpublished events and
+// returns how many were published. An event is marked published only after
+// publish returns, so a crash in between publishes it again: delivery is at
+// least once.
+//
+// Only one relay runs at a time across all instances; the others return
+// right away. Otherwise two relays could publish the events of a wallet out
+// of order.
+func (pg *postgresDB) RelayOutbox(ctx context.Context, limit int, publish PublishFunc) (int, error) {
+	tx, err := pg.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock(hashtext('outbox_relay'))`).Scan(&locked); err != nil {
+		return 0, fmt.Errorf("lock outbox: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	query := `SELECT id, type, wallet_id, amount, balance, currency, created_at FROM outbox_events
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1`
+	rows, err := tx.Query(ctx, query, limit)
+	if err != nil {
+		return 0, fmt.Errorf("get pending events: %w", err)
+	}
+	events, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Event])
+	if err != nil {
+		return 0, fmt.Errorf("get pending events: %w", err)
+	}
+
+	published, publishErr := publishEvents(ctx, events, publish)
+	if len(published) > 0 {
+		if _, err := tx.Exec(ctx, `UPDATE outbox_events SET published_at = now() WHERE id = ANY($1)`, published); err != nil {
+			return 0, fmt.Errorf("mark events published: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return len(published), publishErr
+}
+
+// ListEvents returns up to limit events of a wallet with IDs above afterID,
+// oldest first. Published or not, events stay in the outbox, so a client
+// can catch up on the events it missed.
+func (pg *postgresDB) ListEvents(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Event, error) {
+	query := `SELECT id, type, wallet_id, amount, balance, currency, created_at FROM outbox_events
+		WHERE wallet_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3`
+	rows, err := pg.db.Query(ctx, query, walletID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	events, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Event])
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	return events, nil
+}
+
+// LastEventID returns the ID of the latest event of a wallet, or zero if it
+// has none.
+func (pg *postgresDB) LastEventID(ctx context.Context, walletID uuid.UUID) (int64, error) {
+	var id int64
+	err := pg.db.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM outbox_events WHERE wallet_id = $1`, walletID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("get last event: %w", err)
+	}
+	return id, nil
+}
@@ -2,24 +2,48 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"sync"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const (
+	pgUniqueViolation        = "23505"
+	pgCheckViolation         = "23514"
+	pgNumericValueOutOfRange = "22003"
+	pgUndefinedTable         = "42P01"
+)
+
 type (
 	postgresDB struct {
 		db *pgxpool.Pool
 	}
 
 	Config struct {
+		// URL is a full connection string. When set, the parts below are
+		// ignored.
+		URL string
+
 		Host    string
 		Port    string
 		User    string
 		Pass    string
 		DBName  string
 		SSLMode string
+
+		// Pool limits; zero values keep the pgxpool defaults.
+		MaxConns        int32
+		MinConns        int32
+		MaxConnLifetime time.Duration
+		MaxConnIdleTime time.Duration
 	}
 )
 
@@ -28,12 +52,56 @@ var (
 	pgOnce     sync.Once
 )
 
+// ConnString returns cfg as a Postgres connection URL.
+func (cfg Config) ConnString() string {
+	if cfg.URL != "" {
+		return cfg.URL
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Pass),
+		Host:     net.JoinHostPort(cfg.Host, cfg.Port),
+		Path:     cfg.DBName,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+func (cfg Config) poolConfig() (*pgxpool.Config, error) {
+	poolCfg, err := pgxpool.ParseConfig(cfg.ConnString())
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxConns > 0 {
+		poolCfg.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		poolCfg.MinConns = cfg.MinConns
+	}
+	if cfg.MaxConnLifetime > 0 {
+		poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolCfg.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+	poolCfg.ConnConfig.Tracer = queryTracer{}
+	return poolCfg, nil
+}
+
 func NewPG(ctx context.Context, cfg Config) (*postgresDB, error) {
 	var err error
-	connString := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", cfg.User, cfg.Pass, cfg.Host, cfg.Port, cfg.DBName, cfg.SSLMode)
 	pgOnce.Do(func() {
-		var db *pgxpool.Pool
-		db, err = pgxpool.New(ctx, connString)
+		var (
+			poolCfg *pgxpool.Config
+			db      *pgxpool.Pool
+		)
+		poolCfg, err = cfg.poolConfig()
+		if err != nil {
+			return
+		}
+		db, err = pgxpool.NewWithConfig(ctx, poolCfg)
 		if err == nil {
 			pgInstance = &postgresDB{db}
 		}
@@ -43,17 +111,56 @@ func NewPG(ctx context.Context, cfg Config) (*postgresDB, error) {
 		return nil, fmt.Errorf("unable to create connection pool: %v", err)
 	}
 
-	if err = pgInstance.ping(ctx); err != nil {
+	if err = pgInstance.Ping(ctx); err != nil {
 		return nil, err
 	}
 
 	return pgInstance, nil
 }
 
-func (pg *postgresDB) ping(ctx context.Context) error {
+func (pg *postgresDB) Ping(ctx context.Context) error {
 	return pg.db.Ping(ctx)
 }
 
+// SchemaVersion reads the migration version from the golang-migrate
+// bookkeeping table. A database that was never migrated is at version 0.
+func (pg *postgresDB) SchemaVersion(ctx context.Context) (version uint, dirty bool, err error) {
+	var v int64
+	err = pg.db.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&v, &dirty)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows), errors.As(err, &pgErr) && pgErr.Code == pgUndefinedTable:
+		return 0, false, nil
+	case err != nil:
+		return 0, false, fmt.Errorf("get schema version: %w", err)
+	}
+	return uint(v), dirty, nil
+}
+
+// Stat returns a snapshot of the connection pool statistics.
+func (pg *postgresDB) Stat() *pgxpool.Stat {
+	return pg.db.Stat()
+}
+
 func (pg *postgresDB) Close() {
 	pg.db.Close()
 }
+
+// mapPGError turns constraint violations into the errors the rest of the app
+// checks for, keeping the original message.
+func mapPGError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		return fmt.Errorf("%w: %v", custom_errors.ErrWalletExists, err)
+	case pgCheckViolation:
+		return fmt.Errorf("%w: %v", custom_errors.ErrNegativeBalance, err)
+	case pgNumericValueOutOfRange:
+		return fmt.Errorf("%w: %v", custom_errors.ErrBalanceOverflow, err)
+	}
+	return err
+}
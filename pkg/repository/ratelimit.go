@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+	"time"
+	"wallet-app/pkg/ratelimit"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pgRateLimits keeps the rate limit buckets in Postgres, so that every
+// instance of the service counts against the same limits.
+type pgRateLimits struct {
+	db        *pgxpool.Pool
+	lastSweep atomic.Int64
+	now       func() time.Time
+}
+
+// RateLimits returns a rate limit store shared by all instances using the
+// database.
+func (pg *postgresDB) RateLimits() ratelimit.Store {
+	return &pgRateLimits{db: pg.db, now: time.Now}
+}
+
+func (s *pgRateLimits) Take(ctx context.Context, keys []string, limit ratelimit.Limit) (res ratelimit.Result, err error) {
+	s.sweep(ctx)
+
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return res, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Sorted, so that requests sharing buckets lock them in the same order.
+	keys = slices.Compact(slices.Sorted(slices.Values(keys)))
+
+	// Create the buckets full if they don't exist, so that there is always a
+	// row to lock. A concurrent insert of the same key makes this wait for it.
+	now := s.now()
+	_, err = tx.Exec(ctx, `INSERT INTO rate_limit_buckets (key, tokens, updated_at, full_at)
+		SELECT key, $2, $3, $3 FROM unnest($1::text[]) AS key ORDER BY key
+		ON CONFLICT (key) DO NOTHING`, keys, float64(limit.Burst), now)
+	if err != nil {
+		return res, fmt.Errorf("create buckets: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT tokens, updated_at FROM rate_limit_buckets
+		WHERE key = ANY($1) ORDER BY key FOR UPDATE`, keys)
+	if err != nil {
+		return res, fmt.Errorf("get buckets: %w", err)
+	}
+	buckets, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ratelimit.Bucket, error) {
+		var b ratelimit.Bucket
+		err := row.Scan(&b.Tokens, &b.Updated)
+		return b, err
+	})
+	if err != nil {
+		return res, fmt.Errorf("get buckets: %w", err)
+	}
+
+	// Refill up to when the locks were acquired, not when we started waiting.
+	buckets, res = ratelimit.TakeAll(buckets, limit, s.now())
+
+	query := `UPDATE rate_limit_buckets
+		SET tokens = @tokens, updated_at = @updatedAt, full_at = @fullAt
+		WHERE key = @key`
+	batch := &pgx.Batch{}
+	for i, bucket := range buckets {
+		batch.Queue(query, pgx.NamedArgs{
+			"key":       keys[i],
+			"tokens":    bucket.Tokens,
+			"updatedAt": bucket.Updated,
+			"fullAt":    bucket.FullAt(limit),
+		})
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return res, fmt.Errorf("save buckets: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return res, fmt.Errorf("commit tx: %w", err)
+	}
+	return res, nil
+}
+
+// sweep deletes the refilled buckets, at most once per SweepInterval per
+// instance.
+func (s *pgRateLimits) sweep(ctx context.Context) {
+	now := s.now()
+	last := s.lastSweep.Load()
+	if now.Sub(time.Unix(0, last)) < ratelimit.SweepInterval || !s.lastSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	if _, err := s.db.Exec(ctx, `DELETE FROM rate_limit_buckets WHERE full_at <= $1`, now); err != nil {
+		slog.WarnContext(ctx, "sweep rate limit buckets", slog.Any("error", err))
+	}
+}
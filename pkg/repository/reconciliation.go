@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// reconcileQuery checks the wallets matching condition against their ledger
+// and reports the mismatches of run @runID. It returns how many wallets were
+// checked and how many mismatched. Being one statement, it sees every
+// balance together with the transactions that made it.
+func reconcileQuery(condition string) string {
+	return `WITH checked AS (
+			SELECT w.id, w.balance, COALESCE(SUM(t.amount), 0)::BIGINT AS ledger_total
+			FROM wallets w
+			LEFT JOIN wallet_transactions t ON t.wallet_id = w.id
+			WHERE ` + condition + `
+			GROUP BY w.id, w.balance
+		), found AS (
+			INSERT INTO reconciliation_discrepancies (run_id, wallet_id, balance, ledger_total)
+			SELECT @runID, id, balance, ledger_total FROM checked WHERE balance <> ledger_total
+			RETURNING wallet_id
+		)
+		SELECT (SELECT count(*) FROM checked), (SELECT count(*) FROM found)`
+}
+
+// Reconcile compares the balance of every wallet with the sum of its
+// transactions and records the wallets where they differ.
+func (pg *postgresDB) Reconcile(ctx context.Context, opts models.ReconcileOptions) (models.Reconciliation, error) {
+	run := models.Reconciliation{ID: uuid.New(), Streaming: opts.Stream}
+
+	query := `INSERT INTO reconciliation_runs (id, streaming) VALUES ($1, $2) RETURNING started_at`
+	if err := pg.db.QueryRow(ctx, query, run.ID, run.Streaming).Scan(&run.StartedAt); err != nil {
+		return run, fmt.Errorf("start reconciliation: %w", err)
+	}
+
+	var err error
+	if opts.Stream {
+		err = pg.reconcileStream(ctx, &run, opts.BatchSize)
+	} else {
+		err = pg.reconcileBatch(ctx, &run, "true", pgx.NamedArgs{})
+	}
+	if err != nil {
+		return run, err
+	}
+
+	query = `UPDATE reconciliation_runs SET finished_at = clock_timestamp(), wallets = $2, discrepancies = $3
+		WHERE id = $1
+		RETURNING finished_at`
+	if err := pg.db.QueryRow(ctx, query, run.ID, run.Wallets, run.Discrepancies).Scan(&run.FinishedAt); err != nil {
+		return run, fmt.Errorf("finish reconciliation: %w", err)
+	}
+	return run, nil
+}
+
+// reconcileStream walks the wallets in order of their IDs, batchSize at a
+// time, so that only one batch of IDs is ever held in memory.
+func (pg *postgresDB) reconcileStream(ctx context.Context, run *models.Reconciliation, batchSize int) error {
+	var after uuid.UUID
+	for {
+		rows, err := pg.db.Query(ctx, `SELECT id FROM wallets WHERE id > $1 ORDER BY id LIMIT $2`, after, batchSize)
+		if err != nil {
+			return fmt.Errorf("list wallets: %w", err)
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+		if err != nil {
+			return fmt.Errorf("list wallets: %w", err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := pg.reconcileBatch(ctx, run, "w.id = ANY(@walletIDs)", pgx.NamedArgs{"walletIDs": ids}); err != nil {
+			return err
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+// reconcileBatch checks the wallets matching condition and adds the counts
+// to run.
+func (pg *postgresDB) reconcileBatch(ctx context.Context, run *models.Reconciliation, condition string, args pgx.NamedArgs) error {
+	args["runID"] = run.ID
+
+	var wallets, discrepancies int64
+	if err := pg.db.QueryRow(withStatementName(ctx, "reconcile wallets"), reconcileQuery(condition), args).Scan(&wallets, &discrepancies); err != nil {
+		return fmt.Errorf("reconcile: %w", err)
+	}
+	run.Wallets += wallets
+	run.Discrepancies += discrepancies
+	return nil
+}
+
+func (pg *postgresDB) GetLatestReconciliation(ctx context.Context) (models.Reconciliation, error) {
+	query := `SELECT id, streaming, started_at, finished_at, wallets, discrepancies FROM reconciliation_runs
+		WHERE finished_at IS NOT NULL
+		ORDER BY finished_at DESC
+		LIMIT 1`
+
+	var run models.Reconciliation
+	err := pg.db.QueryRow(ctx, query).
+		Scan(&run.ID, &run.Streaming, &run.StartedAt, &run.FinishedAt, &run.Wallets, &run.Discrepancies)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return run, custom_errors.ErrReconciliationNotFound
+	}
+	if err != nil {
+		return run, fmt.Errorf("get reconciliation: %w", err)
+	}
+	return run, nil
+}
@@ -2,24 +2,58 @@ package repository
 
 import (
 	"context"
+	"time"
+	"wallet-app/pkg/models"
 
 	"github.com/google/uuid"
 )
 
 type Database interface {
 	Close()
-	NewWallet(ctx context.Context, walletID uuid.UUID, amount int) error
-	Deposit(ctx context.Context, walletID uuid.UUID, amount int) error
-	Withdraw(ctx context.Context, walletID uuid.UUID, amount int) error
-	GetBalance(ctx context.Context, walletID uuid.UUID) (int, error)
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (version uint, dirty bool, err error)
+	NewWallet(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error
+	Deposit(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error
+	Withdraw(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error
+	Transfer(ctx context.Context, fromID, toID uuid.UUID, currency string, amount int64) error
+	Batch(ctx context.Context, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, error)
+	GetBalance(ctx context.Context, walletID uuid.UUID) (int64, error)
+	GetBalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (int64, error)
+	TakeBalanceSnapshots(ctx context.Context, at time.Time) (int64, error)
+	GetWallet(ctx context.Context, walletID uuid.UUID) (models.Wallet, error)
+	SetWalletStatus(ctx context.Context, walletID uuid.UUID, status, reason string) (models.WalletStatusChange, error)
+	ListTransactions(ctx context.Context, walletID uuid.UUID, filter models.TransactionFilter) ([]models.Transaction, error)
+	GetIdempotencyKey(ctx context.Context, key string) (models.IdempotencyKey, error)
+	Authorize(ctx context.Context, hold models.Hold) error
+	Capture(ctx context.Context, holdID uuid.UUID, amount int64) error
+	Release(ctx context.Context, holdID uuid.UUID) error
+	GetHold(ctx context.Context, holdID uuid.UUID) (models.Hold, error)
+	CreateClient(ctx context.Context, client models.Client, keyHash string) error
+	GetClientByKeyHash(ctx context.Context, keyHash string) (models.Client, error)
+	RevokeClient(ctx context.Context, clientID uuid.UUID) error
+	Reconcile(ctx context.Context, opts models.ReconcileOptions) (models.Reconciliation, error)
+	GetLatestReconciliation(ctx context.Context) (models.Reconciliation, error)
+	TrialBalance(ctx context.Context) ([]models.TrialBalanceLine, error)
+	RelayOutbox(ctx context.Context, limit int, publish PublishFunc) (int, error)
+	ListEvents(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Event, error)
+	LastEventID(ctx context.Context, walletID uuid.UUID) (int64, error)
+	CreateWebhook(ctx context.Context, webhook models.Webhook) error
+	GetWebhook(ctx context.Context, webhookID uuid.UUID) (models.Webhook, error)
+	ListWebhooks(ctx context.Context, clientID uuid.UUID) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error
+	EnqueueWebhookDeliveries(ctx context.Context, event models.Event) (int64, error)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	GetWebhookDelivery(ctx context.Context, deliveryID int64) (models.WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit int) ([]models.WebhookDelivery, error)
 }
 
 type Repository struct {
 	Database
 }
 
-func NewRepository(pg *postgresDB) *Repository {
+func NewRepository(db Database) *Repository {
 	return &Repository{
-		Database: pg,
+		Database: db,
 	}
 }
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetBalanceAt returns the balance of walletID as of at: its latest snapshot
+// taken at or before at plus the transactions made since, up to at.
+func (pg *postgresDB) GetBalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (int64, error) {
+	var exists bool
+	if err := pg.db.QueryRow(ctx, `SELECT true FROM wallets WHERE id = $1`, walletID).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("get wallet: %w", err)
+	}
+
+	query := `WITH snapshot AS (
+			SELECT taken_at, balance FROM balance_snapshots
+			WHERE wallet_id = @walletID AND taken_at <= @at
+			ORDER BY taken_at DESC
+			LIMIT 1
+		)
+		SELECT COALESCE((SELECT balance FROM snapshot), 0) + COALESCE(SUM(amount), 0)::BIGINT
+		FROM wallet_transactions
+		WHERE wallet_id = @walletID
+			AND created_at > COALESCE((SELECT taken_at FROM snapshot), '-infinity')
+			AND created_at <= @at`
+	args := pgx.NamedArgs{"walletID": walletID, "at": at}
+
+	var balance int64
+	if err := pg.db.QueryRow(ctx, query, args).Scan(&balance); err != nil {
+		return 0, fmt.Errorf("get balance at: %w", err)
+	}
+	return balance, nil
+}
+
+// TakeBalanceSnapshots snapshots, as of at, the balance of every wallet with
+// transactions since the previous snapshots. at must be far enough in the
+// past that no transaction created before it can still commit.
+func (pg *postgresDB) TakeBalanceSnapshots(ctx context.Context, at time.Time) (int64, error) {
+	// Every run snapshots all the wallets changed since the run before, so
+	// only transactions after the latest snapshot need looking at.
+	query := `WITH changed AS (
+			SELECT DISTINCT wallet_id FROM wallet_transactions
+			WHERE created_at <= @at AND created_at > COALESCE(
+				(SELECT max(taken_at) FROM balance_snapshots WHERE taken_at <= @at), '-infinity')
+		)
+		INSERT INTO balance_snapshots (wallet_id, taken_at, balance)
+		SELECT changed.wallet_id, @at, COALESCE(snapshot.balance, 0) + since.amount
+		FROM changed
+		LEFT JOIN LATERAL (
+			SELECT taken_at, balance FROM balance_snapshots
+			WHERE wallet_id = changed.wallet_id AND taken_at <= @at
+			ORDER BY taken_at DESC
+			LIMIT 1
+		) snapshot ON true
+		CROSS JOIN LATERAL (
+			SELECT SUM(amount)::BIGINT AS amount FROM wallet_transactions
+			WHERE wallet_id = changed.wallet_id
+				AND created_at > COALESCE(snapshot.taken_at, '-infinity')
+				AND created_at <= @at
+		) since
+		ON CONFLICT DO NOTHING`
+
+	tag, err := pg.db.Exec(ctx, query, pgx.NamedArgs{"at": at})
+	if err != nil {
+		return 0, fmt.Errorf("take balance snapshots: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"wallet-app/pkg/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryTracer puts every query, and every wait for a pooled connection, in a
+// span of its own, so lock waits can be told apart from pool or network waits.
+// Queries are also logged at debug level.
+type queryTracer struct{}
+
+var (
+	_ pgx.QueryTracer       = queryTracer{}
+	_ pgxpool.AcquireTracer = queryTracer{}
+)
+
+type (
+	querySpanKey     struct{}
+	acquireSpanKey   struct{}
+	statementNameKey struct{}
+)
+
+// withStatementName names the spans of the queries run with ctx, for
+// statements that statementName can't summarise, such as those whose main
+// statement reads only from their common table expressions.
+func withStatementName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, statementNameKey{}, name)
+}
+
+type querySpan struct {
+	span      trace.Span
+	statement string
+	start     time.Time
+}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	operation, table := statementName(data.SQL)
+
+	name, ok := ctx.Value(statementNameKey{}).(string)
+	if !ok {
+		name = operation
+		if table != "" {
+			name += " " + table
+		}
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(data.SQL),
+		),
+		trace.WithAttributes(tracing.Attributes(ctx)...),
+	)
+	if table != "" {
+		span.SetAttributes(semconv.DBCollectionName(table))
+	}
+	return context.WithValue(ctx, querySpanKey{}, querySpan{span: span, statement: name, start: time.Now()})
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	q, ok := ctx.Value(querySpanKey{}).(querySpan)
+	if !ok {
+		return
+	}
+
+	err := data.Err
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = nil
+	}
+
+	if slog.Default().Enabled(ctx, slog.LevelDebug) {
+		attrs := []slog.Attr{
+			slog.String("statement", q.statement),
+			slog.Duration("duration", time.Since(q.start)),
+		}
+		for _, attr := range tracing.Attributes(ctx) {
+			attrs = append(attrs, slog.String(string(attr.Key), attr.Value.Emit()))
+		}
+		if err != nil {
+			attrs = append(attrs, slog.Any("error", err))
+		}
+		slog.LogAttrs(ctx, slog.LevelDebug, "query", attrs...)
+	}
+
+	tracing.End(q.span, err)
+}
+
+func (queryTracer) TraceAcquireStart(ctx context.Context, _ *pgxpool.Pool, _ pgxpool.TraceAcquireStartData) context.Context {
+	ctx, span := tracing.Tracer().Start(ctx, "pool.acquire", trace.WithAttributes(semconv.DBSystemPostgreSQL))
+	return context.WithValue(ctx, acquireSpanKey{}, span)
+}
+
+func (queryTracer) TraceAcquireEnd(ctx context.Context, _ *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	if span, ok := ctx.Value(acquireSpanKey{}).(trace.Span); ok {
+		tracing.End(span, data.Err)
+	}
+}
+
+// statementName summarises sql as its operation and the table it works on,
+// such as SELECT and wallets, for naming spans without the full query text.
+// The operation of a statement with common table expressions is that of
+// its main statement. table is empty for statements like BEGIN, and for
+// those reading only from subqueries.
+func statementName(sql string) (operation, table string) {
+	words := sqlWords(sql)
+	if len(words) == 0 {
+		return "", ""
+	}
+	operation = strings.ToUpper(words[0].text)
+
+	main := 0
+	if operation == "WITH" {
+		// The main statement is the first one outside the parentheses of
+		// the expressions.
+		for i, w := range words[1:] {
+			if w.depth == 0 && slices.Contains(statementKeywords, strings.ToUpper(w.text)) {
+				main = i + 1
+				break
+			}
+		}
+		if main == 0 {
+			return operation, ""
+		}
+		operation = strings.ToUpper(words[main].text)
+	}
+
+	// The word that precedes the table name for each operation.
+	var marker string
+	switch operation {
+	case "SELECT", "DELETE":
+		marker = "FROM"
+	case "INSERT":
+		marker = "INTO"
+	case "UPDATE":
+		marker = "UPDATE"
+	default:
+		return operation, ""
+	}
+
+	// Only the clauses of the main statement count, not its subqueries.
+	for i := main; i < len(words)-1; i++ {
+		if words[i].depth == 0 && strings.EqualFold(words[i].text, marker) {
+			if words[i+1].depth == 0 {
+				return operation, words[i+1].text
+			}
+			return operation, ""
+		}
+	}
+	return operation, ""
+}
+
+var statementKeywords = []string{"SELECT", "INSERT", "UPDATE", "DELETE"}
+
+type sqlWord struct {
+	text string
+	// depth is the number of parentheses the word is in.
+	depth int
+}
+
+// sqlWords splits sql into words, telling which parentheses each is in.
+// Quoted strings are not told apart; the queries here don't need it.
+func sqlWords(sql string) []sqlWord {
+	var (
+		words []sqlWord
+		depth int
+		start = -1
+	)
+	for i, r := range sql + " " {
+		separator := unicode.IsSpace(r) || strings.ContainsRune("(),;", r)
+		if separator && start >= 0 {
+			words = append(words, sqlWord{text: sql[start:i], depth: depth})
+			start = -1
+		}
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case !separator && start < 0:
+			start = i
+		}
+	}
+	return words
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStatementName(t *testing.T) {
+	tests := []struct {
+		sql, operation, table string
+	}{
+		{`SELECT balance, currency, status FROM wallets WHERE id=@walletID FOR UPDATE`, "SELECT", "wallets"},
+		{"select COALESCE(SUM(amount), 0)::BIGINT from holds\n\t\tWHERE wallet_id = $1", "SELECT", "holds"},
+		{`INSERT INTO holds (id, wallet_id) VALUES ($1, $2)`, "INSERT", "holds"},
+		{`UPDATE wallets SET balance = balance - $1 WHERE id = $2`, "UPDATE", "wallets"},
+		{`DELETE FROM idempotency_keys WHERE key = $1`, "DELETE", "idempotency_keys"},
+		{"WITH entry AS (\n\tINSERT INTO journal_entries (operation) VALUES ($1) RETURNING id\n)\nINSERT INTO journal_postings (entry_id) SELECT id FROM entry", "INSERT", "journal_postings"},
+		{`WITH snapshot AS (SELECT balance FROM balance_snapshots) SELECT COALESCE((SELECT balance FROM snapshot), 0) + SUM(amount) FROM wallet_transactions`, "SELECT", "wallet_transactions"},
+		{`WITH due AS (SELECT id FROM d), d AS (UPDATE webhook_deliveries SET n = 1 RETURNING *) UPDATE outbox_events SET n = 1`, "UPDATE", "outbox_events"},
+		{`SELECT (SELECT count(*) FROM checked), (SELECT count(*) FROM found)`, "SELECT", ""},
+		{`SELECT id FROM (SELECT id FROM wallets) w`, "SELECT", ""},
+		{`begin`, "BEGIN", ""},
+		{`SELECT 1`, "SELECT", ""},
+		{``, "", ""},
+	}
+
+	for _, tt := range tests {
+		operation, table := statementName(tt.sql)
+		assert.Equal(t, tt.operation, operation, tt.sql)
+		assert.Equal(t, tt.table, table, tt.sql)
+	}
+}
+
+func TestQueryTracerNames(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	var tracer queryTracer
+	for _, ctx := range []context.Context{t.Context(), withStatementName(t.Context(), "claim webhook_deliveries")} {
+		ctx = tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: `WITH d AS (UPDATE webhook_deliveries SET n = 1 RETURNING *) SELECT * FROM d`})
+		tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	}
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "SELECT d", spans[0].Name())
+	assert.Equal(t, "claim webhook_deliveries", spans[1].Name(), "call sites can name statements")
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func recordTransaction(ctx context.Context, tx pgx.Tx, t models.Transaction) error {
+	query := `INSERT INTO wallet_transactions (wallet_id, operation, amount, counterparty_id)
+		VALUES (@walletID, @operation, @amount, @counterpartyID)`
+	args := pgx.NamedArgs{
+		"walletID":       t.WalletID,
+		"operation":      t.Operation,
+		"amount":         t.Amount,
+		"counterpartyID": t.CounterpartyID,
+	}
+
+	if _, err := tx.Exec(ctx, query, args); err != nil {
+		return fmt.Errorf("record transaction: %w", err)
+	}
+	return nil
+}
+
+func (pg *postgresDB) ListTransactions(ctx context.Context, walletID uuid.UUID, filter models.TransactionFilter) ([]models.Transaction, error) {
+	var exists bool
+	if err := pg.db.QueryRow(ctx, `SELECT true FROM wallets WHERE id = $1`, walletID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("get wallet: %w", err)
+	}
+
+	conditions := []string{"wallet_id = @walletID"}
+	args := pgx.NamedArgs{
+		"walletID": walletID,
+		"limit":    filter.Limit,
+	}
+
+	if filter.Operation != "" {
+		conditions = append(conditions, "operation = @operation")
+		args["operation"] = filter.Operation
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= @from")
+		args["from"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at <= @to")
+		args["to"] = filter.To
+	}
+	if filter.Cursor > 0 {
+		conditions = append(conditions, "id < @cursor")
+		args["cursor"] = filter.Cursor
+	}
+
+	query := `SELECT id, wallet_id, operation, amount, created_at, counterparty_id FROM wallet_transactions
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY id DESC
+		LIMIT @limit`
+
+	rows, err := pg.db.Query(ctx, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("list transactions: %w", err)
+	}
+
+	transactions, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Transaction])
+	if err != nil {
+		return nil, fmt.Errorf("scan transactions: %w", err)
+	}
+	return transactions, nil
+}
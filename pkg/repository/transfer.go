@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func (pg *postgresDB) Transfer(ctx context.Context, fromID, toID uuid.UUID, currency string, amount int64) error {
+	if fromID == toID {
+		return custom_errors.ErrSameWallet
+	}
+
+	// Rows are always locked in id order so that two concurrent transfers
+	// in opposite directions can't deadlock each other.
+	querySelect := `SELECT id, balance, currency, status FROM wallets WHERE id = ANY(@walletIDs) ORDER BY id FOR UPDATE`
+	argsSelect := pgx.NamedArgs{
+		"walletIDs": []uuid.UUID{fromID, toID},
+	}
+
+	queryUpdate := `UPDATE wallets SET balance = balance + @amount WHERE id=@walletID`
+
+	tx, err := pg.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := claimIdempotencyKey(ctx, tx); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, querySelect, argsSelect)
+	if err != nil {
+		return fmt.Errorf("select for update: %w", err)
+	}
+
+	wallets := make(map[uuid.UUID]models.Wallet, 2)
+	var wallet models.Wallet
+	_, err = pgx.ForEachRow(rows, []any{&wallet.ID, &wallet.Balance, &wallet.Currency, &wallet.Status}, func() error {
+		wallets[wallet.ID] = wallet
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("select for update: %w", err)
+	}
+
+	if len(wallets) != 2 {
+		return fmt.Errorf("select for update: %w", custom_errors.ErrWalletNotFound)
+	}
+
+	if err := checkCanSpend(wallets[fromID].Status); err != nil {
+		return err
+	}
+	if err := checkCanReceive(wallets[toID].Status); err != nil {
+		return err
+	}
+
+	if err := checkCurrency(currency, wallets[fromID].Currency); err != nil {
+		return err
+	}
+	if err := checkCurrency(wallets[fromID].Currency, wallets[toID].Currency); err != nil {
+		return err
+	}
+
+	held, err := heldAmount(ctx, tx, fromID)
+	if err != nil {
+		return err
+	}
+	if wallets[fromID].Balance-held < amount {
+		return custom_errors.ErrNotEnoughFunds
+	}
+
+	if err := checkOverflow(wallets[toID].Balance, amount); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, queryUpdate, pgx.NamedArgs{"walletID": fromID, "amount": -amount}); err != nil {
+		return fmt.Errorf("update balance: %w", mapPGError(err))
+	}
+	if _, err := tx.Exec(ctx, queryUpdate, pgx.NamedArgs{"walletID": toID, "amount": amount}); err != nil {
+		return fmt.Errorf("update balance: %w", mapPGError(err))
+	}
+
+	out := models.Transaction{
+		WalletID:       fromID,
+		Operation:      models.OperationTransferOut,
+		Amount:         -amount,
+		CounterpartyID: &toID,
+	}
+	in := models.Transaction{
+		WalletID:       toID,
+		Operation:      models.OperationTransferIn,
+		Amount:         amount,
+		CounterpartyID: &fromID,
+	}
+	for _, t := range []models.Transaction{out, in} {
+		if err := recordTransaction(ctx, tx, t); err != nil {
+			return err
+		}
+	}
+	if err := postEntry(ctx, tx, models.OperationTransfer, transferPostings(fromID, toID, amount)); err != nil {
+		return err
+	}
+	if err := recordEvent(ctx, tx, balanceEvent(out, wallets[fromID].Balance-amount, wallets[fromID].Currency)); err != nil {
+		return err
+	}
+	if err := recordEvent(ctx, tx, balanceEvent(in, wallets[toID].Balance+amount, wallets[toID].Currency)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
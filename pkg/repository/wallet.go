@@ -3,24 +3,108 @@ package repository
 import (
 	"context"
 	"fmt"
+	"math"
 	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
-func (pg *postgresDB) NewWallet(ctx context.Context, walletID uuid.UUID, amount int) error {
-	query := `INSERT INTO wallets (id, balance) VALUES (@walletID, @amount)`
-	args := pgx.NamedArgs{"walletID": walletID, "amount": amount}
-	_, err := pg.db.Exec(ctx, query, args)
-	if err != nil {
-		return fmt.Errorf("create wallet: %w", err)
+// checkCurrency fails with ErrCurrencyMismatch when the caller asked for a
+// specific currency and the wallet holds another one. An empty want matches
+// any wallet.
+func checkCurrency(want, walletCurrency string) error {
+	if want != "" && want != walletCurrency {
+		return fmt.Errorf("%w: wallet currency is %s", custom_errors.ErrCurrencyMismatch, walletCurrency)
+	}
+	return nil
+}
+
+// checkCanReceive fails unless a wallet with status may be credited.
+func checkCanReceive(status string) error {
+	if status == models.WalletClosed {
+		return custom_errors.ErrWalletClosed
+	}
+	return nil
+}
+
+// checkCanSpend fails unless a wallet with status may be debited.
+func checkCanSpend(status string) error {
+	switch status {
+	case models.WalletClosed:
+		return custom_errors.ErrWalletClosed
+	case models.WalletFrozen:
+		return custom_errors.ErrWalletFrozen
+	}
+	return nil
+}
+
+// statusTransitions lists the statuses a wallet can move to from each status.
+// Closing a wallet is final.
+var statusTransitions = map[string][]string{
+	models.WalletActive: {models.WalletFrozen, models.WalletClosed},
+	models.WalletFrozen: {models.WalletActive},
+}
+
+func checkStatusTransition(from, to string) error {
+	for _, allowed := range statusTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s to %s", custom_errors.ErrInvalidStatusTransition, from, to)
+}
+
+// checkOverflow fails with ErrBalanceOverflow when crediting amount would
+// push balance past the largest value a balance can hold.
+func checkOverflow(balance, amount int64) error {
+	if amount > 0 && balance > math.MaxInt64-amount {
+		return custom_errors.ErrBalanceOverflow
 	}
 	return nil
 }
 
-func (pg *postgresDB) Deposit(ctx context.Context, walletID uuid.UUID, amount int) error {
-	querySelect := `SELECT balance FROM wallets WHERE id=@walletID FOR UPDATE`
+func (pg *postgresDB) NewWallet(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error {
+	query := `INSERT INTO wallets (id, owner_id, balance, currency) VALUES (@walletID, @ownerID, @amount, @currency)`
+	args := pgx.NamedArgs{"walletID": walletID, "ownerID": nullUUID(ownerFromContext(ctx)), "amount": amount, "currency": currency}
+
+	tx, err := pg.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := claimIdempotencyKey(ctx, tx); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, query, args)
+	if err != nil {
+		return fmt.Errorf("create wallet: %w", mapPGError(err))
+	}
+
+	if amount > 0 {
+		t := models.Transaction{
+			WalletID:  walletID,
+			Operation: models.OperationDeposit,
+			Amount:    amount,
+		}
+		if err := recordTransaction(ctx, tx, t); err != nil {
+			return err
+		}
+		if err := postEntry(ctx, tx, t.Operation, cashPostings(t, currency)); err != nil {
+			return err
+		}
+		if err := recordEvent(ctx, tx, balanceEvent(t, amount, currency)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (pg *postgresDB) Deposit(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error {
+	querySelect := `SELECT balance, currency, status FROM wallets WHERE id=@walletID FOR UPDATE`
 	argsSelect := pgx.NamedArgs{
 		"walletID": walletID,
 	}
@@ -37,21 +121,56 @@ func (pg *postgresDB) Deposit(ctx context.Context, walletID uuid.UUID, amount in
 	}
 	defer tx.Rollback(ctx)
 
-	var balance int
+	if err := claimIdempotencyKey(ctx, tx); err != nil {
+		return err
+	}
+
+	var (
+		balance        int64
+		walletCurrency string
+		status         string
+	)
 
-	if err := tx.QueryRow(ctx, querySelect, argsSelect).Scan(&balance); err != nil {
+	if err := tx.QueryRow(ctx, querySelect, argsSelect).Scan(&balance, &walletCurrency, &status); err != nil {
 		return fmt.Errorf("select for update: %w", err)
 	}
 
+	if err := checkCanReceive(status); err != nil {
+		return err
+	}
+
+	if err := checkCurrency(currency, walletCurrency); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(balance, amount); err != nil {
+		return err
+	}
+
 	_, err = tx.Exec(ctx, queryUpdate, argsUpdate)
 	if err != nil {
-		return fmt.Errorf("update balance: %w", err)
+		return fmt.Errorf("update balance: %w", mapPGError(err))
+	}
+
+	t := models.Transaction{
+		WalletID:  walletID,
+		Operation: models.OperationDeposit,
+		Amount:    amount,
+	}
+	if err := recordTransaction(ctx, tx, t); err != nil {
+		return err
+	}
+	if err := postEntry(ctx, tx, t.Operation, cashPostings(t, walletCurrency)); err != nil {
+		return err
+	}
+	if err := recordEvent(ctx, tx, balanceEvent(t, balance+t.Amount, walletCurrency)); err != nil {
+		return err
 	}
 	return tx.Commit(ctx)
 }
 
-func (pg *postgresDB) Withdraw(ctx context.Context, walletID uuid.UUID, amount int) error {
-	querySelect := `SELECT balance FROM wallets WHERE id=@walletID FOR UPDATE`
+func (pg *postgresDB) Withdraw(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error {
+	querySelect := `SELECT balance, currency, status FROM wallets WHERE id=@walletID FOR UPDATE`
 	argsSelect := pgx.NamedArgs{
 		"walletID": walletID,
 	}
@@ -68,28 +187,124 @@ func (pg *postgresDB) Withdraw(ctx context.Context, walletID uuid.UUID, amount i
 	}
 	defer tx.Rollback(ctx)
 
-	var balance int
+	if err := claimIdempotencyKey(ctx, tx); err != nil {
+		return err
+	}
+
+	var (
+		balance        int64
+		walletCurrency string
+		status         string
+	)
 
-	if err := tx.QueryRow(ctx, querySelect, argsSelect).Scan(&balance); err != nil {
+	if err := tx.QueryRow(ctx, querySelect, argsSelect).Scan(&balance, &walletCurrency, &status); err != nil {
 		return fmt.Errorf("select for update: %w", err)
 	}
 
-	if balance < amount {
+	if err := checkCanSpend(status); err != nil {
+		return err
+	}
+
+	if err := checkCurrency(currency, walletCurrency); err != nil {
+		return err
+	}
+
+	held, err := heldAmount(ctx, tx, walletID)
+	if err != nil {
+		return err
+	}
+	if balance-held < amount {
 		return custom_errors.ErrNotEnoughFunds
 	}
 
 	_, err = tx.Exec(ctx, queryUpdate, argsUpdate)
 	if err != nil {
-		return fmt.Errorf("update balance: %w", err)
+		return fmt.Errorf("update balance: %w", mapPGError(err))
+	}
+
+	t := models.Transaction{
+		WalletID:  walletID,
+		Operation: models.OperationWithdraw,
+		Amount:    -amount,
+	}
+	if err := recordTransaction(ctx, tx, t); err != nil {
+		return err
+	}
+	if err := postEntry(ctx, tx, t.Operation, cashPostings(t, walletCurrency)); err != nil {
+		return err
+	}
+	if err := recordEvent(ctx, tx, balanceEvent(t, balance+t.Amount, walletCurrency)); err != nil {
+		return err
 	}
 	return tx.Commit(ctx)
 }
 
-func (pg *postgresDB) GetBalance(ctx context.Context, walletID uuid.UUID) (int, error) {
-	var balance int
+func (pg *postgresDB) GetBalance(ctx context.Context, walletID uuid.UUID) (int64, error) {
+	var balance int64
 	err := pg.db.QueryRow(ctx, `SELECT balance FROM wallets WHERE id = $1`, walletID).Scan(&balance)
 	if err != nil {
 		return 0, fmt.Errorf("get balance: %w", err)
 	}
 	return balance, nil
 }
+
+func (pg *postgresDB) GetWallet(ctx context.Context, walletID uuid.UUID) (models.Wallet, error) {
+	var (
+		wallet  models.Wallet
+		ownerID uuid.NullUUID
+	)
+	err := pg.db.QueryRow(ctx, `SELECT id, owner_id, balance, currency, status FROM wallets WHERE id = $1`, walletID).
+		Scan(&wallet.ID, &ownerID, &wallet.Balance, &wallet.Currency, &wallet.Status)
+	if err != nil {
+		return wallet, fmt.Errorf("get wallet: %w", err)
+	}
+	wallet.OwnerID = ownerID.UUID
+
+	held, err := heldAmount(ctx, pg.db, walletID)
+	if err != nil {
+		return wallet, err
+	}
+	wallet.Available = wallet.Balance - held
+	return wallet, nil
+}
+
+func (pg *postgresDB) SetWalletStatus(ctx context.Context, walletID uuid.UUID, status, reason string) (models.WalletStatusChange, error) {
+	change := models.WalletStatusChange{
+		WalletID: walletID,
+		To:       status,
+		Reason:   reason,
+	}
+
+	queryInsert := `INSERT INTO wallet_status_changes (wallet_id, from_status, to_status, reason)
+		VALUES (@walletID, @from, @to, @reason)
+		RETURNING created_at`
+
+	tx, err := pg.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return change, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.QueryRow(ctx, `SELECT status FROM wallets WHERE id = $1 FOR UPDATE`, walletID).Scan(&change.From); err != nil {
+		return change, fmt.Errorf("select for update: %w", err)
+	}
+
+	if err := checkStatusTransition(change.From, status); err != nil {
+		return change, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE wallets SET status = $1 WHERE id = $2`, status, walletID); err != nil {
+		return change, fmt.Errorf("update status: %w", err)
+	}
+
+	argsInsert := pgx.NamedArgs{
+		"walletID": walletID,
+		"from":     change.From,
+		"to":       status,
+		"reason":   reason,
+	}
+	if err := tx.QueryRow(ctx, queryInsert, argsInsert).Scan(&change.CreatedAt); err != nil {
+		return change, fmt.Errorf("record status change: %w", err)
+	}
+	return change, tx.Commit(ctx)
+}
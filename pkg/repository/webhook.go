@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const webhookColumns = `id, client_id, url, event_types, secret, created_at`
+
+// deliveryColumns are read from webhook_deliveries d joined with the event
+// e it delivers.
+const deliveryColumns = `d.id, d.webhook_id, d.status, d.attempts, d.next_attempt_at, d.last_error, d.last_status_code, d.created_at,
+	e.id, e.type, e.wallet_id, e.amount, e.balance, e.currency, e.created_at`
+
+func scanWebhook(row pgx.Row) (models.Webhook, error) {
+	var w models.Webhook
+	err := row.Scan(&w.ID, &w.ClientID, &w.URL, &w.EventTypes, &w.Secret, &w.CreatedAt)
+	return w, err
+}
+
+func scanDelivery(row pgx.Row) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := row.Scan(&d.ID, &d.WebhookID, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.LastStatusCode, &d.CreatedAt,
+		&d.Event.ID, &d.Event.Type, &d.Event.WalletID, &d.Event.Amount, &d.Event.Balance, &d.Event.Currency, &d.Event.CreatedAt)
+	return d, err
+}
+
+func (pg *postgresDB) CreateWebhook(ctx context.Context, webhook models.Webhook) error {
+	query := `INSERT INTO webhooks (id, client_id, url, event_types, secret, created_at)
+		VALUES (@id, @clientID, @url, @eventTypes, @secret, @createdAt)`
+	args := pgx.NamedArgs{
+		"id":         webhook.ID,
+		"clientID":   webhook.ClientID,
+		"url":        webhook.URL,
+		"eventTypes": webhook.EventTypes,
+		"secret":     webhook.Secret,
+		"createdAt":  webhook.CreatedAt,
+	}
+
+	if _, err := pg.db.Exec(ctx, query, args); err != nil {
+		return fmt.Errorf("create webhook: %w", err)
+	}
+	return nil
+}
+
+func (pg *postgresDB) GetWebhook(ctx context.Context, webhookID uuid.UUID) (models.Webhook, error) {
+	webhook, err := scanWebhook(pg.db.QueryRow(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, webhookID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return webhook, custom_errors.ErrWebhookNotFound
+	}
+	if err != nil {
+		return webhook, fmt.Errorf("get webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+func (pg *postgresDB) ListWebhooks(ctx context.Context, clientID uuid.UUID) ([]models.Webhook, error) {
+	rows, err := pg.db.Query(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE client_id = $1 ORDER BY created_at, id`, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	webhooks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Webhook, error) { return scanWebhook(row) })
+	if err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes the webhook together with its deliveries.
+func (pg *postgresDB) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error {
+	tag, err := pg.db.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, webhookID)
+	if err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return custom_errors.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// EnqueueWebhookDeliveries creates a pending delivery of event for every
+// webhook subscribed to its type whose client may see the wallet. Enqueuing
+// an event twice creates no more deliveries, since events may be published
+// more than once.
+func (pg *postgresDB) EnqueueWebhookDeliveries(ctx context.Context, event models.Event) (int64, error) {
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_id)
+		SELECT h.id, @eventID
+		FROM webhooks h
+		JOIN api_clients c ON c.id = h.client_id
+		WHERE @type = ANY(h.event_types)
+			AND c.revoked_at IS NULL
+			AND (c.admin OR c.id = (SELECT owner_id FROM wallets WHERE id = @walletID))
+		ON CONFLICT (webhook_id, event_id) DO NOTHING`
+	args := pgx.NamedArgs{"eventID": event.ID, "type": event.Type, "walletID": event.WalletID}
+
+	tag, err := pg.db.Exec(ctx, query, args)
+	if err != nil {
+		return 0, fmt.Errorf("enqueue webhook deliveries: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries that are due
+// and postpones them by lease, so that no other worker picks them up while
+// they are being sent. A worker that dies mid-delivery leaves them to be
+// retried once the lease runs out.
+func (pg *postgresDB) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	query := `WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, id
+			LIMIT @limit
+			FOR UPDATE SKIP LOCKED
+		), d AS (
+			UPDATE webhook_deliveries SET next_attempt_at = now() + @lease::interval
+			WHERE id IN (SELECT id FROM due)
+			RETURNING *
+		)
+		SELECT ` + deliveryColumns + `
+		FROM d JOIN outbox_events e ON e.id = d.event_id
+		ORDER BY d.id`
+	rows, err := pg.db.Query(withStatementName(ctx, "claim webhook_deliveries"), query, pgx.NamedArgs{"limit": limit, "lease": lease})
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WebhookDelivery, error) { return scanDelivery(row) })
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery saves the status, attempts and last error of a
+// delivery.
+func (pg *postgresDB) UpdateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	query := `UPDATE webhook_deliveries
+		SET status = @status, attempts = @attempts, next_attempt_at = @nextAttemptAt, last_error = @lastError, last_status_code = @lastStatusCode
+		WHERE id = @id`
+	args := pgx.NamedArgs{
+		"id":             d.ID,
+		"status":         d.Status,
+		"attempts":       d.Attempts,
+		"nextAttemptAt":  d.NextAttemptAt,
+		"lastError":      d.LastError,
+		"lastStatusCode": d.LastStatusCode,
+	}
+
+	tag, err := pg.db.Exec(ctx, query, args)
+	if err != nil {
+		return fmt.Errorf("update webhook delivery: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return custom_errors.ErrDeliveryNotFound
+	}
+	return nil
+}
+
+func (pg *postgresDB) GetWebhookDelivery(ctx context.Context, deliveryID int64) (models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d JOIN outbox_events e ON e.id = d.event_id WHERE d.id = $1`
+	d, err := scanDelivery(pg.db.QueryRow(ctx, query, deliveryID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return d, custom_errors.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return d, fmt.Errorf("get webhook delivery: %w", err)
+	}
+	return d, nil
+}
+
+// ListWebhookDeliveries returns up to limit deliveries of a webhook, newest
+// first. An empty status matches any.
+func (pg *postgresDB) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit int) ([]models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d JOIN outbox_events e ON e.id = d.event_id
+		WHERE d.webhook_id = @webhookID AND (@status::text = '' OR d.status = @status)
+		ORDER BY d.id DESC
+		LIMIT @limit`
+	rows, err := pg.db.Query(ctx, query, pgx.NamedArgs{"webhookID": webhookID, "status": status, "limit": limit})
+	if err != nil {
+		return nil, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WebhookDelivery, error) { return scanDelivery(row) })
+	if err != nil {
+		return nil, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
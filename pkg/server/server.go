@@ -2,25 +2,132 @@ package server
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"time"
 )
 
-type Server struct {
-	httpServer *http.Server
+type (
+	Server struct {
+		httpServer *http.Server
+		cfg        Config
+		// deadline ends the shutdown, set once it starts.
+		deadline time.Time
+	}
+
+	// Config holds the server timeouts. Zero values fall back to the
+	// defaults below.
+	Config struct {
+		ReadTimeout  time.Duration
+		WriteTimeout time.Duration
+		IdleTimeout  time.Duration
+		// ShutdownTimeout is how long in-flight requests get to finish once
+		// the drain delay is over. The rest of the shutdown shares the same
+		// deadline, see ShutdownContext.
+		ShutdownTimeout time.Duration
+		// DrainDelay keeps serving for a while after ctx is done, so that load
+		// balancers see the instance become unready before it stops
+		// accepting connections. Zero shuts down right away.
+		DrainDelay time.Duration
+	}
+)
+
+const (
+	DefaultReadTimeout     = 10 * time.Second
+	DefaultWriteTimeout    = 10 * time.Second
+	DefaultIdleTimeout     = 60 * time.Second
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+func New(cfg Config) *Server {
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = DefaultReadTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = DefaultWriteTimeout
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = DefaultIdleTimeout
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	return &Server{cfg: cfg}
+}
+
+// Run listens on port and serves handler until ctx is done, then shuts down
+// gracefully. See Serve.
+func (s *Server) Run(ctx context.Context, port int, hander http.Handler) error {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	slog.Info("server started", slog.String("addr", l.Addr().String()))
+	return s.Serve(ctx, l, hander)
 }
 
-func (s *Server) Run(port string, hander http.Handler) error {
+// Serve serves handler on l until ctx is done. It then stops accepting new
+// connections and waits up to the shutdown timeout, after the drain delay,
+// for in-flight requests to finish before closing the remaining
+// connections. It returns nil after a clean shutdown.
+func (s *Server) Serve(ctx context.Context, l net.Listener, hander http.Handler) error {
 	s.httpServer = &http.Server{
-		Addr:           ":" + port,
 		Handler:        hander,
 		MaxHeaderBytes: 1 << 20, // 1MB
-		ReadTimeout:    time.Second * 10,
-		WriteTimeout:   time.Second * 10,
+		ReadTimeout:    s.cfg.ReadTimeout,
+		WriteTimeout:   s.cfg.WriteTimeout,
+		IdleTimeout:    s.cfg.IdleTimeout,
+		ErrorLog:       slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.httpServer.Serve(l)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	s.deadline = time.Now().Add(s.cfg.DrainDelay + s.cfg.ShutdownTimeout)
+
+	if s.cfg.DrainDelay > 0 {
+		slog.Info("draining before shutdown", slog.Duration("delay", s.cfg.DrainDelay))
+		select {
+		case <-time.After(s.cfg.DrainDelay):
+		case err := <-serveErr:
+			return err
+		}
+	}
+
+	slog.Info("shutting down, waiting for in-flight requests", slog.Duration("timeout", time.Until(s.deadline)))
+	shutdownCtx, cancel := s.ShutdownContext()
+	defer cancel()
+
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		s.httpServer.Close()
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ShutdownContext returns a context that expires at the deadline set when
+// Serve started shutting down, so that whatever else has to stop once the
+// server has, such as background work, finishes by the same deadline. If
+// Serve stopped on an error instead, the deadline is the shutdown timeout
+// from now.
+func (s *Server) ShutdownContext() (context.Context, context.CancelFunc) {
+	if s.deadline.IsZero() {
+		return context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
 	}
-	log.Printf("Server started on http://localhost:%s", port)
-	return s.httpServer.ListenAndServe()
+	return context.WithDeadline(context.Background(), s.deadline)
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	url := "http://" + l.Addr().String()
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := New(Config{ShutdownTimeout: 5 * time.Second})
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ctx, l, handler)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		inFlight <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+
+	res := <-inFlight
+	assert.NoError(t, res.err)
+	assert.Equal(t, "done", res.body)
+	assert.NoError(t, <-served)
+
+	_, err = http.Get(url)
+	assert.Error(t, err, "server must not accept requests after shutdown")
+}
+
+func TestServeShutdownDeadline(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := New(Config{ShutdownTimeout: 100 * time.Millisecond})
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ctx, l, handler)
+	}()
+
+	go http.Get("http://" + l.Addr().String())
+
+	<-started
+	cancel()
+
+	assert.ErrorIs(t, <-served, context.DeadlineExceeded)
+}
+
+func TestServeDrainDelay(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	url := "http://" + l.Addr().String()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := New(Config{DrainDelay: 300 * time.Millisecond})
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ctx, l, handler)
+	}()
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	resp, err := http.Get(url)
+	require.NoError(t, err, "requests are still served while draining")
+	resp.Body.Close()
+
+	assert.NoError(t, <-served)
+	_, err = http.Get(url)
+	assert.Error(t, err)
+}
+
+func TestShutdownContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New(Config{ShutdownTimeout: time.Second, DrainDelay: 100 * time.Millisecond})
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ctx, l, http.NotFoundHandler())
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	stopped := time.Now()
+	cancel()
+	require.NoError(t, <-served)
+
+	shutdownCtx, cancelShutdown := s.ShutdownContext()
+	defer cancelShutdown()
+	deadline, ok := shutdownCtx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, stopped.Add(1100*time.Millisecond), deadline, 50*time.Millisecond,
+		"the deadline counts from the start of the shutdown, the drain delay included")
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/metrics"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/repository"
+
+	"github.com/google/uuid"
+)
+
+// WithBatchResponse makes the next Batch called with ctx store the response
+// rendered by render from its results under its idempotency key. See
+// repository.WithBatchResponse; Batch renders the results of all its
+// operations, including those it denied.
+func WithBatchResponse(ctx context.Context, render func([]models.BatchResult) ([]byte, error)) context.Context {
+	return repository.WithBatchResponse(ctx, render)
+}
+
+// Batch applies ops, in order, in a single transaction. If atomic, the
+// first operation that fails fails the whole batch with a BatchError.
+// Otherwise the failures are reported in the results of their operations
+// and the other operations are applied.
+func (s *Service) Batch(ctx context.Context, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, error) {
+	ops = append([]models.BatchOperation(nil), ops...)
+
+	// Check every wallet once. Wallets the client may not use are kept out
+	// of the batch and reported as forbidden.
+	denied := make(map[uuid.UUID]error)
+	checked := make(map[uuid.UUID]bool)
+	var wallets []uuid.UUID
+	for i, op := range ops {
+		if !checked[op.WalletID] {
+			checked[op.WalletID] = true
+			// Deposits create missing wallets, and withdrawals from them
+			// fail in the repository.
+			err := s.authorize(ctx, op.WalletID)
+			switch {
+			case errors.Is(err, custom_errors.ErrForbidden):
+				denied[op.WalletID] = err
+			case err != nil && !errors.Is(err, custom_errors.ErrWalletNotFound):
+				return nil, err
+			default:
+				wallets = append(wallets, op.WalletID)
+			}
+		}
+		if err, ok := denied[op.WalletID]; ok {
+			if atomic {
+				return nil, &custom_errors.BatchError{Index: i, Err: err}
+			}
+			continue
+		}
+
+		if op.Currency == "" {
+			currency, err := s.ResolveCurrency(ctx, op.WalletID, "")
+			if err != nil {
+				return nil, err
+			}
+			ops[i].Currency = currency
+		}
+	}
+
+	// The batch locks each of its wallets once, in a single transaction, so
+	// it takes one token per wallet however many operations it has on it.
+	if err := limitWallets(ctx, wallets...); err != nil {
+		return nil, err
+	}
+
+	allowed := make([]models.BatchOperation, 0, len(ops))
+	for _, op := range ops {
+		if _, ok := denied[op.WalletID]; !ok {
+			allowed = append(allowed, op)
+		}
+	}
+
+	// merge puts the results of the allowed operations back in their
+	// places among the denied ones.
+	merge := func(applied []models.BatchResult) []models.BatchResult {
+		results := make([]models.BatchResult, len(ops))
+		for i, op := range ops {
+			if err, ok := denied[op.WalletID]; ok {
+				results[i].Err = err
+				continue
+			}
+			results[i], applied = applied[0], applied[1:]
+		}
+		return results
+	}
+	if render, ok := repository.BatchResponseFromContext(ctx); ok {
+		ctx = repository.WithBatchResponse(ctx, func(applied []models.BatchResult) ([]byte, error) {
+			return render(merge(applied))
+		})
+	}
+
+	// An atomic batch got here only if nothing was denied, so the indexes of
+	// its BatchError are those of ops.
+	applied, err := s.Database.Batch(ctx, allowed, atomic)
+	if err != nil {
+		var batchErr *custom_errors.BatchError
+		if errors.As(err, &batchErr) {
+			observeBatchOperation(ops[batchErr.Index], batchErr.Err)
+		}
+		return nil, err
+	}
+
+	results := merge(applied)
+	for i, op := range ops {
+		observeBatchOperation(op, results[i].Err)
+	}
+	return results, nil
+}
+
+func observeBatchOperation(op models.BatchOperation, err error) {
+	operation := metrics.OperationDeposit
+	if op.Operation == models.OperationWithdraw {
+		operation = metrics.OperationWithdraw
+	}
+	metrics.ObserveOperation(operation, op.Currency, op.Amount, err)
+}
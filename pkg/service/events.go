@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/outbox"
+
+	"github.com/google/uuid"
+)
+
+// EventPublisher wakes the wallet event streams of this instance for the
+// events published to it. It is meant for the outbox relay; streams poll
+// for events relayed by other instances.
+func (s *Service) EventPublisher() outbox.Publisher {
+	return s.events
+}
+
+// EventStream follows the events of a wallet. Its Wake channel receives
+// when new events may be available; read them with After. Close it when
+// done.
+type EventStream struct {
+	Wake <-chan struct{}
+
+	db          Database
+	walletID    uuid.UUID
+	unsubscribe func()
+}
+
+// WatchWallet starts following the events of walletID. It fails with
+// ErrWalletNotFound if there is no such wallet and with ErrForbidden if the
+// client of ctx may not see it.
+func (s *Service) WatchWallet(ctx context.Context, walletID uuid.UUID) (*EventStream, error) {
+	wallet, err := s.Database.GetWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOwner(ctx, wallet); err != nil {
+		return nil, err
+	}
+
+	// A single pending wake-up is enough, as After reads all new events.
+	wake := make(chan struct{}, 1)
+	unsubscribe := s.events.Subscribe(func(ctx context.Context, event models.Event) error {
+		if event.WalletID == walletID {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+		return nil
+	})
+
+	return &EventStream{Wake: wake, db: s.Database, walletID: walletID, unsubscribe: unsubscribe}, nil
+}
+
+// LastID returns the ID of the latest event of the wallet, or zero if it has
+// none.
+func (es *EventStream) LastID(ctx context.Context) (int64, error) {
+	return es.db.LastEventID(ctx, es.walletID)
+}
+
+// After returns up to limit events of the wallet with IDs above afterID,
+// oldest first.
+func (es *EventStream) After(ctx context.Context, afterID int64, limit int) ([]models.Event, error) {
+	return es.db.ListEvents(ctx, es.walletID, afterID, limit)
+}
+
+// Close stops following the events of the wallet.
+func (es *EventStream) Close() {
+	es.unsubscribe()
+}
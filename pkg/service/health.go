@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	custom_errors "wallet-app/pkg/errors"
+)
+
+// Ready fails with ErrNotReady unless the database can be reached and its
+// schema has at least the expected migration version.
+func (s *Service) Ready(ctx context.Context) error {
+	if err := s.Database.Ping(ctx); err != nil {
+		return fmt.Errorf("%w: database is unreachable: %v", custom_errors.ErrNotReady, err)
+	}
+
+	if s.schemaVersion == 0 {
+		return nil
+	}
+
+	version, dirty, err := s.Database.SchemaVersion(ctx)
+	switch {
+	case err != nil:
+		return fmt.Errorf("%w: %v", custom_errors.ErrNotReady, err)
+	case dirty:
+		return fmt.Errorf("%w: migration %d failed and must be fixed by hand", custom_errors.ErrNotReady, version)
+	case version < s.schemaVersion:
+		// A newer schema is fine: during a rolling deploy the new release
+		// migrates first while the old one is still serving.
+		return fmt.Errorf("%w: schema is at version %d, expected %d", custom_errors.ErrNotReady, version, s.schemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"time"
+	"wallet-app/pkg/metrics"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// NewHold prepares an active hold on walletID that expires after the
+// configured TTL. It is only stored once passed to Authorize.
+func (s *Service) NewHold(walletID uuid.UUID, currency string, amount int64) models.Hold {
+	// Postgres keeps microseconds, so the hold is returned the same way it
+	// will later be read back.
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	return models.Hold{
+		ID:        uuid.New(),
+		WalletID:  walletID,
+		Currency:  currency,
+		Amount:    amount,
+		Status:    models.HoldActive,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.holdTTL),
+	}
+}
+
+func (s *Service) Authorize(ctx context.Context, hold models.Hold) error {
+	if err := s.authorize(ctx, hold.WalletID); err != nil {
+		return err
+	}
+	if err := limitWallets(ctx, hold.WalletID); err != nil {
+		return err
+	}
+	return s.Database.Authorize(ctx, hold)
+}
+
+func (s *Service) GetHold(ctx context.Context, holdID uuid.UUID) (models.Hold, error) {
+	hold, err := s.Database.GetHold(ctx, holdID)
+	if err != nil {
+		return hold, err
+	}
+	if err := s.authorize(ctx, hold.WalletID); err != nil {
+		return models.Hold{}, err
+	}
+	return hold, nil
+}
+
+func (s *Service) Capture(ctx context.Context, holdID uuid.UUID, amount int64) error {
+	hold, err := s.GetHold(ctx, holdID)
+	if err != nil {
+		return err
+	}
+	if err := limitWallets(ctx, hold.WalletID); err != nil {
+		return err
+	}
+
+	if err := s.Database.Capture(ctx, holdID, amount); err != nil {
+		metrics.ObserveOperation(metrics.OperationCapture, "", amount, err)
+		return err
+	}
+
+	// amount may be zero for a full capture, and the currency is the
+	// wallet's, so both are read back from the captured hold.
+	if hold, err := s.Database.GetHold(ctx, holdID); err == nil {
+		metrics.ObserveOperation(metrics.OperationCapture, hold.Currency, hold.CapturedAmount, nil)
+	}
+	return nil
+}
+
+func (s *Service) Release(ctx context.Context, holdID uuid.UUID) error {
+	hold, err := s.GetHold(ctx, holdID)
+	if err != nil {
+		return err
+	}
+	if err := limitWallets(ctx, hold.WalletID); err != nil {
+		return err
+	}
+	return s.Database.Release(ctx, holdID)
+}
@@ -0,0 +1,13 @@
+package service
+
+import (
+	"context"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/repository"
+)
+
+// WithIdempotencyKey attaches key to ctx so that the balance change made with
+// it is recorded under that key. See repository.WithIdempotencyKey.
+func WithIdempotencyKey(ctx context.Context, key models.IdempotencyKey) context.Context {
+	return repository.WithIdempotencyKey(ctx, key)
+}
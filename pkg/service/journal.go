@@ -0,0 +1,15 @@
+package service
+
+import (
+	"context"
+	"wallet-app/pkg/models"
+)
+
+// TrialBalance returns the debit and credit totals of the ledger by kind of
+// account and currency. Only admin clients may see it.
+func (s *Service) TrialBalance(ctx context.Context) ([]models.TrialBalanceLine, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return s.Database.TrialBalance(ctx)
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"wallet-app/pkg/metrics"
+	"wallet-app/pkg/outbox"
+)
+
+const DefaultOutboxBatchSize = 100
+
+// RelayOutbox publishes the pending balance change events through publisher,
+// batchSize at a time, until none are left or a batch fails. It returns how
+// many events were published.
+func (s *Service) RelayOutbox(ctx context.Context, publisher outbox.Publisher, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultOutboxBatchSize
+	}
+
+	var total int
+	for {
+		n, err := s.Database.RelayOutbox(ctx, batchSize, publisher.Publish)
+		total += n
+		metrics.OutboxPublished.Add(float64(n))
+		if err != nil {
+			metrics.OutboxPublishFailures.Inc()
+			return total, err
+		}
+		// A short batch means the outbox is drained, or that another
+		// instance is relaying it.
+		if n < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// RunOutboxRelay relays the outbox every interval until ctx is done. Events
+// that fail to publish are retried at the next tick.
+func (s *Service) RunOutboxRelay(ctx context.Context, interval time.Duration, publisher outbox.Publisher, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.RelayOutbox(ctx, publisher, batchSize); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "relay outbox", slog.Any("error", err))
+			}
+		}
+	}
+}
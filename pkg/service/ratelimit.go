@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// WalletLimiter takes a token from the rate limit of each of walletIDs. It
+// fails with ErrWalletRateLimited once the limit of one of them is used up.
+type WalletLimiter func(ctx context.Context, walletIDs ...uuid.UUID) error
+
+type walletLimiterCtx struct{}
+
+// WithWalletLimiter makes the balance operations called with ctx rate limit
+// the wallets they change with limit. They do so only once the client is
+// authorized, so that requests rejected as forbidden can't use up the limit
+// of another client's wallet.
+func WithWalletLimiter(ctx context.Context, limit WalletLimiter) context.Context {
+	return context.WithValue(ctx, walletLimiterCtx{}, limit)
+}
+
+// limitWallets applies the wallet limiter of ctx, if any, to walletIDs.
+func limitWallets(ctx context.Context, walletIDs ...uuid.UUID) error {
+	if limit, ok := ctx.Value(walletLimiterCtx{}).(WalletLimiter); ok {
+		return limit(ctx, walletIDs...)
+	}
+	return nil
+}
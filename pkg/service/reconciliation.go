@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"wallet-app/pkg/metrics"
+	"wallet-app/pkg/models"
+)
+
+const DefaultReconcileBatchSize = 1000
+
+// Reconcile checks that the balance of every wallet equals the sum of its
+// transactions. The wallets where it doesn't are recorded in the report of
+// the run and counted in the returned Reconciliation.
+func (s *Service) Reconcile(ctx context.Context, opts models.ReconcileOptions) (models.Reconciliation, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultReconcileBatchSize
+	}
+
+	run, err := s.Database.Reconcile(ctx, opts)
+	if err != nil {
+		return run, err
+	}
+	metrics.ReconciliationDiscrepancies.Set(float64(run.Discrepancies))
+
+	attrs := []any{
+		slog.String("reconciliation_id", run.ID.String()),
+		slog.Int64("wallets", run.Wallets),
+		slog.Int64("discrepancies", run.Discrepancies),
+		slog.Duration("duration", run.FinishedAt.Sub(run.StartedAt)),
+	}
+	if run.Discrepancies > 0 {
+		slog.ErrorContext(ctx, "balances don't match the ledger", attrs...)
+	} else {
+		slog.InfoContext(ctx, "balances match the ledger", attrs...)
+	}
+	return run, nil
+}
+
+// RunReconciliation reconciles every interval until ctx is done. Failures
+// are logged and retried at the next tick.
+func (s *Service) RunReconciliation(ctx context.Context, interval time.Duration, opts models.ReconcileOptions) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Reconcile(ctx, opts); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "reconcile", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// GetLatestReconciliation returns the last finished reconciliation. Only
+// admin clients may see it.
+func (s *Service) GetLatestReconciliation(ctx context.Context) (models.Reconciliation, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return models.Reconciliation{}, err
+	}
+	return s.Database.GetLatestReconciliation(ctx)
+}
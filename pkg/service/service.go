@@ -2,6 +2,10 @@ package service
 
 import (
 	"context"
+	"time"
+	"wallet-app/pkg/currency"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/outbox"
 	"wallet-app/pkg/repository"
 
 	"github.com/google/uuid"
@@ -9,18 +13,88 @@ import (
 
 type Database interface {
 	Close()
-	NewWallet(ctx context.Context, walletID uuid.UUID, amount int) error
-	Deposit(ctx context.Context, walletID uuid.UUID, amount int) error
-	Withdraw(ctx context.Context, walletID uuid.UUID, amount int) error
-	GetBalance(ctx context.Context, walletID uuid.UUID) (int, error)
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (version uint, dirty bool, err error)
+	NewWallet(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error
+	Deposit(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error
+	Withdraw(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error
+	Transfer(ctx context.Context, fromID, toID uuid.UUID, currency string, amount int64) error
+	Batch(ctx context.Context, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, error)
+	GetBalance(ctx context.Context, walletID uuid.UUID) (int64, error)
+	GetBalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (int64, error)
+	TakeBalanceSnapshots(ctx context.Context, at time.Time) (int64, error)
+	GetWallet(ctx context.Context, walletID uuid.UUID) (models.Wallet, error)
+	SetWalletStatus(ctx context.Context, walletID uuid.UUID, status, reason string) (models.WalletStatusChange, error)
+	ListTransactions(ctx context.Context, walletID uuid.UUID, filter models.TransactionFilter) ([]models.Transaction, error)
+	GetIdempotencyKey(ctx context.Context, key string) (models.IdempotencyKey, error)
+	Authorize(ctx context.Context, hold models.Hold) error
+	Capture(ctx context.Context, holdID uuid.UUID, amount int64) error
+	Release(ctx context.Context, holdID uuid.UUID) error
+	GetHold(ctx context.Context, holdID uuid.UUID) (models.Hold, error)
+	CreateClient(ctx context.Context, client models.Client, keyHash string) error
+	GetClientByKeyHash(ctx context.Context, keyHash string) (models.Client, error)
+	RevokeClient(ctx context.Context, clientID uuid.UUID) error
+	Reconcile(ctx context.Context, opts models.ReconcileOptions) (models.Reconciliation, error)
+	GetLatestReconciliation(ctx context.Context) (models.Reconciliation, error)
+	TrialBalance(ctx context.Context) ([]models.TrialBalanceLine, error)
+	RelayOutbox(ctx context.Context, limit int, publish repository.PublishFunc) (int, error)
+	ListEvents(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Event, error)
+	LastEventID(ctx context.Context, walletID uuid.UUID) (int64, error)
+	CreateWebhook(ctx context.Context, webhook models.Webhook) error
+	GetWebhook(ctx context.Context, webhookID uuid.UUID) (models.Webhook, error)
+	ListWebhooks(ctx context.Context, clientID uuid.UUID) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error
+	EnqueueWebhookDeliveries(ctx context.Context, event models.Event) (int64, error)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	GetWebhookDelivery(ctx context.Context, deliveryID int64) (models.WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit int) ([]models.WebhookDelivery, error)
+}
+
+const DefaultHoldTTL = 24 * time.Hour
+
+type Config struct {
+	// DefaultCurrency is used for wallets created without a currency.
+	DefaultCurrency string
+	// HoldTTL is how long a hold reserves funds before it expires.
+	HoldTTL time.Duration
+	// SchemaVersion is the migration version the database must be at for
+	// the service to be ready. Zero skips the check.
+	SchemaVersion uint
+	// AllowPrivateWebhooks lets webhooks point to loopback, private and
+	// link-local addresses, for local development and tests.
+	AllowPrivateWebhooks bool
 }
 
 type Service struct {
 	Database
+	defaultCurrency string
+	holdTTL         time.Duration
+	schemaVersion   uint
+	// allowPrivateWebhooks is Config.AllowPrivateWebhooks.
+	allowPrivateWebhooks bool
+	// events wakes the wallet event streams of this instance.
+	events *outbox.Bus
 }
 
-func NewService(repo *repository.Repository) *Service {
+func NewService(repo *repository.Repository, cfg Config) *Service {
+	defaultCurrency := cfg.DefaultCurrency
+	if defaultCurrency == "" {
+		defaultCurrency = currency.Default
+	}
+
+	holdTTL := cfg.HoldTTL
+	if holdTTL <= 0 {
+		holdTTL = DefaultHoldTTL
+	}
+
 	return &Service{
-		Database: repo.Database,
+		Database:        tracedDatabase{repo.Database},
+		defaultCurrency: defaultCurrency,
+		holdTTL:         holdTTL,
+		schemaVersion:   cfg.SchemaVersion,
+		events:          outbox.NewBus(),
+
+		allowPrivateWebhooks: cfg.AllowPrivateWebhooks,
 	}
 }
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SnapshotDelay is how far behind the present balance snapshots are taken.
+// A transaction is stamped when it is recorded but only becomes visible once
+// its database transaction commits, so a snapshot of the last instants could
+// miss it.
+const SnapshotDelay = time.Minute
+
+// TakeBalanceSnapshots snapshots the balances of the wallets changed since
+// the previous snapshots, which keeps GetBalanceAt from reading the whole
+// history of a wallet.
+func (s *Service) TakeBalanceSnapshots(ctx context.Context) error {
+	at := time.Now().Add(-SnapshotDelay)
+	n, err := s.Database.TakeBalanceSnapshots(ctx, at)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "balance snapshots taken", slog.Int64("wallets", n), slog.Time("as_of", at))
+	return nil
+}
+
+// RunBalanceSnapshots takes balance snapshots every interval until ctx is
+// done. Failures are logged and retried at the next tick.
+func (s *Service) RunBalanceSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.TakeBalanceSnapshots(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "take balance snapshots", slog.Any("error", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"time"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/repository"
+	"wallet-app/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracedDatabase wraps every call the service makes into the repository in
+// a span carrying the wallet or hold it works on.
+type tracedDatabase struct {
+	Database
+}
+
+func walletAttr(walletID uuid.UUID) attribute.KeyValue {
+	return tracing.WalletIDKey.String(walletID.String())
+}
+
+func holdAttr(holdID uuid.UUID) attribute.KeyValue {
+	return tracing.HoldIDKey.String(holdID.String())
+}
+
+func webhookAttr(webhookID uuid.UUID) attribute.KeyValue {
+	return tracing.WebhookIDKey.String(webhookID.String())
+}
+
+func clientAttr(clientID uuid.UUID) attribute.KeyValue {
+	return tracing.ClientIDKey.String(clientID.String())
+}
+
+func amountAttrs(currency string, amount int64) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("currency", currency),
+		attribute.Int64("amount", amount),
+	}
+}
+
+func (d tracedDatabase) NewWallet(ctx context.Context, walletID uuid.UUID, currency string, amount int64) (err error) {
+	ctx, span := tracing.Start(ctx, "service.NewWallet", walletAttr(walletID))
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(amountAttrs(currency, amount)...)
+
+	return d.Database.NewWallet(ctx, walletID, currency, amount)
+}
+
+func (d tracedDatabase) Deposit(ctx context.Context, walletID uuid.UUID, currency string, amount int64) (err error) {
+	ctx, span := tracing.Start(ctx, "service.Deposit", walletAttr(walletID))
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(amountAttrs(currency, amount)...)
+
+	return d.Database.Deposit(ctx, walletID, currency, amount)
+}
+
+func (d tracedDatabase) Withdraw(ctx context.Context, walletID uuid.UUID, currency string, amount int64) (err error) {
+	ctx, span := tracing.Start(ctx, "service.Withdraw", walletAttr(walletID))
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(amountAttrs(currency, amount)...)
+
+	return d.Database.Withdraw(ctx, walletID, currency, amount)
+}
+
+func (d tracedDatabase) Transfer(ctx context.Context, fromID, toID uuid.UUID, currency string, amount int64) (err error) {
+	ctx, span := tracing.Start(ctx, "service.Transfer", walletAttr(fromID), tracing.ToWalletIDKey.String(toID.String()))
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(amountAttrs(currency, amount)...)
+
+	return d.Database.Transfer(ctx, fromID, toID, currency, amount)
+}
+
+func (d tracedDatabase) Batch(ctx context.Context, ops []models.BatchOperation, atomic bool) (_ []models.BatchResult, err error) {
+	ctx, span := tracing.Start(ctx, "service.Batch")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.Int("batch.size", len(ops)), attribute.Bool("batch.atomic", atomic))
+
+	return d.Database.Batch(ctx, ops, atomic)
+}
+
+func (d tracedDatabase) GetBalance(ctx context.Context, walletID uuid.UUID) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "service.GetBalance", walletAttr(walletID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.GetBalance(ctx, walletID)
+}
+
+func (d tracedDatabase) GetBalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "service.GetBalanceAt", walletAttr(walletID))
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.String("as_of", at.Format(time.RFC3339Nano)))
+
+	return d.Database.GetBalanceAt(ctx, walletID, at)
+}
+
+func (d tracedDatabase) TakeBalanceSnapshots(ctx context.Context, at time.Time) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "service.TakeBalanceSnapshots")
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.TakeBalanceSnapshots(ctx, at)
+}
+
+func (d tracedDatabase) GetWallet(ctx context.Context, walletID uuid.UUID) (_ models.Wallet, err error) {
+	ctx, span := tracing.Start(ctx, "service.GetWallet", walletAttr(walletID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.GetWallet(ctx, walletID)
+}
+
+func (d tracedDatabase) SetWalletStatus(ctx context.Context, walletID uuid.UUID, status, reason string) (_ models.WalletStatusChange, err error) {
+	ctx, span := tracing.Start(ctx, "service.SetWalletStatus", walletAttr(walletID))
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.String("wallet.status", status))
+
+	return d.Database.SetWalletStatus(ctx, walletID, status, reason)
+}
+
+func (d tracedDatabase) ListTransactions(ctx context.Context, walletID uuid.UUID, filter models.TransactionFilter) (_ []models.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "service.ListTransactions", walletAttr(walletID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.ListTransactions(ctx, walletID, filter)
+}
+
+func (d tracedDatabase) GetIdempotencyKey(ctx context.Context, key string) (_ models.IdempotencyKey, err error) {
+	ctx, span := tracing.Start(ctx, "service.GetIdempotencyKey")
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.GetIdempotencyKey(ctx, key)
+}
+
+func (d tracedDatabase) Authorize(ctx context.Context, hold models.Hold) (err error) {
+	ctx, span := tracing.Start(ctx, "service.Authorize", walletAttr(hold.WalletID), holdAttr(hold.ID))
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(amountAttrs(hold.Currency, hold.Amount)...)
+
+	return d.Database.Authorize(ctx, hold)
+}
+
+func (d tracedDatabase) Capture(ctx context.Context, holdID uuid.UUID, amount int64) (err error) {
+	ctx, span := tracing.Start(ctx, "service.Capture", holdAttr(holdID))
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.Int64("amount", amount))
+
+	return d.Database.Capture(ctx, holdID, amount)
+}
+
+func (d tracedDatabase) Release(ctx context.Context, holdID uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "service.Release", holdAttr(holdID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.Release(ctx, holdID)
+}
+
+func (d tracedDatabase) GetHold(ctx context.Context, holdID uuid.UUID) (_ models.Hold, err error) {
+	ctx, span := tracing.Start(ctx, "service.GetHold", holdAttr(holdID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.GetHold(ctx, holdID)
+}
+
+func (d tracedDatabase) CreateClient(ctx context.Context, client models.Client, keyHash string) (err error) {
+	ctx, span := tracing.Start(ctx, "service.CreateClient", clientAttr(client.ID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.CreateClient(ctx, client, keyHash)
+}
+
+func (d tracedDatabase) GetClientByKeyHash(ctx context.Context, keyHash string) (_ models.Client, err error) {
+	ctx, span := tracing.Start(ctx, "service.GetClientByKeyHash")
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.GetClientByKeyHash(ctx, keyHash)
+}
+
+func (d tracedDatabase) RevokeClient(ctx context.Context, clientID uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "service.RevokeClient", clientAttr(clientID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.RevokeClient(ctx, clientID)
+}
+
+func (d tracedDatabase) Reconcile(ctx context.Context, opts models.ReconcileOptions) (run models.Reconciliation, err error) {
+	ctx, span := tracing.Start(ctx, "service.Reconcile")
+	defer func() {
+		span.SetAttributes(attribute.Int64("reconcile.wallets", run.Wallets), attribute.Int64("reconcile.discrepancies", run.Discrepancies))
+		tracing.End(span, err)
+	}()
+	span.SetAttributes(attribute.Bool("reconcile.stream", opts.Stream))
+
+	return d.Database.Reconcile(ctx, opts)
+}
+
+func (d tracedDatabase) GetLatestReconciliation(ctx context.Context) (_ models.Reconciliation, err error) {
+	ctx, span := tracing.Start(ctx, "service.GetLatestReconciliation")
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.GetLatestReconciliation(ctx)
+}
+
+func (d tracedDatabase) TrialBalance(ctx context.Context) (_ []models.TrialBalanceLine, err error) {
+	ctx, span := tracing.Start(ctx, "service.TrialBalance")
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.TrialBalance(ctx)
+}
+
+func (d tracedDatabase) RelayOutbox(ctx context.Context, limit int, publish repository.PublishFunc) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "service.RelayOutbox")
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.RelayOutbox(ctx, limit, publish)
+}
+
+func (d tracedDatabase) CreateWebhook(ctx context.Context, webhook models.Webhook) (err error) {
+	ctx, span := tracing.Start(ctx, "service.CreateWebhook", webhookAttr(webhook.ID), clientAttr(webhook.ClientID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.CreateWebhook(ctx, webhook)
+}
+
+func (d tracedDatabase) GetWebhook(ctx context.Context, webhookID uuid.UUID) (_ models.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "service.GetWebhook", webhookAttr(webhookID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.GetWebhook(ctx, webhookID)
+}
+
+func (d tracedDatabase) ListWebhooks(ctx context.Context, clientID uuid.UUID) (_ []models.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "service.ListWebhooks", clientAttr(clientID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.ListWebhooks(ctx, clientID)
+}
+
+func (d tracedDatabase) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "service.DeleteWebhook", webhookAttr(webhookID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.DeleteWebhook(ctx, webhookID)
+}
+
+func (d tracedDatabase) EnqueueWebhookDeliveries(ctx context.Context, event models.Event) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "service.EnqueueWebhookDeliveries", walletAttr(event.WalletID))
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.Int64("event.id", event.ID), attribute.String("event.type", event.Type))
+
+	return d.Database.EnqueueWebhookDeliveries(ctx, event)
+}
+
+func (d tracedDatabase) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) (_ []models.WebhookDelivery, err error) {
+	ctx, span := tracing.Start(ctx, "service.ClaimWebhookDeliveries")
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.ClaimWebhookDeliveries(ctx, limit, lease)
+}
+
+func (d tracedDatabase) UpdateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) (err error) {
+	ctx, span := tracing.Start(ctx, "service.UpdateWebhookDelivery", webhookAttr(delivery.WebhookID))
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.Int64("delivery.id", delivery.ID), attribute.String("delivery.status", delivery.Status))
+
+	return d.Database.UpdateWebhookDelivery(ctx, delivery)
+}
+
+func (d tracedDatabase) GetWebhookDelivery(ctx context.Context, deliveryID int64) (_ models.WebhookDelivery, err error) {
+	ctx, span := tracing.Start(ctx, "service.GetWebhookDelivery", attribute.Int64("delivery.id", deliveryID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.GetWebhookDelivery(ctx, deliveryID)
+}
+
+func (d tracedDatabase) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit int) (_ []models.WebhookDelivery, err error) {
+	ctx, span := tracing.Start(ctx, "service.ListWebhookDeliveries", webhookAttr(webhookID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.ListWebhookDeliveries(ctx, webhookID, status, limit)
+}
+
+func (d tracedDatabase) ListEvents(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) (_ []models.Event, err error) {
+	ctx, span := tracing.Start(ctx, "service.ListEvents", walletAttr(walletID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.ListEvents(ctx, walletID, afterID, limit)
+}
+
+func (d tracedDatabase) LastEventID(ctx context.Context, walletID uuid.UUID) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "service.LastEventID", walletAttr(walletID))
+	defer func() { tracing.End(span, err) }()
+
+	return d.Database.LastEventID(ctx, walletID)
+}
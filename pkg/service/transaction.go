@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultTransactionsLimit = 50
+	MaxTransactionsLimit     = 100
+)
+
+func (s *Service) ListTransactions(ctx context.Context, walletID uuid.UUID, filter models.TransactionFilter) (models.TransactionPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultTransactionsLimit
+	}
+	if filter.Limit > MaxTransactionsLimit {
+		filter.Limit = MaxTransactionsLimit
+	}
+	limit := filter.Limit
+
+	wallet, err := s.GetWallet(ctx, walletID)
+	if err != nil {
+		return models.TransactionPage{}, err
+	}
+
+	// Ask for one extra row to find out whether there is a next page.
+	filter.Limit++
+	transactions, err := s.Database.ListTransactions(ctx, walletID, filter)
+	if err != nil {
+		return models.TransactionPage{}, err
+	}
+
+	page := models.TransactionPage{
+		Transactions: transactions,
+		Currency:     wallet.Currency,
+	}
+	if len(transactions) > limit {
+		page.Transactions = transactions[:limit]
+		page.NextCursor = page.Transactions[limit-1].ID
+	}
+	return page, nil
+}
@@ -2,27 +2,157 @@ package service
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"log/slog"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/metrics"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/money"
 
 	"github.com/google/uuid"
 )
 
-func (s *Service) Withdraw(ctx context.Context, walletID uuid.UUID, amount int) error {
-	return s.Database.Withdraw(ctx, walletID, amount)
+// NewWallet isn't rate limited: a wallet is created when a Deposit finds it
+// missing, and the Deposit has taken the token already.
+func (s *Service) NewWallet(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error {
+	if currency == "" {
+		currency = s.defaultCurrency
+	}
+	err := s.Database.NewWallet(ctx, walletID, currency, amount)
+	// A wallet is created by its first deposit.
+	if amount > 0 {
+		metrics.ObserveOperation(metrics.OperationDeposit, currency, amount, err)
+	}
+	if err == nil {
+		slog.InfoContext(ctx, "wallet created", slog.String("wallet_id", walletID.String()), slog.String("currency", currency))
+	}
+	return err
+}
+
+func (s *Service) SetWalletStatus(ctx context.Context, walletID uuid.UUID, status, reason string) (models.WalletStatusChange, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return models.WalletStatusChange{}, err
+	}
+
+	change, err := s.Database.SetWalletStatus(ctx, walletID, status, reason)
+	if err != nil {
+		return change, err
+	}
+
+	slog.InfoContext(ctx, "wallet status changed",
+		slog.String("wallet_id", walletID.String()),
+		slog.String("from", change.From),
+		slog.String("to", change.To),
+		slog.String("reason", change.Reason),
+	)
+	return change, nil
+}
+
+func (s *Service) Withdraw(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error {
+	if err := s.authorize(ctx, walletID); err != nil {
+		return err
+	}
+	if err := limitWallets(ctx, walletID); err != nil {
+		return err
+	}
+
+	err := s.Database.Withdraw(ctx, walletID, currency, amount)
+	metrics.ObserveOperation(metrics.OperationWithdraw, currency, amount, err)
+	return err
 }
 
-func (s *Service) Deposit(ctx context.Context, walletID uuid.UUID, amount int) error {
-	return s.Database.Deposit(ctx, walletID, amount)
+func (s *Service) Deposit(ctx context.Context, walletID uuid.UUID, currency string, amount int64) error {
+	authErr := s.authorize(ctx, walletID)
+	if authErr != nil && !errors.Is(authErr, custom_errors.ErrWalletNotFound) {
+		return authErr
+	}
+	// A deposit into a missing wallet is limited too, since it goes on to
+	// create the wallet.
+	if err := limitWallets(ctx, walletID); err != nil {
+		return err
+	}
+	if authErr != nil {
+		return authErr
+	}
+
+	err := s.Database.Deposit(ctx, walletID, currency, amount)
+	metrics.ObserveOperation(metrics.OperationDeposit, currency, amount, err)
+	return err
+}
+
+// Transfer only requires the client to own fromID: paying into another
+// client's wallet is what transfers are for. For the same reason only fromID
+// is rate limited, or anyone could use up the limit of toID and lock its
+// owner out.
+func (s *Service) Transfer(ctx context.Context, fromID, toID uuid.UUID, currency string, amount int64) error {
+	if err := s.authorize(ctx, fromID); err != nil {
+		return err
+	}
+	if err := limitWallets(ctx, fromID); err != nil {
+		return err
+	}
+
+	err := s.Database.Transfer(ctx, fromID, toID, currency, amount)
+	metrics.ObserveOperation(metrics.OperationTransfer, currency, amount, err)
+	return err
+}
+
+func (s *Service) GetWallet(ctx context.Context, walletID uuid.UUID) (models.Wallet, error) {
+	wallet, err := s.Database.GetWallet(ctx, walletID)
+	if err != nil {
+		return wallet, err
+	}
+	if err := checkOwner(ctx, wallet); err != nil {
+		return models.Wallet{}, err
+	}
+	return wallet, nil
 }
 
 func (s *Service) GetBalance(ctx context.Context, walletID uuid.UUID) (string, error) {
-	balance, err := s.Database.GetBalance(ctx, walletID)
+	wallet, err := s.GetWallet(ctx, walletID)
 	if err != nil {
 		return "", err
 	}
 
-	balanceStr := fmt.Sprintf("%03d", balance)
-	balanceStr = balanceStr[:len(balanceStr)-2] + "." + balanceStr[len(balanceStr)-2:]
+	return money.Format(wallet.Balance, wallet.Currency), nil
+}
 
-	return balanceStr, nil
+// GetBalanceAt returns the balance of walletID as of at. A wallet had a zero
+// balance before its first transaction.
+func (s *Service) GetBalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (string, error) {
+	wallet, err := s.GetWallet(ctx, walletID)
+	if err != nil {
+		return "", err
+	}
+
+	balance, err := s.Database.GetBalanceAt(ctx, walletID, at)
+	if err != nil {
+		return "", err
+	}
+	return money.Format(balance, wallet.Currency), nil
+}
+
+// ResolveCurrency returns the currency an operation on walletID is made in:
+// the requested one if set, otherwise the wallet's currency, or the default
+// currency for a wallet that doesn't exist yet. A wallet of another client
+// fails with ErrForbidden whatever the currency, so that neither its
+// currency nor, through amount validation, its precision is disclosed.
+func (s *Service) ResolveCurrency(ctx context.Context, walletID uuid.UUID, requested string) (string, error) {
+	wallet, err := s.Database.GetWallet(ctx, walletID)
+	switch {
+	case errors.Is(err, custom_errors.ErrWalletNotFound):
+		wallet.Currency = s.defaultCurrency
+	case err != nil:
+		return "", err
+	default:
+		if err := checkOwner(ctx, wallet); err != nil {
+			return "", err
+		}
+	}
+
+	if requested != "" {
+		return requested, nil
+	}
+	return wallet.Currency, nil
 }
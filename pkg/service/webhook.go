@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	custom_errors "wallet-app/pkg/errors"
+	"wallet-app/pkg/metrics"
+	"wallet-app/pkg/models"
+	"wallet-app/pkg/outbox"
+	"wallet-app/pkg/webhook"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultWebhookBatchSize = 100
+	// webhookLeaseMargin is added to the timeout of an attempt to get how
+	// long a claimed delivery is kept from other workers.
+	webhookLeaseMargin = time.Minute
+	// MaxListedDeliveries is how many deliveries ListWebhookDeliveries
+	// returns at most.
+	MaxListedDeliveries = 100
+
+	webhookSecretPrefix = "whsec_"
+)
+
+// WebhookOptions control the delivery of webhooks.
+type WebhookOptions struct {
+	// Client sends the deliveries. Its Timeout bounds each attempt and must
+	// be set.
+	Client *http.Client
+	// MaxAttempts is how many times a delivery is tried before it fails for
+	// good.
+	MaxAttempts int
+	// Backoff is the delay after the first failed attempt; it doubles with
+	// every further one.
+	Backoff   time.Duration
+	BatchSize int
+}
+
+// checkWebhookOwner hides the webhooks of other clients, except from
+// admins. Calls without a client are not restricted.
+func checkWebhookOwner(ctx context.Context, wh models.Webhook) error {
+	client, ok := ClientFromContext(ctx)
+	if !ok || client.Admin || wh.ClientID == client.ID {
+		return nil
+	}
+	return custom_errors.ErrWebhookNotFound
+}
+
+// CreateWebhook registers wh for the client of ctx and returns it with its
+// ID. A secret is generated if wh has none.
+func (s *Service) CreateWebhook(ctx context.Context, wh models.Webhook) (models.Webhook, error) {
+	if client, ok := ClientFromContext(ctx); ok {
+		wh.ClientID = client.ID
+	}
+	if wh.ClientID == uuid.Nil {
+		return models.Webhook{}, custom_errors.ErrUnauthorized
+	}
+
+	if !s.allowPrivateWebhooks {
+		u, err := url.Parse(wh.URL)
+		if err != nil {
+			return models.Webhook{}, fmt.Errorf("parse url: %w", err)
+		}
+		if err := webhook.CheckURL(ctx, u); err != nil {
+			return models.Webhook{}, err
+		}
+	}
+
+	if wh.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return models.Webhook{}, fmt.Errorf("generate secret: %w", err)
+		}
+		wh.Secret = webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	}
+
+	wh.ID = uuid.New()
+	wh.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	if err := s.Database.CreateWebhook(ctx, wh); err != nil {
+		return models.Webhook{}, err
+	}
+	return wh, nil
+}
+
+// ListWebhooks returns the webhooks of the client of ctx.
+func (s *Service) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	client, ok := ClientFromContext(ctx)
+	if !ok {
+		return nil, custom_errors.ErrUnauthorized
+	}
+	return s.Database.ListWebhooks(ctx, client.ID)
+}
+
+func (s *Service) GetWebhook(ctx context.Context, webhookID uuid.UUID) (models.Webhook, error) {
+	wh, err := s.Database.GetWebhook(ctx, webhookID)
+	if err != nil {
+		return wh, err
+	}
+	if err := checkWebhookOwner(ctx, wh); err != nil {
+		return models.Webhook{}, err
+	}
+	return wh, nil
+}
+
+func (s *Service) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error {
+	if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+		return err
+	}
+	return s.Database.DeleteWebhook(ctx, webhookID)
+}
+
+// ListWebhookDeliveries returns the latest deliveries of a webhook with
+// status, or with any status if it is empty.
+func (s *Service) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status string) ([]models.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	return s.Database.ListWebhookDeliveries(ctx, webhookID, status, MaxListedDeliveries)
+}
+
+// Redeliver makes a delivered or failed delivery pending again, with a full
+// set of attempts, so that the worker sends it as soon as it can.
+func (s *Service) Redeliver(ctx context.Context, webhookID uuid.UUID, deliveryID int64) (models.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	d, err := s.Database.GetWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		return d, err
+	}
+	if d.WebhookID != webhookID {
+		return models.WebhookDelivery{}, custom_errors.ErrDeliveryNotFound
+	}
+	if d.Status == models.DeliveryPending {
+		return d, custom_errors.ErrDeliveryPending
+	}
+
+	d.Status = models.DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now()
+	if err := s.Database.UpdateWebhookDelivery(ctx, d); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+// WebhookPublisher queues the events published to it for delivery to the
+// webhooks subscribed to them. It is meant for the outbox relay.
+func (s *Service) WebhookPublisher() outbox.Publisher {
+	return outbox.Handler(func(ctx context.Context, event models.Event) error {
+		_, err := s.Database.EnqueueWebhookDeliveries(ctx, event)
+		return err
+	})
+}
+
+// DeliverWebhooks sends the deliveries that are due, a batch at a time and
+// the deliveries of a batch in parallel, until none are left. It returns
+// how many were delivered. Failed attempts are scheduled for a retry, or
+// marked failed once out of attempts; only database errors are returned.
+func (s *Service) DeliverWebhooks(ctx context.Context, opts WebhookOptions) (int, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultWebhookBatchSize
+	}
+	lease := opts.Client.Timeout + webhookLeaseMargin
+
+	var total int
+	for {
+		deliveries, err := s.Database.ClaimWebhookDeliveries(ctx, opts.BatchSize, lease)
+		if err != nil {
+			return total, err
+		}
+
+		var (
+			wg   sync.WaitGroup
+			mu   sync.Mutex
+			errs []error
+		)
+		for _, d := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				delivered, err := s.deliverWebhook(ctx, d, opts)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if delivered {
+					total++
+				}
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if err := errors.Join(errs...); err != nil {
+			return total, err
+		}
+		if len(deliveries) < opts.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// deliverWebhook makes one attempt at d and records its outcome.
+func (s *Service) deliverWebhook(ctx context.Context, d models.WebhookDelivery, opts WebhookOptions) (delivered bool, err error) {
+	wh, err := s.Database.GetWebhook(ctx, d.WebhookID)
+	if errors.Is(err, custom_errors.ErrWebhookNotFound) {
+		// Deleted since the delivery was claimed, together with it.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	statusCode, sendErr := webhook.Send(ctx, opts.Client, wh.URL, wh.Secret, d.Event)
+	if ctx.Err() != nil {
+		// Shutting down; the lease runs out and the delivery is retried.
+		return false, nil
+	}
+
+	d.Attempts++
+	d.LastStatusCode = statusCode
+	switch {
+	case sendErr == nil:
+		d.Status = models.DeliveryDelivered
+		d.LastError = ""
+		metrics.WebhookAttempts.WithLabelValues(models.DeliveryDelivered).Inc()
+	case d.Attempts >= opts.MaxAttempts:
+		d.Status = models.DeliveryFailed
+		d.LastError = sendErr.Error()
+		metrics.WebhookAttempts.WithLabelValues(models.DeliveryFailed).Inc()
+		slog.WarnContext(ctx, "webhook delivery failed for good",
+			slog.Int64("delivery_id", d.ID),
+			slog.String("webhook_id", wh.ID.String()),
+			slog.Int("attempts", d.Attempts),
+			slog.Any("error", sendErr))
+	default:
+		d.NextAttemptAt = time.Now().Add(webhook.Backoff(opts.Backoff, d.Attempts))
+		d.LastError = sendErr.Error()
+		metrics.WebhookAttempts.WithLabelValues("retried").Inc()
+	}
+
+	if err := s.Database.UpdateWebhookDelivery(ctx, d); err != nil && !errors.Is(err, custom_errors.ErrDeliveryNotFound) {
+		return false, err
+	}
+	return sendErr == nil, nil
+}
+
+// RunWebhookDeliveries delivers webhooks every interval until ctx is done.
+// Failures are logged and retried at the next tick.
+func (s *Service) RunWebhookDeliveries(ctx context.Context, interval time.Duration, opts WebhookOptions) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.DeliverWebhooks(ctx, opts); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "deliver webhooks", slog.Any("error", err))
+			}
+		}
+	}
+}
@@ -2,8 +2,10 @@ package testutils
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"testing"
+	"wallet-app/pkg/migrations"
 	"wallet-app/pkg/repository"
 
 	"github.com/testcontainers/testcontainers-go"
@@ -20,53 +22,104 @@ const (
 var (
 	TestPG      *repository.Repository
 	testOnce    sync.Once
+	testErr     error
 	pgContainer *postgres.PostgresContainer
 	ctx         = context.Background()
 )
 
+// ForEachBackend runs fn as a subtest against every repository backend.
+// The Postgres subtest is skipped when Docker is not available.
+func ForEachBackend(t *testing.T, fn func(t *testing.T, repo *repository.Repository)) {
+	t.Run("memory", func(t *testing.T) {
+		fn(t, SetupTestMemory(t))
+	})
+	t.Run("postgres", func(t *testing.T) {
+		fn(t, SetupTestPG(t))
+	})
+}
+
+// SetupTestMemory returns a repository backed by a fresh in-memory database.
+func SetupTestMemory(t *testing.T) *repository.Repository {
+	return repository.NewRepository(repository.NewMemory())
+}
+
 func SetupTestPG(t *testing.T) *repository.Repository {
 	testOnce.Do(func() {
-		var err error
-		// t.Fatalf(os.Executable())
-		pgContainer, err = postgres.Run(
-			ctx,
-			dbImage,
-			postgres.WithInitScripts("../testutils/init-wallet-db.sh"),
-			postgres.WithDatabase(dbName),
-			postgres.WithUsername(dbUser),
-			postgres.WithPassword(dbPassword),
-			postgres.BasicWaitStrategies(),
-		)
-		if err != nil {
-			t.Fatalf("failed to start postgres container: %v", err)
-		}
+		testErr = startPG()
+	})
 
-		ip, err := pgContainer.ContainerIP(ctx)
-		if err != nil {
-			t.Fatalf("failed to get container ip: %v", err)
-		}
+	if testErr != nil {
+		t.Skipf("postgres is not available: %v", testErr)
+	}
 
-		cfg := repository.Config{
-			Host:    ip,
-			Port:    "5432",
-			User:    "user",
-			Pass:    "password",
-			DBName:  "database",
-			SSLMode: "disable",
-		}
+	return TestPG
+}
 
-		pg, err := repository.NewPG(ctx, cfg)
-		if err != nil {
-			t.Fatalf("pg init error: %v", err)
+func startPG() (err error) {
+	// testcontainers panics instead of returning an error when there is no
+	// Docker daemon at all.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("docker is not available: %v", r)
 		}
+	}()
 
-		TestPG = repository.NewRepository(pg)
+	pgContainer, err = postgres.Run(
+		ctx,
+		dbImage,
+		postgres.WithDatabase(dbName),
+		postgres.WithUsername(dbUser),
+		postgres.WithPassword(dbPassword),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to start postgres container: %w", err)
+	}
 
-		go func() {
-			<-ctx.Done()
-			_ = testcontainers.TerminateContainer(pgContainer)
-		}()
-	})
+	ip, err := pgContainer.ContainerIP(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get container ip: %w", err)
+	}
 
-	return TestPG
+	cfg := repository.Config{
+		Host:    ip,
+		Port:    "5432",
+		User:    "user",
+		Pass:    "password",
+		DBName:  "database",
+		SSLMode: "disable",
+	}
+
+	if err := migrate(cfg.ConnString()); err != nil {
+		return err
+	}
+
+	pg, err := repository.NewPG(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("pg init error: %w", err)
+	}
+
+	TestPG = repository.NewRepository(pg)
+
+	go func() {
+		<-ctx.Done()
+		_ = testcontainers.TerminateContainer(pgContainer)
+	}()
+
+	return nil
+}
+
+// migrate applies the same embedded migrations as production, so test and
+// production schemas can't drift apart.
+func migrate(connString string) error {
+	migrator, err := migrations.New(connString, migrations.Settings{})
+	if err != nil {
+		return fmt.Errorf("init migrations: %w", err)
+	}
+	defer migrator.Close()
+
+	if err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("apply migrations: %w", err)
+	}
+	return nil
 }
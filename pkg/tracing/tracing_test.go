@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestFileExporter(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterFile, File: path, SampleRatio: 1})
+	require.NoError(t, err)
+
+	ctx, parent := Start(context.Background(), "parent", WalletIDKey.String("wallet-1"))
+	childCtx, child := Start(ctx, "child", HoldIDKey.String("hold-1"))
+	assert.Equal(t, []attribute.KeyValue{WalletIDKey.String("wallet-1"), HoldIDKey.String("hold-1")}, Attributes(childCtx),
+		"child contexts inherit the attributes of their parents")
+	End(child, nil)
+	End(parent, nil)
+
+	require.NoError(t, shutdown(context.Background()))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var span struct{ Name string }
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		names = append(names, span.Name)
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, []string{"child", "parent"}, names)
+}
+
+func TestUnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), Config{Exporter: "jaeger"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+	"wallet-app/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPublic(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.216.34":          true,
+		"2606:2800:220:1::1":     true,
+		"127.0.0.1":              false,
+		"10.1.2.3":               false,
+		"172.16.0.1":             false,
+		"192.168.1.1":            false,
+		"169.254.169.254":        false,
+		"100.64.0.1":             false,
+		"0.0.0.0":                false,
+		"255.255.255.255":        false,
+		"224.0.0.1":              false,
+		"::1":                    false,
+		"::":                     false,
+		"fd00::1":                false,
+		"fe80::1":                false,
+		"::ffff:127.0.0.1":       false,
+		"::ffff:169.254.169.254": false,
+		"64:ff9b::a9fe:a9fe":     false,
+	} {
+		assert.Equal(t, public, IsPublic(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for _, rawURL := range []string{
+		"http://127.0.0.1:8080/hooks",
+		"http://169.254.169.254/latest/meta-data",
+		"https://10.0.0.1",
+		"http://[::1]/hooks",
+		"http://localhost/hooks",
+	} {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		assert.ErrorIs(t, CheckURL(t.Context(), u), ErrPrivateAddress, rawURL)
+	}
+
+	u, err := url.Parse("https://93.184.216.34/hooks")
+	require.NoError(t, err)
+	assert.NoError(t, CheckURL(t.Context(), u))
+}
+
+func TestNewClient(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	_, err := Send(t.Context(), NewClient(time.Second, false), receiver.URL, "secret", models.Event{})
+	assert.ErrorIs(t, err, ErrPrivateAddress, "the address is checked when connecting")
+
+	code, err := Send(t.Context(), NewClient(time.Second, true), receiver.URL, "secret", models.Event{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+}
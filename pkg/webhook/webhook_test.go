@@ -0,0 +1,72 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"wallet-app/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	const secret = "s3cr3t"
+	event := models.Event{ID: 7, Type: models.EventDeposited, WalletID: uuid.New(), Amount: 100, Balance: 100, Currency: "RUB"}
+
+	status := http.StatusNoContent
+	var verifyErr error
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		verifyErr = Verify(secret, r.Header, body, time.Minute, time.Now())
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	code, err := Send(t.Context(), receiver.Client(), receiver.URL, secret, event)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.NoError(t, verifyErr)
+
+	code, err = Send(t.Context(), receiver.Client(), receiver.URL, "wrong", event)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.ErrorIs(t, verifyErr, ErrBadSignature)
+
+	status = http.StatusServiceUnavailable
+	code, err = Send(t.Context(), receiver.Client(), receiver.URL, secret, event)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	receiver.Close()
+	code, err = Send(t.Context(), receiver.Client(), receiver.URL, secret, event)
+	assert.Error(t, err)
+	assert.Zero(t, code)
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Unix(1_760_000_000, 0)
+	header := http.Header{}
+	header.Set(TimestampHeader, "1760000000")
+	header.Set(SignatureHeader, Sign("secret", now.Unix(), body))
+
+	assert.NoError(t, Verify("secret", header, body, time.Minute, now))
+	assert.ErrorIs(t, Verify("secret", header, []byte(`{"id":2}`), time.Minute, now), ErrBadSignature)
+	assert.ErrorIs(t, Verify("secret", header, body, time.Minute, now.Add(2*time.Minute)), ErrBadSignature, "replayed")
+
+	header.Set(TimestampHeader, "soon")
+	assert.ErrorIs(t, Verify("secret", header, body, time.Minute, now), ErrBadSignature)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 10*time.Second, Backoff(10*time.Second, 1))
+	assert.Equal(t, 20*time.Second, Backoff(10*time.Second, 2))
+	assert.Equal(t, 80*time.Second, Backoff(10*time.Second, 4))
+	assert.Equal(t, MaxBackoff, Backoff(10*time.Second, 20))
+	assert.Equal(t, MaxBackoff, Backoff(10*time.Second, 1000), "doesn't overflow")
+}
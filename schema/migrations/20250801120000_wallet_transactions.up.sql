@@ -0,0 +1,12 @@
+CREATE TABLE wallet_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    operation TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp()
+);
+
+CREATE INDEX wallet_transactions_wallet_id_idx ON wallet_transactions (wallet_id, id DESC);
+
+INSERT INTO wallet_transactions (wallet_id, operation, amount)
+SELECT id, 'opening_balance', balance FROM wallets WHERE balance <> 0;
@@ -0,0 +1 @@
+ALTER TABLE wallet_transactions DROP COLUMN counterparty_id;
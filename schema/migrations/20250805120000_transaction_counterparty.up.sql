@@ -0,0 +1 @@
+ALTER TABLE wallet_transactions ADD COLUMN counterparty_id UUID REFERENCES wallets (id);
@@ -0,0 +1,9 @@
+-- Existing wallets get the currency from the wallet.default_currency setting,
+-- which the application sets from DEFAULT_CURRENCY when it migrates, falling
+-- back to RUB.
+ALTER TABLE wallets ADD COLUMN currency CHAR(3);
+
+UPDATE wallets SET currency = COALESCE(NULLIF(current_setting('wallet.default_currency', true), ''), 'RUB');
+
+ALTER TABLE wallets ALTER COLUMN currency SET NOT NULL;
+ALTER TABLE wallets ADD CONSTRAINT wallets_currency_check CHECK (currency ~ '^[A-Z]{3}$');
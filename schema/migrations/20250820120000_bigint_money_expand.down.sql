@@ -0,0 +1,9 @@
+DROP PROCEDURE backfill_bigint_money;
+
+DROP TRIGGER wallet_transactions_sync_amount_bigint ON wallet_transactions;
+DROP FUNCTION wallet_transactions_sync_amount_bigint;
+DROP TRIGGER wallets_sync_balance_bigint ON wallets;
+DROP FUNCTION wallets_sync_balance_bigint;
+
+ALTER TABLE wallet_transactions DROP COLUMN amount_bigint;
+ALTER TABLE wallets DROP COLUMN balance_bigint;
@@ -0,0 +1,54 @@
+-- Step 1 of 5 of moving money columns from INTEGER to BIGINT without
+-- rewriting the tables under an exclusive lock. New BIGINT columns are added
+-- next to the old ones and kept in sync by triggers, so the application can
+-- keep running and writing the old columns.
+ALTER TABLE wallets ADD COLUMN balance_bigint BIGINT;
+ALTER TABLE wallet_transactions ADD COLUMN amount_bigint BIGINT;
+
+CREATE FUNCTION wallets_sync_balance_bigint() RETURNS trigger AS $$
+BEGIN
+    NEW.balance_bigint := NEW.balance;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallets_sync_balance_bigint
+    BEFORE INSERT OR UPDATE OF balance ON wallets
+    FOR EACH ROW EXECUTE FUNCTION wallets_sync_balance_bigint();
+
+CREATE FUNCTION wallet_transactions_sync_amount_bigint() RETURNS trigger AS $$
+BEGIN
+    NEW.amount_bigint := NEW.amount;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallet_transactions_sync_amount_bigint
+    BEFORE INSERT OR UPDATE OF amount ON wallet_transactions
+    FOR EACH ROW EXECUTE FUNCTION wallet_transactions_sync_amount_bigint();
+
+-- Copies existing rows in small committed batches so that row locks are held
+-- only briefly. Run it with `CALL backfill_bigint_money();` from psql before
+-- applying the next migration on large databases; the next migration then
+-- has nothing left to copy.
+CREATE PROCEDURE backfill_bigint_money(batch_size INTEGER DEFAULT 10000) AS $$
+DECLARE
+    updated INTEGER;
+BEGIN
+    LOOP
+        UPDATE wallets SET balance_bigint = balance
+        WHERE id IN (SELECT id FROM wallets WHERE balance_bigint IS NULL LIMIT batch_size);
+        GET DIAGNOSTICS updated = ROW_COUNT;
+        COMMIT;
+        EXIT WHEN updated = 0;
+    END LOOP;
+
+    LOOP
+        UPDATE wallet_transactions SET amount_bigint = amount
+        WHERE id IN (SELECT id FROM wallet_transactions WHERE amount_bigint IS NULL LIMIT batch_size);
+        GET DIAGNOSTICS updated = ROW_COUNT;
+        COMMIT;
+        EXIT WHEN updated = 0;
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,2 @@
+-- Nothing to undo: the copied values are dropped together with the columns.
+SELECT 1;
@@ -0,0 +1,7 @@
+-- Step 2 of 5: copy whatever backfill_bigint_money() hasn't copied yet. This
+-- is a single UPDATE in the transaction of the migration, which keeps every
+-- row it copies locked until it commits. On large tables, run
+-- `CALL backfill_bigint_money();` before deploying, so that it finds nothing
+-- left to copy.
+UPDATE wallets SET balance_bigint = balance WHERE balance_bigint IS NULL;
+UPDATE wallet_transactions SET amount_bigint = amount WHERE amount_bigint IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE wallet_transactions DROP CONSTRAINT wallet_transactions_amount_bigint_not_null;
+ALTER TABLE wallets DROP CONSTRAINT wallets_balance_bigint_check;
+ALTER TABLE wallets DROP CONSTRAINT wallets_balance_bigint_not_null;
@@ -0,0 +1,7 @@
+-- Step 3 of 5: the constraints the BIGINT columns need before the swap. NOT
+-- VALID applies them to new rows only, so the exclusive lock they take is
+-- released as soon as this migration commits. Each is validated against the
+-- existing rows by a migration of its own.
+ALTER TABLE wallets ADD CONSTRAINT wallets_balance_bigint_not_null CHECK (balance_bigint IS NOT NULL) NOT VALID;
+ALTER TABLE wallets ADD CONSTRAINT wallets_balance_bigint_check CHECK (balance_bigint >= 0) NOT VALID;
+ALTER TABLE wallet_transactions ADD CONSTRAINT wallet_transactions_amount_bigint_not_null CHECK (amount_bigint IS NOT NULL) NOT VALID;
@@ -0,0 +1,2 @@
+-- Nothing to undo: bigint_money_constraints drops the constraint.
+SELECT 1;
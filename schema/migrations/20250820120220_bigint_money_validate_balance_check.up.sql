@@ -0,0 +1,4 @@
+-- Step 4 of 5, in a migration of its own: VALIDATE CONSTRAINT scans the
+-- table under a lock that doesn't block writes, which holds only while no
+-- statement in the same transaction takes a stronger one.
+ALTER TABLE wallets VALIDATE CONSTRAINT wallets_balance_bigint_check;
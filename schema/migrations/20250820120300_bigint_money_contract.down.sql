@@ -0,0 +1,63 @@
+-- Brings the schema back to the state after the constraints are validated.
+-- Fails if a balance no longer fits into INTEGER.
+ALTER TABLE wallets RENAME COLUMN balance TO balance_bigint;
+ALTER TABLE wallets RENAME CONSTRAINT wallets_balance_check TO wallets_balance_bigint_check;
+ALTER TABLE wallets ALTER COLUMN balance_bigint DROP DEFAULT;
+ALTER TABLE wallets ADD CONSTRAINT wallets_balance_bigint_not_null CHECK (balance_bigint IS NOT NULL);
+ALTER TABLE wallets ALTER COLUMN balance_bigint DROP NOT NULL;
+ALTER TABLE wallets ADD COLUMN balance INTEGER;
+UPDATE wallets SET balance = balance_bigint;
+ALTER TABLE wallets ALTER COLUMN balance SET NOT NULL;
+ALTER TABLE wallets ALTER COLUMN balance SET DEFAULT 0;
+ALTER TABLE wallets ADD CONSTRAINT wallets_balance_check CHECK (balance >= 0);
+
+ALTER TABLE wallet_transactions RENAME COLUMN amount TO amount_bigint;
+ALTER TABLE wallet_transactions ADD CONSTRAINT wallet_transactions_amount_bigint_not_null CHECK (amount_bigint IS NOT NULL);
+ALTER TABLE wallet_transactions ALTER COLUMN amount_bigint DROP NOT NULL;
+ALTER TABLE wallet_transactions ADD COLUMN amount INTEGER;
+UPDATE wallet_transactions SET amount = amount_bigint;
+ALTER TABLE wallet_transactions ALTER COLUMN amount SET NOT NULL;
+
+CREATE FUNCTION wallets_sync_balance_bigint() RETURNS trigger AS $$
+BEGIN
+    NEW.balance_bigint := NEW.balance;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallets_sync_balance_bigint
+    BEFORE INSERT OR UPDATE OF balance ON wallets
+    FOR EACH ROW EXECUTE FUNCTION wallets_sync_balance_bigint();
+
+CREATE FUNCTION wallet_transactions_sync_amount_bigint() RETURNS trigger AS $$
+BEGIN
+    NEW.amount_bigint := NEW.amount;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallet_transactions_sync_amount_bigint
+    BEFORE INSERT OR UPDATE OF amount ON wallet_transactions
+    FOR EACH ROW EXECUTE FUNCTION wallet_transactions_sync_amount_bigint();
+
+CREATE PROCEDURE backfill_bigint_money(batch_size INTEGER DEFAULT 10000) AS $$
+DECLARE
+    updated INTEGER;
+BEGIN
+    LOOP
+        UPDATE wallets SET balance_bigint = balance
+        WHERE id IN (SELECT id FROM wallets WHERE balance_bigint IS NULL LIMIT batch_size);
+        GET DIAGNOSTICS updated = ROW_COUNT;
+        COMMIT;
+        EXIT WHEN updated = 0;
+    END LOOP;
+
+    LOOP
+        UPDATE wallet_transactions SET amount_bigint = amount
+        WHERE id IN (SELECT id FROM wallet_transactions WHERE amount_bigint IS NULL LIMIT batch_size);
+        GET DIAGNOSTICS updated = ROW_COUNT;
+        COMMIT;
+        EXIT WHEN updated = 0;
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
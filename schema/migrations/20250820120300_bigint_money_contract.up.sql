@@ -0,0 +1,21 @@
+-- Step 5 of 5: swap the columns. SET NOT NULL skips the table scan thanks
+-- to the constraints validated by the previous migrations, so everything
+-- here only touches the catalog and the exclusive lock is held briefly.
+ALTER TABLE wallets ALTER COLUMN balance_bigint SET NOT NULL;
+ALTER TABLE wallets DROP CONSTRAINT wallets_balance_bigint_not_null;
+ALTER TABLE wallet_transactions ALTER COLUMN amount_bigint SET NOT NULL;
+ALTER TABLE wallet_transactions DROP CONSTRAINT wallet_transactions_amount_bigint_not_null;
+
+DROP PROCEDURE backfill_bigint_money;
+DROP TRIGGER wallets_sync_balance_bigint ON wallets;
+DROP FUNCTION wallets_sync_balance_bigint;
+DROP TRIGGER wallet_transactions_sync_amount_bigint ON wallet_transactions;
+DROP FUNCTION wallet_transactions_sync_amount_bigint;
+
+ALTER TABLE wallets DROP COLUMN balance;
+ALTER TABLE wallets RENAME COLUMN balance_bigint TO balance;
+ALTER TABLE wallets RENAME CONSTRAINT wallets_balance_bigint_check TO wallets_balance_check;
+ALTER TABLE wallets ALTER COLUMN balance SET DEFAULT 0;
+
+ALTER TABLE wallet_transactions DROP COLUMN amount;
+ALTER TABLE wallet_transactions RENAME COLUMN amount_bigint TO amount;
@@ -0,0 +1,14 @@
+-- A hold reserves part of a wallet's balance until it is captured, released
+-- or expires. Expiry is not stored as a status: an active hold past its
+-- expires_at simply stops counting against the available balance.
+CREATE TABLE holds (
+    id UUID PRIMARY KEY,
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    captured_amount BIGINT NOT NULL DEFAULT 0 CHECK (captured_amount >= 0 AND captured_amount <= amount),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'captured', 'released')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX holds_wallet_id_active_idx ON holds (wallet_id, expires_at) WHERE status = 'active';
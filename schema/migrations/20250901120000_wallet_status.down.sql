@@ -0,0 +1,2 @@
+DROP TABLE wallet_status_changes;
+ALTER TABLE wallets DROP COLUMN status;
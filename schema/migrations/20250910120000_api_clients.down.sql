@@ -0,0 +1,2 @@
+ALTER TABLE wallets DROP COLUMN owner_id;
+DROP TABLE api_clients;
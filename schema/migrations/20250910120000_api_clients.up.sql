@@ -0,0 +1,15 @@
+-- API clients authenticate with a key; only its SHA-256 hash is stored.
+CREATE TABLE api_clients (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    admin BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+-- Wallets created before authentication have no owner and are only
+-- reachable by admin clients until one is assigned.
+ALTER TABLE wallets ADD COLUMN owner_id UUID REFERENCES api_clients (id);
+
+CREATE INDEX wallets_owner_id_idx ON wallets (owner_id);
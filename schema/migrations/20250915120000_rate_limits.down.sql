@@ -0,0 +1,5 @@
+ALTER TABLE api_clients
+    DROP CONSTRAINT api_clients_rate_limit_check,
+    DROP COLUMN rate_burst,
+    DROP COLUMN rate_limit;
+DROP TABLE rate_limit_buckets;
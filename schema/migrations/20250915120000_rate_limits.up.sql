@@ -0,0 +1,17 @@
+-- Token buckets of the Postgres rate limit store. Rows are deleted once the
+-- bucket has refilled (full_at), as a full bucket is the same as none.
+CREATE TABLE rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    full_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX rate_limit_buckets_full_at_idx ON rate_limit_buckets (full_at);
+
+-- Per-client overrides of the default client rate limit; NULL keeps the
+-- default, a rate of 0 disables the limit.
+ALTER TABLE api_clients
+    ADD COLUMN rate_limit DOUBLE PRECISION CHECK (rate_limit >= 0),
+    ADD COLUMN rate_burst INTEGER CHECK (rate_burst >= 0),
+    ADD CONSTRAINT api_clients_rate_limit_check CHECK ((rate_limit IS NULL) = (rate_burst IS NULL));
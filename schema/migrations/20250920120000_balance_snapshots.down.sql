@@ -0,0 +1,3 @@
+DROP INDEX wallet_transactions_created_at_idx;
+DROP INDEX wallet_transactions_wallet_id_created_at_idx;
+DROP TABLE balance_snapshots;
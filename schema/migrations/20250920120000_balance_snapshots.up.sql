@@ -0,0 +1,17 @@
+-- Balance of a wallet as of taken_at: the sum of its transactions created at
+-- or before that instant. The balance at any later instant is the latest
+-- snapshot plus the transactions after it, so history is never scanned from
+-- the start.
+CREATE TABLE balance_snapshots (
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    taken_at TIMESTAMPTZ NOT NULL,
+    balance BIGINT NOT NULL,
+    PRIMARY KEY (wallet_id, taken_at)
+);
+
+CREATE INDEX balance_snapshots_taken_at_idx ON balance_snapshots (taken_at);
+
+-- Balances as of an instant read a wallet's transactions by time, and taking
+-- snapshots reads all transactions since the previous ones.
+CREATE INDEX wallet_transactions_wallet_id_created_at_idx ON wallet_transactions (wallet_id, created_at);
+CREATE INDEX wallet_transactions_created_at_idx ON wallet_transactions (created_at);
@@ -0,0 +1,21 @@
+-- Runs of the ledger reconciliation. finished_at stays NULL for a run that
+-- didn't complete.
+CREATE TABLE reconciliation_runs (
+    id UUID PRIMARY KEY,
+    streaming BOOLEAN NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp(),
+    finished_at TIMESTAMPTZ,
+    wallets BIGINT NOT NULL DEFAULT 0,
+    discrepancies BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX reconciliation_runs_finished_at_idx ON reconciliation_runs (finished_at DESC) WHERE finished_at IS NOT NULL;
+
+-- Wallets whose balance didn't match the sum of their transactions.
+CREATE TABLE reconciliation_discrepancies (
+    run_id UUID NOT NULL REFERENCES reconciliation_runs (id) ON DELETE CASCADE,
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    balance BIGINT NOT NULL,
+    ledger_total BIGINT NOT NULL,
+    PRIMARY KEY (run_id, wallet_id)
+);
@@ -0,0 +1,7 @@
+DROP TRIGGER wallets_create_ledger_accounts ON wallets;
+DROP FUNCTION wallets_create_ledger_accounts;
+DROP TABLE journal_postings;
+DROP FUNCTION journal_postings_append_only;
+DROP FUNCTION journal_postings_check_balanced;
+DROP TABLE journal_entries;
+DROP TABLE ledger_accounts;
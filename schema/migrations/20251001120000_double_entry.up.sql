@@ -0,0 +1,106 @@
+-- Double-entry journal. Every operation is a journal entry whose postings
+-- move money between ledger accounts: debits are positive, credits negative,
+-- and the postings of an entry sum to zero.
+CREATE TABLE ledger_accounts (
+    id TEXT PRIMARY KEY,
+    kind TEXT NOT NULL CHECK (kind IN ('wallet', 'cash_in_clearing', 'cash_out_clearing', 'fees')),
+    wallet_id UUID UNIQUE REFERENCES wallets (id),
+    currency TEXT NOT NULL,
+    CHECK ((kind = 'wallet') = (wallet_id IS NOT NULL))
+);
+
+CREATE TABLE journal_entries (
+    id BIGSERIAL PRIMARY KEY,
+    operation TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp()
+);
+
+CREATE TABLE journal_postings (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES journal_entries (id),
+    account_id TEXT NOT NULL REFERENCES ledger_accounts (id),
+    amount BIGINT NOT NULL CHECK (amount <> 0)
+);
+
+CREATE INDEX journal_postings_entry_id_idx ON journal_postings (entry_id);
+CREATE INDEX journal_postings_account_id_idx ON journal_postings (account_id);
+
+-- Checked at commit, once all the postings of the entry are in.
+CREATE FUNCTION journal_postings_check_balanced() RETURNS trigger AS $$
+DECLARE
+    total NUMERIC;
+    currencies BIGINT;
+BEGIN
+    SELECT SUM(p.amount), COUNT(DISTINCT a.currency) INTO total, currencies
+    FROM journal_postings p
+    JOIN ledger_accounts a ON a.id = p.account_id
+    WHERE p.entry_id = NEW.entry_id;
+
+    IF total <> 0 THEN
+        RAISE EXCEPTION 'journal entry % is unbalanced by %', NEW.entry_id, total;
+    END IF;
+    IF currencies > 1 THEN
+        RAISE EXCEPTION 'journal entry % mixes currencies', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER journal_postings_balanced
+    AFTER INSERT ON journal_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION journal_postings_check_balanced();
+
+-- The journal is append-only, so an entry can't be unbalanced afterwards.
+CREATE FUNCTION journal_postings_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'journal postings can''t be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER journal_postings_append_only
+    BEFORE UPDATE OR DELETE ON journal_postings
+    FOR EACH ROW EXECUTE FUNCTION journal_postings_append_only();
+
+-- Every wallet gets its account, and its currency the system accounts, as
+-- it is created.
+CREATE FUNCTION wallets_create_ledger_accounts() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO ledger_accounts (id, kind, currency)
+    SELECT kind || ':' || NEW.currency, kind, NEW.currency
+    FROM unnest(ARRAY['cash_in_clearing', 'cash_out_clearing', 'fees']) AS kind
+    ON CONFLICT DO NOTHING;
+
+    INSERT INTO ledger_accounts (id, kind, wallet_id, currency)
+    VALUES (NEW.id::TEXT, 'wallet', NEW.id, NEW.currency);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallets_create_ledger_accounts
+    AFTER INSERT ON wallets
+    FOR EACH ROW EXECUTE FUNCTION wallets_create_ledger_accounts();
+
+-- Accounts of the existing wallets, and an entry bringing in each balance
+-- through the cash-in clearing account.
+INSERT INTO ledger_accounts (id, kind, currency)
+SELECT DISTINCT kind || ':' || currency, kind, currency
+FROM wallets, unnest(ARRAY['cash_in_clearing', 'cash_out_clearing', 'fees']) AS kind;
+
+INSERT INTO ledger_accounts (id, kind, wallet_id, currency)
+SELECT id::TEXT, 'wallet', id, currency FROM wallets;
+
+CREATE TEMPORARY TABLE opening_entries AS
+SELECT nextval('journal_entries_id_seq') AS entry_id, id AS wallet_id, balance, currency
+FROM wallets
+WHERE balance <> 0;
+
+INSERT INTO journal_entries (id, operation)
+SELECT entry_id, 'opening_balance' FROM opening_entries;
+
+INSERT INTO journal_postings (entry_id, account_id, amount)
+SELECT entry_id, 'cash_in_clearing:' || currency, balance FROM opening_entries
+UNION ALL
+SELECT entry_id, wallet_id::TEXT, -balance FROM opening_entries;
+
+DROP TABLE opening_entries;
@@ -0,0 +1,16 @@
+-- Balance change events waiting to be published. An event is written in the
+-- transaction that changes the balance, so it exists if and only if the
+-- change was committed. published_at is set once the relay has handed the
+-- event to the publisher.
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    amount BIGINT NOT NULL,
+    balance BIGINT NOT NULL,
+    currency TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at TIMESTAMPTZ
+);
+
+CREATE INDEX outbox_events_pending_idx ON outbox_events (id) WHERE published_at IS NULL;
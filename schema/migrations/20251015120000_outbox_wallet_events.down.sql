@@ -0,0 +1 @@
+DROP INDEX outbox_events_wallet_id_idx;
@@ -0,0 +1,2 @@
+-- Wallet event streams read the events of one wallet after a given ID.
+CREATE INDEX outbox_events_wallet_id_idx ON outbox_events (wallet_id, id);
@@ -0,0 +1,10 @@
+// Package schema embeds the database migrations so that the binary can apply
+// them itself.
+package schema
+
+import "embed"
+
+// Migrations holds the golang-migrate files under migrations/.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS